@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/openapi"
+)
+
+// openapi writes the OpenAPI document of a package annotated with swag
+// comments. cmd/server runs it through go generate.
+func main() {
+	dir := flag.String("dir", ".", "package directory")
+	out := flag.String("out", "openapi.json", "output file")
+	flag.Parse()
+
+	doc, err := openapi.Generate(*dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := openapi.Marshal(doc)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
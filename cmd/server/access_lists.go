@@ -0,0 +1,498 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// htpasswdMode keeps password hashes away from other local users. nginx
+// workers read the file through its group, so the data directory should be
+// owned by the group nginx runs as.
+const htpasswdMode = 0o640
+
+// cryptAlphabet is the base64 alphabet of crypt(3) style hashes
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// AccessList represents IP rules and basic-auth users that protect hosts or locations
+type AccessList struct {
+	ID int `json:"id" example:"1"`
+	Revision
+	Name      string           `json:"name" example:"Office only"`
+	Satisfy   string           `json:"satisfy" example:"any"`
+	Rules     []AccessRule     `json:"rules"`
+	Users     []AccessListUser `json:"users"`
+	CreatedAt string           `json:"created_at" example:"2025-12-08T10:00:00Z"`
+}
+
+// AccessRule allows or denies an address or CIDR range. Rules are evaluated in order.
+type AccessRule struct {
+	Action  string `json:"action" example:"allow"`
+	Address string `json:"address" example:"10.0.0.0/8"`
+}
+
+// AccessListUser is a basic-auth user. The password is only stored as an
+// apr1 hash, the salted MD5 scheme nginx verifies on every platform.
+type AccessListUser struct {
+	Username     string `json:"username" example:"admin"`
+	PasswordHash string `json:"-"`
+}
+
+// AccessListRequest represents the request body for creating/updating access lists
+type AccessListRequest struct {
+	Name    string                  `json:"name" binding:"required" example:"Office only"`
+	Satisfy string                  `json:"satisfy" example:"any"`
+	Rules   []AccessRule            `json:"rules"`
+	Users   []AccessListUserRequest `json:"users"`
+}
+
+// AccessListUserRequest sets a basic-auth user. An empty password keeps the
+// current password of an existing user.
+type AccessListUserRequest struct {
+	Username string `json:"username" example:"admin"`
+	Password string `json:"password,omitempty" example:"s3cret"`
+}
+
+// validateAccessList normalizes and checks an access list request
+func validateAccessList(req *AccessListRequest) error {
+	if req.Satisfy == "" {
+		req.Satisfy = "all"
+	}
+	var errs fieldErrors
+	if req.Satisfy != "all" && req.Satisfy != "any" {
+		errs.add("satisfy", "satisfy must be %q or %q", "all", "any")
+	}
+
+	for i, rule := range req.Rules {
+		if rule.Action != "allow" && rule.Action != "deny" {
+			errs.add(fmt.Sprintf("rules[%d].action", i), "rules[%d].action must be %q or %q", i, "allow", "deny")
+		}
+		if rule.Address == "all" || net.ParseIP(rule.Address) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(rule.Address); err != nil {
+			errs.add(fmt.Sprintf("rules[%d].address", i), "rules[%d].address must be an IP, a CIDR range or \"all\"", i)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, user := range req.Users {
+		field := fmt.Sprintf("users[%d].username", i)
+		if user.Username == "" || strings.ContainsAny(user.Username, ": \t\r\n") {
+			errs.add(field, "%s must be non-empty and contain no ':' or whitespace", field)
+		} else if seen[user.Username] {
+			errs.add(field, "%s: duplicate username %q", field, user.Username)
+		}
+		seen[user.Username] = true
+	}
+
+	return errs.err()
+}
+
+// accessListUsers hashes the requested users, keeping existing hashes for
+// users sent without a password
+func accessListUsers(req []AccessListUserRequest, existing []AccessListUser) ([]AccessListUser, error) {
+	hashes := map[string]string{}
+	for _, user := range existing {
+		hashes[user.Username] = user.PasswordHash
+	}
+
+	var errs fieldErrors
+	users := make([]AccessListUser, 0, len(req))
+	for i, user := range req {
+		hash := hashes[user.Username]
+		if user.Password != "" {
+			salt, err := apr1Salt()
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash password for %q: %w", user.Username, err)
+			}
+			hash = apr1(user.Password, salt)
+		}
+		if hash == "" {
+			errs.add(fmt.Sprintf("users[%d].password", i), "users[%d].password is required for new user %q", i, user.Username)
+		}
+		users = append(users, AccessListUser{Username: user.Username, PasswordHash: hash})
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// validateAccessListRef checks that an attached access list exists.
+// Callers must hold store.mu.
+func validateAccessListRef(id *int) error {
+	if id == nil {
+		return nil
+	}
+	if _, ok := store.accessLists.get(*id); !ok {
+		return fmt.Errorf("access list %d does not exist", *id)
+	}
+	return nil
+}
+
+// accessListInUse reports what still references an access list.
+// Callers must hold store.mu.
+func accessListInUse(id int) (string, bool) {
+	for _, host := range store.proxyHosts.all() {
+		if host.AccessListID != nil && *host.AccessListID == id {
+			return fmt.Sprintf("proxy host %d", host.ID), true
+		}
+		for _, loc := range host.Locations {
+			if loc.AccessListID != nil && *loc.AccessListID == id {
+				return fmt.Sprintf("location %q of proxy host %d", loc.Path, host.ID), true
+			}
+		}
+	}
+	return "", false
+}
+
+// ListAccessLists godoc
+// @Summary      List all access lists
+// @Description  Get a list of all access lists
+// @Tags         access-lists
+// @Produce      json
+// @Success      200 {array} AccessList
+// @Router       /access-lists [get]
+func ListAccessLists(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return c.JSON(store.accessLists.all())
+}
+
+// CreateAccessList godoc
+// @Summary      Create a new access list
+// @Description  Create IP allow/deny rules and basic-auth users that can be attached to proxy hosts and locations
+// @Tags         access-lists
+// @Accept       json
+// @Produce      json
+// @Param        list body AccessListRequest true "Access List"
+// @Success      201 {object} AccessList
+// @Header       201 {string} ETag "Version of the access list"
+// @Failure      400 {object} ErrorResponse
+// @Router       /access-lists [post]
+func CreateAccessList(c *fiber.Ctx) error {
+	var req AccessListRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateAccessList(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+	users, err := accessListUsers(req.Users, nil)
+	if err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	list := AccessList{
+		ID:        store.accessLists.newID(),
+		Name:      req.Name,
+		Satisfy:   req.Satisfy,
+		Rules:     req.Rules,
+		Users:     users,
+		CreatedAt: now(),
+	}
+	if list.Rules == nil {
+		list.Rules = []AccessRule{}
+	}
+	list = store.accessLists.put(list.ID, list)
+
+	setETag(c, list.Version)
+	return c.Status(201).JSON(list)
+}
+
+// GetAccessList godoc
+// @Summary      Get an access list
+// @Description  Get a specific access list by ID
+// @Tags         access-lists
+// @Produce      json
+// @Param        id path int true "Access List ID"
+// @Success      200 {object} AccessList
+// @Header       200 {string} ETag "Version of the access list"
+// @Failure      404 {object} ErrorResponse
+// @Router       /access-lists/{id} [get]
+func GetAccessList(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	list, ok := store.accessLists.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Access list not found")
+	}
+
+	setETag(c, list.Version)
+	return c.JSON(list)
+}
+
+// UpdateAccessList godoc
+// @Summary      Update an access list
+// @Description  Replace the rules and users of an access list. Users sent without a password keep their current one.
+// @Tags         access-lists
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Access List ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        list body AccessListRequest true "Updated Access List"
+// @Success      200 {object} AccessList
+// @Header       200 {string} ETag "Version of the access list"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /access-lists/{id} [put]
+func UpdateAccessList(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req AccessListRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateAccessList(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	list, ok := store.accessLists.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Access list not found")
+	}
+	if err := checkIfMatch(c, list.Version); err != nil {
+		return respondStale(c, list.Version, err)
+	}
+
+	users, err := accessListUsers(req.Users, list.Users)
+	if err != nil {
+		return respondInvalid(c, err)
+	}
+
+	list.Name = req.Name
+	list.Satisfy = req.Satisfy
+	list.Rules = req.Rules
+	if list.Rules == nil {
+		list.Rules = []AccessRule{}
+	}
+	list.Users = users
+	list = store.accessLists.put(list.ID, list)
+
+	setETag(c, list.Version)
+	return c.JSON(list)
+}
+
+// PatchAccessList godoc
+// @Summary      Partially update an access list
+// @Description  Change some fields of an access list with a JSON merge patch (RFC 7396). Rules and users are replaced as a whole when present.
+// @Tags         access-lists
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Access List ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        list body AccessListRequest true "Fields to change"
+// @Success      200 {object} AccessList
+// @Header       200 {string} ETag "Version of the access list"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /access-lists/{id} [patch]
+func PatchAccessList(c *fiber.Ctx) error {
+	return patchResource(c, store.accessLists, "Access list not found", accessListRequest, UpdateAccessList)
+}
+
+// accessListRequest returns the request that would set up an access list as
+// it is. Users are sent without password so they keep their current one.
+func accessListRequest(list AccessList) AccessListRequest {
+	users := make([]AccessListUserRequest, len(list.Users))
+	for i, user := range list.Users {
+		users[i] = AccessListUserRequest{Username: user.Username}
+	}
+	return AccessListRequest{
+		Name:    list.Name,
+		Satisfy: list.Satisfy,
+		Rules:   list.Rules,
+		Users:   users,
+	}
+}
+
+// DeleteAccessList godoc
+// @Summary      Delete an access list
+// @Description  Delete an access list that is no longer attached to any proxy host or location
+// @Tags         access-lists
+// @Produce      json
+// @Param        id path int true "Access List ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /access-lists/{id} [delete]
+func DeleteAccessList(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	list, ok := store.accessLists.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Access list not found")
+	}
+	if err := checkIfMatch(c, list.Version); err != nil {
+		return respondStale(c, list.Version, err)
+	}
+	if user, inUse := accessListInUse(id); inUse {
+		return respondError(c, 409, "Conflict", fmt.Sprintf("Access list is used by %s", user))
+	}
+	store.accessLists.remove(id)
+
+	return c.JSON(fiber.Map{
+		"message": "Access list deleted successfully",
+		"id":      id,
+	})
+}
+
+// htpasswdPath returns where the user file of an access list is written
+func htpasswdPath(id int) string {
+	return filepath.Join(nginxController.Config().DataPath, "access-lists", strconv.Itoa(id)+".htpasswd")
+}
+
+// renderHtpasswdFiles renders one htpasswd file per access list with users.
+// Files left behind by deleted lists or lists without users are removed.
+func renderHtpasswdFiles() []nginx.File {
+	var files []nginx.File
+	for _, list := range store.accessLists.all() {
+		if len(list.Users) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		for _, user := range list.Users {
+			fmt.Fprintf(&b, "%s:%s\n", user.Username, user.PasswordHash)
+		}
+		files = append(files, nginx.File{Path: htpasswdPath(list.ID), Content: b.String(), Mode: htpasswdMode})
+	}
+	return removeStale(files, filepath.Join(filepath.Dir(htpasswdPath(0)), "*.htpasswd"))
+}
+
+// apr1Salt returns a random salt for apr1
+func apr1Salt() (string, error) {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	for i, b := range salt {
+		salt[i] = cryptAlphabet[int(b)%len(cryptAlphabet)]
+	}
+	return string(salt), nil
+}
+
+// apr1 hashes a password with the Apache MD5 crypt scheme, as produced by
+// htpasswd -m and openssl passwd -apr1
+func apr1(password, salt string) string {
+	const magic = "$apr1$"
+	pw := []byte(password)
+
+	alternate := md5.Sum([]byte(password + salt + password))
+	h := md5.New()
+	h.Write([]byte(password + magic + salt))
+	for i := len(pw); i > 0; i -= 16 {
+		h.Write(alternate[:min(i, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	sum := h.Sum(nil)
+
+	// 1000 rounds make brute forcing a leaked file slower
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 != 0 {
+			h.Write(pw)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(pw)
+		}
+		if i&1 != 0 {
+			h.Write(sum)
+		} else {
+			h.Write(pw)
+		}
+		sum = h.Sum(nil)
+	}
+
+	var b strings.Builder
+	b.WriteString(magic + salt + "$")
+	encode := func(v uint, n int) {
+		for ; n > 0; n-- {
+			b.WriteByte(cryptAlphabet[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, i := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint(sum[i[0]])<<16|uint(sum[i[1]])<<8|uint(sum[i[2]]), 4)
+	}
+	encode(uint(sum[11]), 2)
+	return b.String()
+}
+
+// renderAccessList renders the allow/deny and auth_basic directives of an
+// attached access list
+func renderAccessList(id *int) []nginx.Directive {
+	if id == nil {
+		return nil
+	}
+	list, ok := store.accessLists.get(*id)
+	if !ok {
+		return nil
+	}
+
+	var directives []nginx.Directive
+	if len(list.Rules) > 0 && len(list.Users) > 0 {
+		directives = append(directives, nginx.Simple("satisfy", list.Satisfy))
+	}
+	for _, rule := range list.Rules {
+		directives = append(directives, nginx.Simple(rule.Action, rule.Address))
+	}
+	// An allow list only restricts access once everything else is denied
+	if n := len(list.Rules); n > 0 && list.Rules[n-1].Action == "allow" && list.Rules[n-1].Address != "all" {
+		directives = append(directives, nginx.Simple("deny", "all"))
+	}
+	if len(list.Users) > 0 {
+		directives = append(directives,
+			nginx.Simple("auth_basic", list.Name),
+			nginx.Simple("auth_basic_user_file", htpasswdPath(list.ID)),
+		)
+	}
+	return directives
+}
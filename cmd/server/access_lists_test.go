@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+func TestAPR1(t *testing.T) {
+	// Expected values come from openssl passwd -apr1
+	tests := []struct {
+		password, salt, want string
+	}{
+		{"myPassword", "r31bcdEF", "$apr1$r31bcdEF$SB5xqoljmkEtetWtTkv140"},
+		{"a much longer password than sixteen bytes", "Ab./9xYz", "$apr1$Ab./9xYz$CohTQH.Glab9ROVcPiwST0"},
+	}
+	for _, tt := range tests {
+		if got := apr1(tt.password, tt.salt); got != tt.want {
+			t.Errorf("apr1(%q, %q) = %s, want %s", tt.password, tt.salt, got, tt.want)
+		}
+	}
+}
+
+func TestRenderAccessListDeniesAfterAllow(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []AccessRule
+		want  string
+	}{
+		{"trailing allow", []AccessRule{{"allow", "10.0.0.0/8"}}, "allow 10.0.0.0/8;\ndeny all;\n"},
+		{"trailing deny", []AccessRule{{"allow", "10.0.0.1"}, {"deny", "10.0.0.0/8"}}, "allow 10.0.0.1;\ndeny 10.0.0.0/8;\n"},
+		{"allow all", []AccessRule{{"deny", "10.0.0.1"}, {"allow", "all"}}, "deny 10.0.0.1;\nallow all;\n"},
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, tt := range tests {
+		id := store.accessLists.newID()
+		store.accessLists.put(id, AccessList{ID: id, Name: tt.name, Satisfy: "all", Rules: tt.rules})
+		got := nginx.Render(renderAccessList(&id))
+		store.accessLists.remove(id)
+
+		if got != tt.want {
+			t.Errorf("%s: rendered\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
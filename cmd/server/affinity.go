@@ -0,0 +1,175 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+// Session affinity modes
+const (
+	affinityCookie = "cookie"
+	affinityIPHash = "ip_hash"
+	affinityHash   = "hash"
+)
+
+// cookieNamePattern matches cookie names that nginx can read through $cookie_*
+var cookieNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// Affinity configures session stickiness for an upstream group
+type Affinity struct {
+	Mode       string        `json:"mode" example:"cookie"`
+	Cookie     *StickyCookie `json:"cookie,omitempty"`
+	HashKey    string        `json:"hash_key,omitempty" example:"$http_x_session_id"`
+	Consistent bool          `json:"consistent,omitempty" example:"true"`
+}
+
+// StickyCookie describes the cookie generated for cookie-based affinity
+type StickyCookie struct {
+	Name    string `json:"name" example:"bs_backend"`
+	Expires string `json:"expires,omitempty" example:"1h"`
+	Path    string `json:"path,omitempty" example:"/"`
+}
+
+// validateAffinity normalizes an affinity definition and rejects
+// combinations nginx cannot express. Every affinity mode replaces the
+// balancing method, so it only works with the default round robin.
+func validateAffinity(upstreamName, algorithm string, affinity *Affinity) error {
+	if affinity == nil {
+		return nil
+	}
+	var errs fieldErrors
+
+	switch affinity.Mode {
+	case affinityCookie, affinityIPHash, affinityHash:
+	default:
+		errs.add("mode", "mode must be one of %q, %q or %q", affinityCookie, affinityIPHash, affinityHash)
+	}
+
+	if algorithm != "round_robin" {
+		errs.add("mode", "mode %s cannot be combined with the %s algorithm", affinity.Mode, algorithm)
+	}
+	if affinity.Mode != affinityCookie && affinity.Cookie != nil {
+		errs.add("cookie", "cookie is only allowed with %s affinity", affinityCookie)
+	}
+	if affinity.Mode != affinityHash && affinity.HashKey != "" {
+		errs.add("hash_key", "hash_key is only allowed with %s affinity", affinityHash)
+	}
+	if affinity.Mode == affinityIPHash && affinity.Consistent {
+		errs.add("consistent", "consistent is not supported with %s affinity", affinityIPHash)
+	}
+
+	switch affinity.Mode {
+	case affinityHash:
+		if affinity.HashKey == "" {
+			errs.add("hash_key", "hash_key is required for %s affinity", affinityHash)
+		}
+	case affinityCookie:
+		if affinity.Cookie == nil {
+			affinity.Cookie = &StickyCookie{}
+		}
+		cookie := affinity.Cookie
+		if cookie.Name == "" {
+			cookie.Name = "bs_" + variableName(upstreamName)
+		}
+		if !cookieNamePattern.MatchString(cookie.Name) {
+			errs.add("cookie.name", "cookie.name may only contain letters, digits and '_'")
+		}
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+		if !strings.HasPrefix(cookie.Path, "/") || strings.ContainsAny(cookie.Path, "; \t") {
+			errs.add("cookie.path", "cookie.path must start with '/' and contain no spaces or ';'")
+		}
+		if cookie.Expires != "" {
+			if d, err := time.ParseDuration(cookie.Expires); err != nil || d <= 0 {
+				errs.add("cookie.expires", "cookie.expires must be a positive duration such as 1h or 30m")
+			}
+		}
+		// Consistent hashing keeps most clients on their server when the group changes
+		affinity.Consistent = true
+	}
+
+	return errs.err()
+}
+
+// variableName turns an upstream name into a valid nginx variable suffix
+func variableName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// stickyKeyVariable is hashed by a cookie-affinity upstream. It holds the
+// cookie value, or the request ID that is about to become the cookie.
+func stickyKeyVariable(upstream Upstream) string {
+	return "$bs_sticky_key_" + variableName(upstream.Name)
+}
+
+// stickyCookieVariable holds the Set-Cookie value for clients that have no
+// affinity cookie yet and is empty otherwise
+func stickyCookieVariable(upstream Upstream) string {
+	return "$bs_sticky_cookie_" + variableName(upstream.Name)
+}
+
+// renderAffinityMaps renders the http-context maps that back cookie affinity.
+// Open source nginx has no sticky directive, so the first request is hashed
+// on $request_id and the same value is handed out as the cookie.
+func renderAffinityMaps(upstream Upstream) []nginx.Directive {
+	if upstream.Affinity == nil || upstream.Affinity.Mode != affinityCookie {
+		return nil
+	}
+
+	cookie := upstream.Affinity.Cookie
+	source := "$cookie_" + cookie.Name
+
+	setCookie := cookie.Name + "=$request_id; Path=" + cookie.Path
+	if cookie.Expires != "" {
+		d, _ := time.ParseDuration(cookie.Expires)
+		setCookie += "; Max-Age=" + strconv.Itoa(int(d.Seconds()))
+	}
+	setCookie += "; HttpOnly"
+
+	return []nginx.Directive{
+		nginx.NewBlock("map", []string{source, stickyKeyVariable(upstream)},
+			nginx.Simple(`""`, "$request_id"),
+			nginx.Simple("default", source),
+		),
+		nginx.NewBlock("map", []string{source, stickyCookieVariable(upstream)},
+			nginx.Simple(`""`, setCookie),
+			nginx.Simple("default", ""),
+		),
+	}
+}
+
+// renderAffinity renders the balancing directive inside the upstream block
+func renderAffinity(upstream Upstream) []nginx.Directive {
+	affinity := upstream.Affinity
+	if affinity == nil {
+		return nil
+	}
+
+	switch affinity.Mode {
+	case affinityIPHash:
+		return []nginx.Directive{nginx.Simple("ip_hash")}
+	case affinityHash:
+		args := []string{affinity.HashKey}
+		if affinity.Consistent {
+			args = append(args, "consistent")
+		}
+		return []nginx.Directive{nginx.Simple("hash", args...)}
+	case affinityCookie:
+		return []nginx.Directive{nginx.Simple("hash", stickyKeyVariable(upstream), "consistent")}
+	}
+	return nil
+}
+
+// renderAffinityLocation renders what a location proxying to the upstream
+// needs so that clients receive their affinity cookie
+func renderAffinityLocation(upstream Upstream) []nginx.Directive {
+	if upstream.Affinity == nil || upstream.Affinity.Mode != affinityCookie {
+		return nil
+	}
+	return []nginx.Directive{nginx.Simple("add_header", "Set-Cookie", stickyCookieVariable(upstream))}
+}
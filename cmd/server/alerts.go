@@ -0,0 +1,679 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Alert rule kinds
+const (
+	ruleUpstreamHealthy     = "upstream_healthy_below"
+	ruleErrorRate           = "error_rate_above"
+	ruleCertificateExpiring = "certificate_expiring"
+	ruleConfigDrift         = "config_drift"
+)
+
+// Alert statuses
+const (
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+)
+
+const (
+	alertInterval = 30 * time.Second
+	// alertMinRequests keeps a handful of failed requests on an idle host from
+	// firing an error rate alert
+	alertMinRequests = 20
+	// alertRetention is how long resolved alerts are kept
+	alertRetention = 7 * 24 * time.Hour
+)
+
+// AlertRule fires an alert for every subject matching its condition.
+//
+//   - upstream_healthy_below: fewer than threshold healthy servers in an upstream
+//   - error_rate_above: more than threshold percent 5xx responses on a proxy host over window
+//   - certificate_expiring: a certificate expires within threshold days
+//   - config_drift: the configuration files on disk differ from the last applied configuration
+//
+// UpstreamID, ProxyHostID and CertificateID narrow a rule to one resource;
+// without them every resource is checked.
+type AlertRule struct {
+	ID int `json:"id" example:"1"`
+	Revision
+	Name          string  `json:"name" example:"backend capacity"`
+	Kind          string  `json:"kind" example:"upstream_healthy_below"`
+	UpstreamID    *int    `json:"upstream_id,omitempty" example:"1"`
+	ProxyHostID   *int    `json:"proxy_host_id,omitempty" example:"1"`
+	CertificateID *int    `json:"certificate_id,omitempty" example:"1"`
+	Threshold     float64 `json:"threshold" example:"2"`
+	// Window is the traffic window of error_rate_above
+	Window string `json:"window,omitempty" example:"5m"`
+	// For is how long the condition must hold before the alert fires
+	For         string `json:"for,omitempty" example:"1m"`
+	NotifierIDs []int  `json:"notifier_ids" example:"1"`
+	Enabled     bool   `json:"enabled" example:"true"`
+	CreatedAt   string `json:"created_at" example:"2025-12-08T10:00:00Z"`
+}
+
+// AlertRuleRequest creates or updates an alert rule
+type AlertRuleRequest struct {
+	Name          string  `json:"name" binding:"required" example:"backend capacity"`
+	Kind          string  `json:"kind" example:"upstream_healthy_below"`
+	UpstreamID    *int    `json:"upstream_id,omitempty" example:"1"`
+	ProxyHostID   *int    `json:"proxy_host_id,omitempty" example:"1"`
+	CertificateID *int    `json:"certificate_id,omitempty" example:"1"`
+	Threshold     float64 `json:"threshold" example:"2"`
+	Window        string  `json:"window,omitempty" example:"5m"`
+	For           string  `json:"for,omitempty" example:"1m"`
+	NotifierIDs   []int   `json:"notifier_ids" binding:"required" example:"1"`
+	Enabled       *bool   `json:"enabled,omitempty" example:"true"`
+}
+
+// Alert is one subject of a rule that fired
+type Alert struct {
+	ID            int                 `json:"id" example:"1"`
+	RuleID        int                 `json:"rule_id" example:"1"`
+	Subject       string              `json:"subject" example:"upstream backend"`
+	Status        string              `json:"status" example:"firing"`
+	Message       string              `json:"message" example:"1 of 3 servers healthy, expected at least 2"`
+	Value         float64             `json:"value" example:"1"`
+	StartedAt     string              `json:"started_at" example:"2025-12-08T10:00:00Z"`
+	ResolvedAt    string              `json:"resolved_at,omitempty" example:"2025-12-08T10:05:00Z"`
+	Notifications []AlertNotification `json:"notifications"`
+}
+
+// AlertNotification records one notification sent for an alert
+type AlertNotification struct {
+	NotifierID int    `json:"notifier_id" example:"1"`
+	Status     string `json:"status" example:"firing"`
+	At         string `json:"at" example:"2025-12-08T10:00:00Z"`
+	Error      string `json:"error,omitempty"`
+}
+
+// alertCondition is a subject for which a rule's condition currently holds
+type alertCondition struct {
+	subject string
+	message string
+	value   float64
+}
+
+// alertPending remembers since when a condition holds, for rules with a
+// For duration. Only the alert goroutine uses it.
+var alertPending = map[string]time.Time{}
+
+// validateAlertRule normalizes and checks an alert rule request.
+// Callers must hold store.mu.
+func validateAlertRule(req *AlertRuleRequest) error {
+	var errs fieldErrors
+	// The name ends up in notification subjects and email headers
+	if strings.ContainsFunc(req.Name, unicode.IsControl) {
+		errs.add("name", "name must not contain control characters")
+	}
+
+	switch req.Kind {
+	case ruleUpstreamHealthy:
+		if req.Threshold < 1 {
+			errs.add("threshold", "threshold must be at least 1 healthy server")
+		}
+		if req.UpstreamID != nil {
+			if _, ok := store.upstreams.get(*req.UpstreamID); !ok {
+				errs.add("upstream_id", "upstream_id: upstream %d does not exist", *req.UpstreamID)
+			}
+		}
+	case ruleErrorRate:
+		if req.Threshold <= 0 || req.Threshold >= 100 {
+			errs.add("threshold", "threshold must be a percentage between 0 and 100")
+		}
+		if req.Window == "" {
+			req.Window = "5m"
+		}
+		window, err := time.ParseDuration(req.Window)
+		if err != nil || window < time.Minute || window > analyticsRetention {
+			errs.add("window", "window must be a duration between 1m and %s", analyticsRetention)
+		}
+		if req.ProxyHostID != nil {
+			if _, ok := store.proxyHosts.get(*req.ProxyHostID); !ok {
+				errs.add("proxy_host_id", "proxy_host_id: proxy host %d does not exist", *req.ProxyHostID)
+			}
+		}
+	case ruleCertificateExpiring:
+		if req.Threshold < 1 {
+			errs.add("threshold", "threshold must be at least 1 day")
+		}
+		if req.CertificateID != nil {
+			if _, ok := store.certificates.get(*req.CertificateID); !ok {
+				errs.add("certificate_id", "certificate_id: certificate %d does not exist", *req.CertificateID)
+			}
+		}
+	case ruleConfigDrift:
+		if req.Threshold != 0 {
+			errs.add("threshold", "threshold is not used by %q", ruleConfigDrift)
+		}
+	default:
+		errs.add("kind", "kind must be one of %q, %q, %q or %q",
+			ruleUpstreamHealthy, ruleErrorRate, ruleCertificateExpiring, ruleConfigDrift)
+	}
+
+	if req.Kind != ruleUpstreamHealthy && req.UpstreamID != nil {
+		errs.add("upstream_id", "upstream_id is only used by %q", ruleUpstreamHealthy)
+	}
+	if req.Kind != ruleErrorRate && (req.ProxyHostID != nil || req.Window != "") {
+		errs.add("proxy_host_id", "proxy_host_id and window are only used by %q", ruleErrorRate)
+	}
+	if req.Kind != ruleCertificateExpiring && req.CertificateID != nil {
+		errs.add("certificate_id", "certificate_id is only used by %q", ruleCertificateExpiring)
+	}
+
+	if req.For != "" {
+		if d, err := time.ParseDuration(req.For); err != nil || d < 0 {
+			errs.add("for", "for must be a duration such as 5m")
+		}
+	}
+	for i, id := range req.NotifierIDs {
+		if _, ok := store.notifiers.get(id); !ok {
+			errs.add(fmt.Sprintf("notifier_ids[%d]", i), "notifier_ids[%d]: notifier %d does not exist", i, id)
+		}
+	}
+	return errs.err()
+}
+
+// alertSnapshot is the state rules are evaluated against
+type alertSnapshot struct {
+	upstreams    []Upstream
+	servers      []UpstreamServer
+	hosts        []ProxyHost
+	certificates []Certificate
+	files        map[string]string
+}
+
+// takeAlertSnapshot copies what rules need. Callers must hold store.mu.
+func takeAlertSnapshot() alertSnapshot {
+	snapshot := alertSnapshot{
+		upstreams:    store.upstreams.all(),
+		servers:      store.upstreamServers.all(),
+		hosts:        store.proxyHosts.all(),
+		certificates: store.certificates.all(),
+		files:        map[string]string{},
+	}
+	maps.Copy(snapshot.files, appliedFiles)
+	return snapshot
+}
+
+// configDrift returns the applied files whose content on disk differs.
+// Changes that were not applied yet are not drift, and nothing drifts
+// before the first apply.
+func configDrift(files map[string]string) []string {
+	var drifted []string
+	for path, content := range files {
+		current, err := os.ReadFile(path)
+		if err != nil || string(current) != content {
+			drifted = append(drifted, path)
+		}
+	}
+	slices.Sort(drifted)
+	return drifted
+}
+
+// evaluateRule returns the subjects for which a rule's condition holds
+func evaluateRule(rule AlertRule, snapshot alertSnapshot, current time.Time) []alertCondition {
+	var conditions []alertCondition
+
+	switch rule.Kind {
+	case ruleUpstreamHealthy:
+		for _, upstream := range snapshot.upstreams {
+			if rule.UpstreamID != nil && *rule.UpstreamID != upstream.ID {
+				continue
+			}
+			total, healthy, probed := 0, 0, 0
+			for _, server := range snapshot.servers {
+				if server.UpstreamID != upstream.ID {
+					continue
+				}
+				total++
+				if server.Health != "" {
+					probed++
+				}
+				if server.Health == healthHealthy {
+					healthy++
+				}
+			}
+			// Wait for the first probe of every server
+			if probed < total || float64(healthy) >= rule.Threshold {
+				continue
+			}
+			conditions = append(conditions, alertCondition{
+				subject: "upstream " + upstream.Name,
+				message: fmt.Sprintf("%d of %d servers healthy, expected at least %g", healthy, total, rule.Threshold),
+				value:   float64(healthy),
+			})
+		}
+
+	case ruleErrorRate:
+		window, _ := time.ParseDuration(rule.Window)
+		from, to := windowRange(window)
+		analytics.mu.Lock()
+		for _, host := range snapshot.hosts {
+			if rule.ProxyHostID != nil && *rule.ProxyHostID != host.ID {
+				continue
+			}
+			stats := analytics.hosts[host.ID].sum(from, to).stats(window)
+			rate := stats.ErrorRate * 100
+			if stats.Requests < alertMinRequests || rate <= rule.Threshold {
+				continue
+			}
+			conditions = append(conditions, alertCondition{
+				subject: "proxy host " + strconv.Itoa(host.ID) + " (" + strings.Join(host.DomainNames, ", ") + ")",
+				message: fmt.Sprintf("%.1f%% of %d requests failed with 5xx in the last %s, threshold %g%%", rate, stats.Requests, rule.Window, rule.Threshold),
+				value:   rate,
+			})
+		}
+		analytics.mu.Unlock()
+
+	case ruleCertificateExpiring:
+		for _, cert := range snapshot.certificates {
+			if rule.CertificateID != nil && *rule.CertificateID != cert.ID {
+				continue
+			}
+			expiresAt, err := time.Parse(time.RFC3339, cert.ExpiresAt)
+			if err != nil {
+				continue
+			}
+			days := int(expiresAt.Sub(current).Hours() / 24)
+			if float64(days) > rule.Threshold {
+				continue
+			}
+			message := fmt.Sprintf("expires in %d days on %s", days, cert.ExpiresAt)
+			if days < 0 {
+				message = "expired on " + cert.ExpiresAt
+			}
+			conditions = append(conditions, alertCondition{
+				subject: "certificate " + strconv.Itoa(cert.ID) + " (" + cert.DomainName + ")",
+				message: message,
+				value:   float64(days),
+			})
+		}
+
+	case ruleConfigDrift:
+		if drifted := configDrift(snapshot.files); len(drifted) > 0 {
+			conditions = append(conditions, alertCondition{
+				subject: "nginx configuration",
+				message: "differs from the generated configuration: " + strings.Join(drifted, ", "),
+				value:   float64(len(drifted)),
+			})
+		}
+	}
+	return conditions
+}
+
+// startAlerts evaluates alert rules in the background
+func startAlerts() {
+	go func() {
+		ticker := time.NewTicker(alertInterval)
+		defer ticker.Stop()
+		for current := range ticker.C {
+			alertTick(current)
+		}
+	}()
+}
+
+// alertDelivery is a notification waiting to be sent
+type alertDelivery struct {
+	alertID      int
+	notifier     Notifier
+	notification Notification
+}
+
+// alertTick evaluates every rule, fires and resolves alerts and sends their
+// notifications. Notifications are sent without holding store.mu.
+func alertTick(current time.Time) {
+	store.mu.RLock()
+	rules := store.alertRules.all()
+	snapshot := takeAlertSnapshot()
+	store.mu.RUnlock()
+
+	holding := map[int][]alertCondition{}
+	for _, rule := range rules {
+		if rule.Enabled {
+			holding[rule.ID] = evaluateRule(rule, snapshot, current)
+		}
+	}
+
+	store.mu.Lock()
+	deliveries := reconcileAlerts(rules, holding, current)
+	store.mu.Unlock()
+
+	records := make([]AlertNotification, len(deliveries))
+	for i, delivery := range deliveries {
+		records[i] = AlertNotification{
+			NotifierID: delivery.notifier.ID,
+			Status:     delivery.notification.Status,
+			At:         now(),
+		}
+		if err := newNotifier(delivery.notifier).notify(delivery.notification); err != nil {
+			log.Printf("alerts: notifier %d: %v", delivery.notifier.ID, err)
+			records[i].Error = err.Error()
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for i, delivery := range deliveries {
+		if alert, ok := store.alerts.get(delivery.alertID); ok {
+			alert.Notifications = append(alert.Notifications, records[i])
+			store.alerts.put(alert.ID, alert)
+		}
+	}
+}
+
+// reconcileAlerts fires alerts for new conditions and resolves alerts whose
+// condition cleared. An alert notifies once when it fires and once when it
+// resolves. Callers must hold store.mu.
+func reconcileAlerts(rules []AlertRule, holding map[int][]alertCondition, current time.Time) []alertDelivery {
+	firing := map[string]Alert{}
+	for _, alert := range store.alerts.all() {
+		if alert.Status == alertFiring {
+			firing[strconv.Itoa(alert.RuleID)+"/"+alert.Subject] = alert
+		} else if resolvedAt, err := time.Parse(time.RFC3339, alert.ResolvedAt); err == nil && current.Sub(resolvedAt) > alertRetention {
+			store.alerts.remove(alert.ID)
+		}
+	}
+
+	var deliveries []alertDelivery
+	notify := func(rule AlertRule, alert Alert) {
+		notification := Notification{
+			Status:     alert.Status,
+			AlertID:    alert.ID,
+			Rule:       rule.Name,
+			Subject:    alert.Subject,
+			Message:    alert.Message,
+			Value:      alert.Value,
+			StartedAt:  alert.StartedAt,
+			ResolvedAt: alert.ResolvedAt,
+		}
+		for _, id := range rule.NotifierIDs {
+			if n, ok := store.notifiers.get(id); ok {
+				deliveries = append(deliveries, alertDelivery{alertID: alert.ID, notifier: n, notification: notification})
+			}
+		}
+		publishEvent(topicAlerts, "alert."+alert.Status, alert)
+	}
+
+	for _, rule := range rules {
+		prefix := strconv.Itoa(rule.ID) + "/"
+		holds := map[string]bool{}
+		for _, condition := range holding[rule.ID] {
+			key := prefix + condition.subject
+			holds[key] = true
+
+			if alert, ok := firing[key]; ok {
+				alert.Message = condition.message
+				alert.Value = condition.value
+				store.alerts.put(alert.ID, alert)
+				continue
+			}
+
+			since, ok := alertPending[key]
+			if !ok {
+				since = current
+				alertPending[key] = since
+			}
+			wait, _ := time.ParseDuration(rule.For)
+			if current.Sub(since) < wait {
+				continue
+			}
+			delete(alertPending, key)
+
+			alert := Alert{
+				ID:            store.alerts.newID(),
+				RuleID:        rule.ID,
+				Subject:       condition.subject,
+				Status:        alertFiring,
+				Message:       condition.message,
+				Value:         condition.value,
+				StartedAt:     current.UTC().Format(time.RFC3339),
+				Notifications: []AlertNotification{},
+			}
+			store.alerts.put(alert.ID, alert)
+			notify(rule, alert)
+		}
+
+		for key := range alertPending {
+			if strings.HasPrefix(key, prefix) && !holds[key] {
+				delete(alertPending, key)
+			}
+		}
+		for key, alert := range firing {
+			if !strings.HasPrefix(key, prefix) || holds[key] {
+				continue
+			}
+			alert.Status = alertResolved
+			alert.ResolvedAt = current.UTC().Format(time.RFC3339)
+			store.alerts.put(alert.ID, alert)
+			notify(rule, alert)
+		}
+	}
+	return deliveries
+}
+
+// ListAlertRules godoc
+// @Summary      List alert rules
+// @Description  Get all alert rules
+// @Tags         alerts
+// @Produce      json
+// @Success      200 {array} AlertRule
+// @Router       /alert-rules [get]
+func ListAlertRules(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return c.JSON(store.alertRules.all())
+}
+
+// CreateAlertRule godoc
+// @Summary      Create an alert rule
+// @Description  Alert on too few healthy upstream servers, a high 5xx rate, expiring certificates or configuration drift. Rules are evaluated every 30 seconds.
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Param        rule body AlertRuleRequest true "Alert Rule"
+// @Success      201 {object} AlertRule
+// @Header       201 {string} ETag "Version of the alert rule"
+// @Failure      400 {object} ErrorResponse
+// @Router       /alert-rules [post]
+func CreateAlertRule(c *fiber.Ctx) error {
+	var req AlertRuleRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := validateAlertRule(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	rule := AlertRule{
+		ID:            store.alertRules.newID(),
+		Name:          req.Name,
+		Kind:          req.Kind,
+		UpstreamID:    req.UpstreamID,
+		ProxyHostID:   req.ProxyHostID,
+		CertificateID: req.CertificateID,
+		Threshold:     req.Threshold,
+		Window:        req.Window,
+		For:           req.For,
+		NotifierIDs:   req.NotifierIDs,
+		Enabled:       req.Enabled == nil || *req.Enabled,
+		CreatedAt:     now(),
+	}
+	rule = store.alertRules.put(rule.ID, rule)
+
+	setETag(c, rule.Version)
+	return c.Status(201).JSON(rule)
+}
+
+// UpdateAlertRule godoc
+// @Summary      Update an alert rule
+// @Description  Update an alert rule. Alerts that no longer match resolve on the next evaluation.
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Alert Rule ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        rule body AlertRuleRequest true "Updated Alert Rule"
+// @Success      200 {object} AlertRule
+// @Header       200 {string} ETag "Version of the alert rule"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /alert-rules/{id} [put]
+func UpdateAlertRule(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req AlertRuleRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	rule, ok := store.alertRules.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Alert rule not found")
+	}
+	if err := checkIfMatch(c, rule.Version); err != nil {
+		return respondStale(c, rule.Version, err)
+	}
+	if err := validateAlertRule(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	rule.Name = req.Name
+	rule.Kind = req.Kind
+	rule.UpstreamID = req.UpstreamID
+	rule.ProxyHostID = req.ProxyHostID
+	rule.CertificateID = req.CertificateID
+	rule.Threshold = req.Threshold
+	rule.Window = req.Window
+	rule.For = req.For
+	rule.NotifierIDs = req.NotifierIDs
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	rule = store.alertRules.put(rule.ID, rule)
+
+	setETag(c, rule.Version)
+	return c.JSON(rule)
+}
+
+// PatchAlertRule godoc
+// @Summary      Partially update an alert rule
+// @Description  Change some fields of an alert rule with a JSON merge patch (RFC 7396), for example {"enabled": false} to mute it
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Alert Rule ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        rule body AlertRuleRequest true "Fields to change"
+// @Success      200 {object} AlertRule
+// @Header       200 {string} ETag "Version of the alert rule"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /alert-rules/{id} [patch]
+func PatchAlertRule(c *fiber.Ctx) error {
+	return patchResource(c, store.alertRules, "Alert rule not found", alertRuleRequest, UpdateAlertRule)
+}
+
+// alertRuleRequest returns the request that would set up an alert rule as it is
+func alertRuleRequest(rule AlertRule) AlertRuleRequest {
+	return AlertRuleRequest{
+		Name:          rule.Name,
+		Kind:          rule.Kind,
+		UpstreamID:    rule.UpstreamID,
+		ProxyHostID:   rule.ProxyHostID,
+		CertificateID: rule.CertificateID,
+		Threshold:     rule.Threshold,
+		Window:        rule.Window,
+		For:           rule.For,
+		NotifierIDs:   rule.NotifierIDs,
+		Enabled:       &rule.Enabled,
+	}
+}
+
+// DeleteAlertRule godoc
+// @Summary      Delete an alert rule
+// @Description  Delete an alert rule and its alerts without sending resolve notifications
+// @Tags         alerts
+// @Produce      json
+// @Param        id path int true "Alert Rule ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /alert-rules/{id} [delete]
+func DeleteAlertRule(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	rule, ok := store.alertRules.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Alert rule not found")
+	}
+	if err := checkIfMatch(c, rule.Version); err != nil {
+		return respondStale(c, rule.Version, err)
+	}
+	store.alertRules.remove(id)
+	for _, alert := range store.alerts.all() {
+		if alert.RuleID == id {
+			store.alerts.remove(alert.ID)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Alert rule deleted successfully",
+		"id":      id,
+	})
+}
+
+// ListAlerts godoc
+// @Summary      List alerts
+// @Description  Get firing alerts and alerts resolved in the last 7 days, newest first
+// @Tags         alerts
+// @Produce      json
+// @Param        status query string false "Status filter (firing, resolved)"
+// @Success      200 {array} Alert
+// @Router       /alerts [get]
+func ListAlerts(c *fiber.Ctx) error {
+	status := c.Query("status")
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	alerts := []Alert{}
+	for _, alert := range slices.Backward(store.alerts.all()) {
+		if status == "" || alert.Status == status {
+			alerts = append(alerts, alert)
+		}
+	}
+	return c.JSON(alerts)
+}
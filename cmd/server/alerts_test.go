@@ -0,0 +1,68 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconcileAlerts(t *testing.T) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	notifierID := store.notifiers.newID()
+	store.notifiers.put(notifierID, Notifier{ID: notifierID, Name: "ops", Type: "webhook", URL: "http://127.0.0.1:1/"})
+	defer store.notifiers.remove(notifierID)
+
+	rule := AlertRule{ID: 9000, Name: "capacity", Kind: ruleUpstreamHealthy, For: "1m", NotifierIDs: []int{notifierID}}
+	holding := map[int][]alertCondition{rule.ID: {{subject: "upstream backend", message: "1 of 3 servers healthy", value: 1}}}
+	start := time.Date(2025, 12, 8, 10, 0, 0, 0, time.UTC)
+
+	ruleAlerts := func() []Alert {
+		var alerts []Alert
+		for _, alert := range store.alerts.all() {
+			if alert.RuleID == rule.ID {
+				alerts = append(alerts, alert)
+			}
+		}
+		return alerts
+	}
+	defer func() {
+		for _, alert := range ruleAlerts() {
+			store.alerts.remove(alert.ID)
+		}
+	}()
+
+	// The condition must hold for the rule's For duration before it fires
+	if deliveries := reconcileAlerts([]AlertRule{rule}, holding, start); len(deliveries) != 0 || len(ruleAlerts()) != 0 {
+		t.Fatalf("fired before For elapsed: %d deliveries, alerts %v", len(deliveries), ruleAlerts())
+	}
+
+	deliveries := reconcileAlerts([]AlertRule{rule}, holding, start.Add(time.Minute))
+	alerts := ruleAlerts()
+	if len(alerts) != 1 || alerts[0].Status != alertFiring || alerts[0].StartedAt != "2025-12-08T10:01:00Z" {
+		t.Fatalf("alerts after For = %+v", alerts)
+	}
+	if len(deliveries) != 1 || deliveries[0].notification.Status != alertFiring || deliveries[0].notifier.ID != notifierID {
+		t.Errorf("firing deliveries = %+v", deliveries)
+	}
+
+	// A firing alert only updates while the condition holds
+	if deliveries := reconcileAlerts([]AlertRule{rule}, holding, start.Add(2*time.Minute)); len(deliveries) != 0 || len(ruleAlerts()) != 1 {
+		t.Errorf("still firing: %d deliveries, alerts %v", len(deliveries), ruleAlerts())
+	}
+
+	deliveries = reconcileAlerts([]AlertRule{rule}, nil, start.Add(3*time.Minute))
+	alerts = ruleAlerts()
+	if len(alerts) != 1 || alerts[0].Status != alertResolved || alerts[0].ResolvedAt != "2025-12-08T10:03:00Z" {
+		t.Fatalf("alerts after clearing = %+v", alerts)
+	}
+	if len(deliveries) != 1 || deliveries[0].notification.Status != alertResolved {
+		t.Errorf("resolved deliveries = %+v", deliveries)
+	}
+
+	// Resolved alerts are dropped after the retention period
+	reconcileAlerts([]AlertRule{rule}, nil, start.Add(3*time.Minute+alertRetention+time.Second))
+	if alerts := ruleAlerts(); len(alerts) != 0 {
+		t.Errorf("alerts after retention = %+v", alerts)
+	}
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// accessLogFormat names the JSON log format every proxy host logs with
+const accessLogFormat = "bs_json"
+
+// analyticsInterval is how often access logs are read
+const analyticsInterval = 5 * time.Second
+
+// analyticsRetention is how long per-minute traffic buckets are kept
+const analyticsRetention = 24 * time.Hour
+
+// latencyBounds are the upper bounds in milliseconds of the latency
+// histogram. Percentiles are reported as the bound of the bucket they fall in.
+var latencyBounds = []float64{1, 2, 5, 10, 25, 50, 75, 100, 150, 200, 300, 500, 750, 1000, 1500, 2000, 3000, 5000, 10000, 30000, 60000}
+
+// accessLogFields are written by the bs_json log format. Every value is a
+// string because nginx logs "-" or comma separated lists for some of them.
+var accessLogFields = [][2]string{
+	{"time", "$msec"},
+	{"host", "$host"},
+	{"remote_addr", "$remote_addr"},
+	{"method", "$request_method"},
+	{"uri", "$request_uri"},
+	{"status", "$status"},
+	{"bytes_sent", "$bytes_sent"},
+	{"request_time", "$request_time"},
+	{"upstream_addr", "$upstream_addr"},
+	{"upstream_status", "$upstream_status"},
+	{"upstream_response_time", "$upstream_response_time"},
+	{"user_agent", "$http_user_agent"},
+	{"referer", "$http_referer"},
+}
+
+// accessLogEntry is one line of a bs_json access log
+type accessLogEntry struct {
+	Time                 string `json:"time"`
+	Host                 string `json:"host"`
+	RemoteAddr           string `json:"remote_addr"`
+	Method               string `json:"method"`
+	URI                  string `json:"uri"`
+	Status               string `json:"status"`
+	BytesSent            string `json:"bytes_sent"`
+	RequestTime          string `json:"request_time"`
+	UpstreamAddr         string `json:"upstream_addr"`
+	UpstreamStatus       string `json:"upstream_status"`
+	UpstreamResponseTime string `json:"upstream_response_time"`
+	UserAgent            string `json:"user_agent"`
+	Referer              string `json:"referer"`
+}
+
+// TrafficStats aggregates requests over a time window
+type TrafficStats struct {
+	Requests          int64            `json:"requests" example:"1200"`
+	RequestsPerSecond float64          `json:"requests_per_second" example:"0.33"`
+	BytesSent         int64            `json:"bytes_sent" example:"5242880"`
+	StatusClasses     map[string]int64 `json:"status_classes"`
+	ErrorRate         float64          `json:"error_rate" example:"0.01"`
+	LatencyMs         LatencyStats     `json:"latency_ms"`
+}
+
+// LatencyStats are request latencies in milliseconds
+type LatencyStats struct {
+	Avg float64 `json:"avg" example:"42.5"`
+	P50 float64 `json:"p50" example:"25"`
+	P90 float64 `json:"p90" example:"100"`
+	P95 float64 `json:"p95" example:"150"`
+	P99 float64 `json:"p99" example:"500"`
+}
+
+// HostTraffic is the traffic of one proxy host
+type HostTraffic struct {
+	ProxyHostID int      `json:"proxy_host_id" example:"1"`
+	DomainNames []string `json:"domain_names" example:"example.com"`
+	TrafficStats
+}
+
+// HostTrafficDetail is the traffic of one proxy host with a time series
+type HostTrafficDetail struct {
+	HostTraffic
+	Window string         `json:"window" example:"1h0m0s"`
+	Step   string         `json:"step" example:"1m0s"`
+	Series []TrafficPoint `json:"series"`
+}
+
+// TrafficPoint is the traffic of one step of a time series
+type TrafficPoint struct {
+	Time string `json:"time" example:"2025-12-08T10:00:00Z"`
+	TrafficStats
+}
+
+// ServerTraffic is the traffic nginx sent to one upstream server address
+type ServerTraffic struct {
+	Address    string `json:"address" example:"192.168.1.100:8080"`
+	UpstreamID *int   `json:"upstream_id,omitempty" example:"1"`
+	ServerID   *int   `json:"server_id,omitempty" example:"1"`
+	TrafficStats
+}
+
+// trafficBucket holds the traffic of one minute
+type trafficBucket struct {
+	requests   int64
+	bytes      int64
+	status     [5]int64
+	latencySum float64
+	latency    []int64
+}
+
+func newTrafficBucket() *trafficBucket {
+	return &trafficBucket{latency: make([]int64, len(latencyBounds)+1)}
+}
+
+func (b *trafficBucket) add(status int, bytes int64, latencyMs float64) {
+	b.requests++
+	b.bytes += bytes
+	if status >= 100 && status < 600 {
+		b.status[status/100-1]++
+	}
+	b.latencySum += latencyMs
+	i, _ := slices.BinarySearch(latencyBounds, latencyMs)
+	b.latency[i]++
+}
+
+func (b *trafficBucket) merge(other *trafficBucket) {
+	b.requests += other.requests
+	b.bytes += other.bytes
+	for i := range b.status {
+		b.status[i] += other.status[i]
+	}
+	b.latencySum += other.latencySum
+	for i := range b.latency {
+		b.latency[i] += other.latency[i]
+	}
+}
+
+// percentile estimates a latency percentile from the histogram
+func (b *trafficBucket) percentile(p float64) float64 {
+	if b.requests == 0 {
+		return 0
+	}
+	target := int64(p * float64(b.requests))
+	var seen int64
+	for i, count := range b.latency {
+		seen += count
+		if seen > target || seen == b.requests {
+			if i == len(latencyBounds) {
+				return latencyBounds[len(latencyBounds)-1]
+			}
+			return latencyBounds[i]
+		}
+	}
+	return latencyBounds[len(latencyBounds)-1]
+}
+
+func (b *trafficBucket) stats(window time.Duration) TrafficStats {
+	stats := TrafficStats{
+		Requests:      b.requests,
+		BytesSent:     b.bytes,
+		StatusClasses: map[string]int64{},
+	}
+	for i, count := range b.status {
+		stats.StatusClasses[strconv.Itoa(i+1)+"xx"] = count
+	}
+	if b.requests > 0 {
+		stats.RequestsPerSecond = float64(b.requests) / window.Seconds()
+		stats.ErrorRate = float64(b.status[4]) / float64(b.requests)
+		stats.LatencyMs = LatencyStats{
+			Avg: b.latencySum / float64(b.requests),
+			P50: b.percentile(0.50),
+			P90: b.percentile(0.90),
+			P95: b.percentile(0.95),
+			P99: b.percentile(0.99),
+		}
+	}
+	return stats
+}
+
+// trafficSeries is per-minute traffic keyed by the unix minute
+type trafficSeries map[int64]*trafficBucket
+
+func (s trafficSeries) bucket(minute int64) *trafficBucket {
+	b, ok := s[minute]
+	if !ok {
+		b = newTrafficBucket()
+		s[minute] = b
+	}
+	return b
+}
+
+// sum merges the buckets in [from, to)
+func (s trafficSeries) sum(from, to int64) *trafficBucket {
+	total := newTrafficBucket()
+	for minute, b := range s {
+		if minute >= from && minute < to {
+			total.merge(b)
+		}
+	}
+	return total
+}
+
+// logTail remembers how far a log has been read
+type logTail struct {
+	offset int64
+	info   os.FileInfo
+}
+
+// analyticsStore aggregates access logs. It has its own lock so reading logs
+// never blocks API requests that hold store.mu.
+type analyticsStore struct {
+	mu      sync.Mutex
+	hosts   map[int]trafficSeries
+	servers map[string]trafficSeries
+	tails   map[string]*logTail
+}
+
+var analytics = &analyticsStore{
+	hosts:   map[int]trafficSeries{},
+	servers: map[string]trafficSeries{},
+	tails:   map[string]*logTail{},
+}
+
+// accessLogDir returns the directory proxy host logs are written to
+func accessLogDir() string {
+	return filepath.Join(nginxController.Config().DataPath, "logs")
+}
+
+// accessLogPath returns the JSON access log of a proxy host
+func accessLogPath(host ProxyHost) string {
+	return filepath.Join(accessLogDir(), hostZone(host)+".access.log")
+}
+
+// renderLogFormat renders the http-context JSON log format
+func renderLogFormat() nginx.Directive {
+	parts := make([]string, 0, len(accessLogFields))
+	for _, field := range accessLogFields {
+		parts = append(parts, fmt.Sprintf(`"%s":"%s"`, field[0], field[1]))
+	}
+	return nginx.Simple("log_format", accessLogFormat, "escape=json", "{"+strings.Join(parts, ",")+"}")
+}
+
+// errorLogPath returns the error log of a proxy host
+func errorLogPath(host ProxyHost) string {
+	return filepath.Join(accessLogDir(), hostZone(host)+".error.log")
+}
+
+// renderHostLogs renders the access and error logs of a proxy host server block
+func renderHostLogs(host ProxyHost) []nginx.Directive {
+	return []nginx.Directive{
+		nginx.Simple("access_log", accessLogPath(host), accessLogFormat),
+		nginx.Simple("error_log", errorLogPath(host), "warn"),
+	}
+}
+
+// startAnalytics reads the access logs of proxy hosts in the background
+func startAnalytics() {
+	go func() {
+		ticker := time.NewTicker(analyticsInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			analytics.collect()
+		}
+	}()
+}
+
+// collect reads new lines from every proxy host access log
+func (a *analyticsStore) collect() {
+	store.mu.RLock()
+	logs := map[string]int{}
+	for _, host := range store.proxyHosts.all() {
+		if host.Enabled {
+			logs[accessLogPath(host)] = host.ID
+		}
+	}
+	store.mu.RUnlock()
+
+	for path, hostID := range logs {
+		err := a.tail(path, func(entry accessLogEntry) {
+			a.ingest(hostID, entry)
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("analytics: %v", err)
+		}
+	}
+	a.prune(time.Now().Add(-analyticsRetention))
+}
+
+// tail passes access log entries appended to a log since the last call to
+// handle
+func (a *analyticsStore) tail(path string, handle func(accessLogEntry)) error {
+	a.mu.Lock()
+	state, ok := a.tails[path]
+	if !ok {
+		state = &logTail{}
+		a.tails[path] = state
+	}
+	a.mu.Unlock()
+
+	// Only the collect goroutine reads through state
+	return state.read(path, func(line []byte) {
+		var entry accessLogEntry
+		if json.Unmarshal(line, &entry) == nil {
+			handle(entry)
+		}
+	})
+}
+
+// newLogTailAtEnd returns a tail that skips what a log already contains
+func newLogTailAtEnd(path string) *logTail {
+	state := &logTail{}
+	if info, err := os.Stat(path); err == nil {
+		state.info = info
+		state.offset = info.Size()
+	}
+	return state
+}
+
+// read passes complete lines appended to a log since the last call to
+// handle. Rotated and truncated logs are read from the start.
+func (t *logTail) read(path string, handle func(line []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if t.info != nil && (!os.SameFile(t.info, info) || info.Size() < t.offset) {
+		t.offset = 0
+	}
+	t.info = info
+
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// A partial line is read again once nginx finishes writing it
+			break
+		}
+		t.offset += int64(len(line))
+		handle(line)
+	}
+	return nil
+}
+
+// ingest adds one request to the host and upstream server series
+func (a *analyticsStore) ingest(hostID int, entry accessLogEntry) {
+	seconds, err := strconv.ParseFloat(entry.Time, 64)
+	if err != nil {
+		return
+	}
+	at := time.Unix(int64(seconds), 0)
+	if time.Since(at) > analyticsRetention {
+		return
+	}
+	minute := at.Unix() / 60
+
+	status, _ := strconv.Atoi(entry.Status)
+	bytes, _ := strconv.ParseInt(entry.BytesSent, 10, 64)
+	requestTime, _ := strconv.ParseFloat(entry.RequestTime, 64)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	series, ok := a.hosts[hostID]
+	if !ok {
+		series = trafficSeries{}
+		a.hosts[hostID] = series
+	}
+	series.bucket(minute).add(status, bytes, requestTime*1000)
+
+	// nginx lists every server it tried, separated by ", ", and groups
+	// internal redirects with " : "
+	addrs := splitUpstreamList(entry.UpstreamAddr)
+	statuses := splitUpstreamList(entry.UpstreamStatus)
+	times := splitUpstreamList(entry.UpstreamResponseTime)
+	for i, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			continue
+		}
+		var serverStatus int
+		var serverTime float64
+		if i < len(statuses) {
+			serverStatus, _ = strconv.Atoi(statuses[i])
+		}
+		if i < len(times) {
+			serverTime, _ = strconv.ParseFloat(times[i], 64)
+		}
+		var serverBytes int64
+		if i == len(addrs)-1 {
+			serverBytes = bytes
+		}
+
+		series, ok := a.servers[addr]
+		if !ok {
+			series = trafficSeries{}
+			a.servers[addr] = series
+		}
+		series.bucket(minute).add(serverStatus, serverBytes, serverTime*1000)
+	}
+}
+
+// splitUpstreamList splits $upstream_* values into one value per attempt
+func splitUpstreamList(value string) []string {
+	var values []string
+	for _, group := range strings.Split(value, " : ") {
+		for _, v := range strings.Split(group, ", ") {
+			if v = strings.TrimSpace(v); v != "" && v != "-" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// prune drops buckets older than cutoff
+func (a *analyticsStore) prune(cutoff time.Time) {
+	oldest := cutoff.Unix() / 60
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pruneSeries(a.hosts, oldest)
+	pruneSeries(a.servers, oldest)
+}
+
+// pruneSeries drops buckets before the oldest minute and empty series
+func pruneSeries[K comparable](all map[K]trafficSeries, oldest int64) {
+	for key, series := range all {
+		for minute := range series {
+			if minute < oldest {
+				delete(series, minute)
+			}
+		}
+		if len(series) == 0 {
+			delete(all, key)
+		}
+	}
+}
+
+// windowRange returns the unix minutes [from, to) covering the window
+func windowRange(window time.Duration) (int64, int64) {
+	to := time.Now().Unix()/60 + 1
+	return to - int64(window/time.Minute), to
+}
+
+// parseWindow reads a duration query parameter between one minute and the retention
+func parseWindow(c *fiber.Ctx, name, fallback string) (time.Duration, error) {
+	window, err := time.ParseDuration(c.Query(name, fallback))
+	if err != nil || window < time.Minute || window > analyticsRetention {
+		return 0, fmt.Errorf("%s must be a duration between 1m and %s", name, analyticsRetention)
+	}
+	return window.Truncate(time.Minute), nil
+}
+
+// ListHostTraffic godoc
+// @Summary      Traffic per proxy host
+// @Description  Requests, status code classes, bytes and latency percentiles per proxy host over a time window
+// @Tags         analytics
+// @Produce      json
+// @Param        window query string false "Time window, for example 15m or 1h (default 1h, max 24h)"
+// @Success      200 {array} HostTraffic
+// @Failure      400 {object} ErrorResponse
+// @Router       /analytics/proxy-hosts [get]
+func ListHostTraffic(c *fiber.Ctx) error {
+	window, err := parseWindow(c, "window", "1h")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	from, to := windowRange(window)
+
+	store.mu.RLock()
+	hosts := store.proxyHosts.all()
+	store.mu.RUnlock()
+
+	analytics.mu.Lock()
+	defer analytics.mu.Unlock()
+
+	traffic := []HostTraffic{}
+	for _, host := range hosts {
+		total := analytics.hosts[host.ID].sum(from, to)
+		traffic = append(traffic, HostTraffic{
+			ProxyHostID:  host.ID,
+			DomainNames:  host.DomainNames,
+			TrafficStats: total.stats(window),
+		})
+	}
+	return c.JSON(traffic)
+}
+
+// GetHostTraffic godoc
+// @Summary      Traffic of a proxy host
+// @Description  Traffic totals of a proxy host and a time series with one point per step
+// @Tags         analytics
+// @Produce      json
+// @Param        id path int true "Proxy Host ID"
+// @Param        window query string false "Time window (default 1h, max 24h)"
+// @Param        step query string false "Series step (default 1m)"
+// @Success      200 {object} HostTrafficDetail
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Router       /analytics/proxy-hosts/{id} [get]
+func GetHostTraffic(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	window, err := parseWindow(c, "window", "1h")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	step, err := parseWindow(c, "step", "1m")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	if step > window {
+		return respondError(c, 400, "Invalid request", "step must not be longer than window")
+	}
+	from, to := windowRange(window)
+	stepMinutes := int64(step / time.Minute)
+
+	store.mu.RLock()
+	host, ok := store.proxyHosts.get(id)
+	store.mu.RUnlock()
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+
+	analytics.mu.Lock()
+	defer analytics.mu.Unlock()
+
+	series := analytics.hosts[id]
+	detail := HostTrafficDetail{
+		HostTraffic: HostTraffic{
+			ProxyHostID:  host.ID,
+			DomainNames:  host.DomainNames,
+			TrafficStats: series.sum(from, to).stats(window),
+		},
+		Window: window.String(),
+		Step:   step.String(),
+		Series: []TrafficPoint{},
+	}
+	for start := from; start < to; start += stepMinutes {
+		end := min(start+stepMinutes, to)
+		detail.Series = append(detail.Series, TrafficPoint{
+			Time:         time.Unix(start*60, 0).UTC().Format(time.RFC3339),
+			TrafficStats: series.sum(start, end).stats(time.Duration(end-start) * time.Minute),
+		})
+	}
+	return c.JSON(detail)
+}
+
+// ListServerTraffic godoc
+// @Summary      Traffic per upstream server
+// @Description  Requests, status code classes, bytes and response time percentiles per upstream server address over a time window
+// @Tags         analytics
+// @Produce      json
+// @Param        window query string false "Time window (default 1h, max 24h)"
+// @Success      200 {array} ServerTraffic
+// @Failure      400 {object} ErrorResponse
+// @Router       /analytics/upstream-servers [get]
+func ListServerTraffic(c *fiber.Ctx) error {
+	window, err := parseWindow(c, "window", "1h")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	from, to := windowRange(window)
+
+	store.mu.RLock()
+	servers := map[string]UpstreamServer{}
+	for _, server := range store.upstreamServers.all() {
+		servers[net.JoinHostPort(server.Host, strconv.Itoa(server.Port))] = server
+	}
+	store.mu.RUnlock()
+
+	analytics.mu.Lock()
+	defer analytics.mu.Unlock()
+
+	traffic := []ServerTraffic{}
+	for _, addr := range slices.Sorted(maps.Keys(analytics.servers)) {
+		total := analytics.servers[addr].sum(from, to)
+		if total.requests == 0 {
+			continue
+		}
+		entry := ServerTraffic{Address: addr, TrafficStats: total.stats(window)}
+		if server, ok := servers[addr]; ok {
+			entry.UpstreamID = &server.UpstreamID
+			entry.ServerID = &server.ID
+		}
+		traffic = append(traffic, entry)
+	}
+	return c.JSON(traffic)
+}
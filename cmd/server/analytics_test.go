@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestTrafficBucketPercentile(t *testing.T) {
+	bucket := newTrafficBucket()
+	for i := 0; i < 50; i++ {
+		bucket.add(200, 100, 3)
+	}
+	for i := 0; i < 40; i++ {
+		bucket.add(200, 100, 40)
+	}
+	for i := 0; i < 10; i++ {
+		bucket.add(502, 100, 900)
+	}
+
+	// Latencies report the upper bound of their histogram bucket
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0.10, 5},
+		{0.50, 50},
+		{0.89, 50},
+		{0.90, 1000},
+		{0.99, 1000},
+	}
+	for _, tt := range tests {
+		if got := bucket.percentile(tt.p); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+
+	if got := newTrafficBucket().percentile(0.5); got != 0 {
+		t.Errorf("empty percentile = %v, want 0", got)
+	}
+
+	slow := newTrafficBucket()
+	slow.add(200, 0, 120000)
+	if got := slow.percentile(0.99); got != latencyBounds[len(latencyBounds)-1] {
+		t.Errorf("percentile above the last bound = %v", got)
+	}
+}
+
+func TestTrafficBucketStats(t *testing.T) {
+	bucket := newTrafficBucket()
+	bucket.add(200, 300, 10)
+	other := newTrafficBucket()
+	other.add(503, 100, 30)
+	bucket.merge(other)
+
+	stats := bucket.stats(60e9)
+	if stats.Requests != 2 || stats.BytesSent != 400 {
+		t.Errorf("requests = %d, bytes = %d", stats.Requests, stats.BytesSent)
+	}
+	if stats.StatusClasses["2xx"] != 1 || stats.StatusClasses["5xx"] != 1 {
+		t.Errorf("status classes = %v", stats.StatusClasses)
+	}
+	if stats.ErrorRate != 0.5 || stats.LatencyMs.Avg != 20 {
+		t.Errorf("error rate = %v, average latency = %v", stats.ErrorRate, stats.LatencyMs.Avg)
+	}
+}
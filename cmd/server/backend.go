@@ -0,0 +1,267 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+// Backend schemes
+const (
+	schemeHTTP  = "http"
+	schemeHTTPS = "https"
+	schemeGRPC  = "grpc"
+	schemeGRPCS = "grpcs"
+)
+
+// connectionUpgradeVariable closes the backend connection unless the client
+// asked for a protocol upgrade
+const connectionUpgradeVariable = "$bs_connection_upgrade"
+
+// grpcDirectiveNames maps proxy module directives to their grpc module equivalents
+var grpcDirectiveNames = map[string]string{
+	"proxy_pass":        "grpc_pass",
+	"proxy_set_header":  "grpc_set_header",
+	"proxy_hide_header": "grpc_hide_header",
+}
+
+// BackendOptions controls how a proxy host talks to its backend
+type BackendOptions struct {
+	Scheme    string `json:"scheme,omitempty" example:"https"`
+	WebSocket bool   `json:"websocket,omitempty" example:"false"`
+	// HTTP2 serves HTTP/2 on the listener even when the TLS profile does not.
+	// gRPC backends always enable it.
+	HTTP2 bool `json:"http2,omitempty" example:"true"`
+	// SNI is the server name sent to https and grpcs backends
+	SNI         string `json:"sni,omitempty" example:"internal.example.com"`
+	Verify      bool   `json:"verify,omitempty" example:"true"`
+	CABundleID  *int   `json:"ca_bundle_id,omitempty" example:"1"`
+	VerifyDepth int    `json:"verify_depth,omitempty" example:"2"`
+
+	ConnectTimeout string `json:"connect_timeout,omitempty" example:"5s"`
+	ReadTimeout    string `json:"read_timeout,omitempty" example:"60s"`
+	SendTimeout    string `json:"send_timeout,omitempty" example:"60s"`
+
+	Buffering       *bool         `json:"buffering,omitempty" example:"true"`
+	BufferSize      string        `json:"buffer_size,omitempty" example:"8k"`
+	Buffers         *ProxyBuffers `json:"buffers,omitempty"`
+	BusyBuffersSize string        `json:"busy_buffers_size,omitempty" example:"16k"`
+	MaxBodySize     string        `json:"max_body_size,omitempty" example:"50m"`
+}
+
+// ProxyBuffers sets the number and size of response buffers per connection
+type ProxyBuffers struct {
+	Number int    `json:"number" example:"8"`
+	Size   string `json:"size" example:"8k"`
+}
+
+// validateBackend normalizes backend options of a proxy host.
+// Callers must hold store.mu.
+func validateBackend(backend *BackendOptions, cache *CacheConfig) error {
+	if backend == nil {
+		return nil
+	}
+	var errs fieldErrors
+
+	if backend.Scheme == "" {
+		backend.Scheme = schemeHTTP
+	}
+	switch backend.Scheme {
+	case schemeHTTP, schemeHTTPS, schemeGRPC, schemeGRPCS:
+	default:
+		errs.add("scheme", "scheme must be one of %q, %q, %q or %q", schemeHTTP, schemeHTTPS, schemeGRPC, schemeGRPCS)
+	}
+
+	grpc := isGRPC(backend)
+	if grpc && backend.WebSocket {
+		errs.add("websocket", "websocket is not available for gRPC backends")
+	}
+	if grpc && cache != nil {
+		errs.add("scheme", "scheme %q does not support caching", backend.Scheme)
+	}
+	if grpc && (backend.Buffering != nil || backend.Buffers != nil || backend.BusyBuffersSize != "") {
+		errs.add("buffering", "buffering, buffers and busy_buffers_size are not available for gRPC backends")
+	}
+
+	if !isTLSBackend(backend) {
+		for _, field := range []struct {
+			name string
+			set  bool
+		}{{"sni", backend.SNI != ""}, {"verify", backend.Verify}, {"ca_bundle_id", backend.CABundleID != nil}} {
+			if field.set {
+				errs.add(field.name, "%s requires the %q or %q scheme", field.name, schemeHTTPS, schemeGRPCS)
+			}
+		}
+	}
+	if backend.SNI != "" && strings.ContainsAny(backend.SNI, " \t\r\n;{}") {
+		errs.add("sni", "sni %q is not a valid server name", backend.SNI)
+	}
+	if backend.Verify && backend.CABundleID == nil {
+		errs.add("ca_bundle_id", "ca_bundle_id is required by verify")
+	}
+	if backend.CABundleID != nil {
+		if _, ok := store.caBundles.get(*backend.CABundleID); !ok {
+			errs.add("ca_bundle_id", "ca_bundle_id: CA bundle %d does not exist", *backend.CABundleID)
+		}
+	}
+	if backend.VerifyDepth < 0 {
+		errs.add("verify_depth", "verify_depth must not be negative")
+	}
+	if backend.VerifyDepth > 0 && !backend.Verify {
+		errs.add("verify_depth", "verify_depth requires verify")
+	}
+
+	for _, field := range [][2]string{
+		{"connect_timeout", backend.ConnectTimeout},
+		{"read_timeout", backend.ReadTimeout},
+		{"send_timeout", backend.SendTimeout},
+	} {
+		if field[1] != "" && !nginxTimePattern.MatchString(field[1]) {
+			errs.add(field[0], "%s must be an nginx time such as 60s", field[0])
+		}
+	}
+	for _, field := range [][2]string{
+		{"buffer_size", backend.BufferSize},
+		{"busy_buffers_size", backend.BusyBuffersSize},
+		{"max_body_size", backend.MaxBodySize},
+	} {
+		if field[1] != "" && !nginxSizePattern.MatchString(field[1]) {
+			errs.add(field[0], "%s must be an nginx size such as 8k", field[0])
+		}
+	}
+	if buffers := backend.Buffers; buffers != nil {
+		if buffers.Number <= 0 {
+			errs.add("buffers.number", "buffers.number must be positive")
+		}
+		if !nginxSizePattern.MatchString(buffers.Size) {
+			errs.add("buffers.size", "buffers.size must be an nginx size such as 8k")
+		}
+	}
+
+	return errs.err()
+}
+
+// isGRPC reports whether the backend is reached through the grpc module
+func isGRPC(backend *BackendOptions) bool {
+	return backend != nil && (backend.Scheme == schemeGRPC || backend.Scheme == schemeGRPCS)
+}
+
+// isTLSBackend reports whether the backend is reached over TLS
+func isTLSBackend(backend *BackendOptions) bool {
+	return backend != nil && (backend.Scheme == schemeHTTPS || backend.Scheme == schemeGRPCS)
+}
+
+// backendScheme returns the scheme of the proxy_pass or grpc_pass URL
+func backendScheme(backend *BackendOptions) string {
+	if backend == nil {
+		return schemeHTTP
+	}
+	return backend.Scheme
+}
+
+// hostHTTP2 reports whether the listener of a proxy host serves HTTP/2
+func hostHTTP2(host ProxyHost) bool {
+	return host.Backend != nil && (host.Backend.HTTP2 || isGRPC(host.Backend))
+}
+
+// usesWebSocket reports whether any enabled proxy host proxies WebSockets
+func usesWebSocket() bool {
+	for _, host := range store.proxyHosts.all() {
+		if host.Enabled && host.Backend != nil && host.Backend.WebSocket {
+			return true
+		}
+	}
+	return false
+}
+
+// renderConnectionUpgradeMap renders the map shared by WebSocket locations
+func renderConnectionUpgradeMap() nginx.Directive {
+	return nginx.NewBlock("map", []string{"$http_upgrade", connectionUpgradeVariable},
+		nginx.Simple("default", "upgrade"),
+		nginx.Simple(`""`, "close"),
+	)
+}
+
+// renderBackend renders the server-level backend directives of a proxy host.
+// Locations inherit them.
+func renderBackend(host ProxyHost) []nginx.Directive {
+	backend := host.Backend
+	if backend == nil {
+		return nil
+	}
+
+	prefix := "proxy_"
+	if isGRPC(backend) {
+		prefix = "grpc_"
+	}
+
+	var directives []nginx.Directive
+	add := func(name string, args ...string) {
+		directives = append(directives, nginx.Simple(prefix+name, args...))
+	}
+
+	if isTLSBackend(backend) {
+		add("ssl_server_name", "on")
+		if backend.SNI != "" {
+			add("ssl_name", backend.SNI)
+		}
+		if backend.Verify {
+			add("ssl_verify", "on")
+			add("ssl_trusted_certificate", caBundlePath(*backend.CABundleID))
+			if backend.VerifyDepth > 0 {
+				add("ssl_verify_depth", strconv.Itoa(backend.VerifyDepth))
+			}
+		}
+	}
+
+	if backend.ConnectTimeout != "" {
+		add("connect_timeout", backend.ConnectTimeout)
+	}
+	if backend.ReadTimeout != "" {
+		add("read_timeout", backend.ReadTimeout)
+	}
+	if backend.SendTimeout != "" {
+		add("send_timeout", backend.SendTimeout)
+	}
+
+	if backend.Buffering != nil {
+		add("buffering", onOff(*backend.Buffering))
+	}
+	if backend.BufferSize != "" {
+		add("buffer_size", backend.BufferSize)
+	}
+	if backend.Buffers != nil {
+		add("buffers", strconv.Itoa(backend.Buffers.Number), backend.Buffers.Size)
+	}
+	if backend.BusyBuffersSize != "" {
+		add("busy_buffers_size", backend.BusyBuffersSize)
+	}
+	if backend.MaxBodySize != "" {
+		directives = append(directives, nginx.Simple("client_max_body_size", backend.MaxBodySize))
+	}
+	return directives
+}
+
+// renderWebSocket renders the location directives that pass protocol
+// upgrades through to the backend
+func renderWebSocket(host ProxyHost) []nginx.Directive {
+	if host.Backend == nil || !host.Backend.WebSocket {
+		return nil
+	}
+	return []nginx.Directive{
+		nginx.Simple("proxy_http_version", "1.1"),
+		nginx.Simple("proxy_set_header", "Upgrade", "$http_upgrade"),
+		nginx.Simple("proxy_set_header", "Connection", connectionUpgradeVariable),
+	}
+}
+
+// grpcLocation rewrites proxy module directives of a location for the grpc module
+func grpcLocation(directives []nginx.Directive) []nginx.Directive {
+	for i, d := range directives {
+		if name, ok := grpcDirectiveNames[d.Name]; ok {
+			directives[i].Name = name
+		}
+	}
+	return directives
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	colorBlue  = "blue"
+	colorGreen = "green"
+)
+
+// BlueGreen pairs two upstream groups a proxy host can switch between
+type BlueGreen struct {
+	BlueUpstreamID  int    `json:"blue_upstream_id" example:"1"`
+	GreenUpstreamID int    `json:"green_upstream_id" example:"2"`
+	Active          string `json:"active" example:"blue"`
+}
+
+// SwitchRequest represents the request body for a blue/green switch
+type SwitchRequest struct {
+	Target string `json:"target,omitempty" example:"green"`
+}
+
+// SwitchRecord is an entry in the blue/green switch history of a proxy host
+type SwitchRecord struct {
+	ID          int    `json:"id" example:"1"`
+	ProxyHostID int    `json:"proxy_host_id" example:"1"`
+	From        string `json:"from" example:"blue"`
+	To          string `json:"to" example:"green"`
+	Status      string `json:"status" example:"applied"`
+	Output      string `json:"output,omitempty" example:"nginx: configuration file /etc/nginx/nginx.conf test is successful"`
+	RevertOf    *int   `json:"revert_of,omitempty" example:"1"`
+	SwitchedAt  string `json:"switched_at" example:"2025-12-08T12:00:00Z"`
+}
+
+// activeUpstreamID returns the upstream of the active color
+func (bg *BlueGreen) activeUpstreamID() int {
+	if bg.Active == colorGreen {
+		return bg.GreenUpstreamID
+	}
+	return bg.BlueUpstreamID
+}
+
+// upstreamID returns the upstream configured for a color
+func (bg *BlueGreen) upstreamID(color string) int {
+	if color == colorGreen {
+		return bg.GreenUpstreamID
+	}
+	return bg.BlueUpstreamID
+}
+
+// otherColor returns the color that is not active
+func otherColor(color string) string {
+	if color == colorGreen {
+		return colorBlue
+	}
+	return colorGreen
+}
+
+// validateBlueGreen normalizes and checks a blue/green definition.
+// Callers must hold store.mu.
+func validateBlueGreen(bg *BlueGreen) error {
+	if bg == nil {
+		return nil
+	}
+	var errs fieldErrors
+	if bg.Active == "" {
+		bg.Active = colorBlue
+	}
+	if bg.Active != colorBlue && bg.Active != colorGreen {
+		errs.add("active", "active must be %q or %q", colorBlue, colorGreen)
+	}
+	if bg.BlueUpstreamID == bg.GreenUpstreamID {
+		errs.add("green_upstream_id", "green_upstream_id must differ from blue_upstream_id")
+	}
+	for _, field := range []struct {
+		name string
+		id   int
+	}{{"blue_upstream_id", bg.BlueUpstreamID}, {"green_upstream_id", bg.GreenUpstreamID}} {
+		if _, ok := store.upstreams.get(field.id); !ok {
+			errs.add(field.name, "%s: upstream %d does not exist", field.name, field.id)
+		}
+	}
+	return errs.err()
+}
+
+// SwitchProxyHost godoc
+// @Summary      Switch blue/green color
+// @Description  Activate the other upstream of a blue/green proxy host, test and reload Nginx, and record the switch
+// @Tags         proxy-hosts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Proxy Host ID"
+// @Param        If-Match header string false "ETag of the proxy host version being updated"
+// @Param        switch body SwitchRequest false "Color to activate, defaults to the inactive one"
+// @Success      200 {object} SwitchRecord
+// @Header       200 {string} ETag "Version of the proxy host"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Router       /proxy-hosts/{id}/switch [post]
+func SwitchProxyHost(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req SwitchRequest
+	if len(c.Body()) > 0 {
+		if err := parseBody(c, &req); err != nil {
+			return respondInvalid(c, err)
+		}
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	host, ok := store.proxyHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+	if err := checkIfMatch(c, host.Version); err != nil {
+		return respondStale(c, host.Version, err)
+	}
+	if host.BlueGreen == nil {
+		return respondError(c, 409, "Conflict", "Blue/green is not configured for this proxy host")
+	}
+
+	target := req.Target
+	if target == "" {
+		target = otherColor(host.BlueGreen.Active)
+	}
+	if target != colorBlue && target != colorGreen {
+		return respondError(c, 400, "Invalid request", fmt.Sprintf("target must be %q or %q", colorBlue, colorGreen))
+	}
+	if target == host.BlueGreen.Active {
+		return respondError(c, 409, "Conflict", fmt.Sprintf("%s is already active", target))
+	}
+
+	return switchColor(c, host, target, nil)
+}
+
+// RevertProxyHostSwitch godoc
+// @Summary      Revert the last blue/green switch
+// @Description  Switch a proxy host back to the color that was active before its last applied switch
+// @Tags         proxy-hosts
+// @Produce      json
+// @Param        id path int true "Proxy Host ID"
+// @Param        If-Match header string false "ETag of the proxy host version being updated"
+// @Success      200 {object} SwitchRecord
+// @Header       200 {string} ETag "Version of the proxy host"
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Router       /proxy-hosts/{id}/switch/revert [post]
+func RevertProxyHostSwitch(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	host, ok := store.proxyHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+	if err := checkIfMatch(c, host.Version); err != nil {
+		return respondStale(c, host.Version, err)
+	}
+	if host.BlueGreen == nil {
+		return respondError(c, 409, "Conflict", "Blue/green is not configured for this proxy host")
+	}
+
+	var last *SwitchRecord
+	for _, record := range store.switches.all() {
+		if record.ProxyHostID == id && record.Status == "applied" {
+			last = &record
+		}
+	}
+	if last == nil || last.To != host.BlueGreen.Active {
+		return respondError(c, 409, "Conflict", "No switch to revert")
+	}
+
+	return switchColor(c, host, last.From, &last.ID)
+}
+
+// ListProxyHostSwitches godoc
+// @Summary      List blue/green switch history
+// @Description  Get every recorded blue/green switch of a proxy host, oldest first
+// @Tags         proxy-hosts
+// @Produce      json
+// @Param        id path int true "Proxy Host ID"
+// @Success      200 {array} SwitchRecord
+// @Failure      404 {object} ErrorResponse
+// @Router       /proxy-hosts/{id}/switches [get]
+func ListProxyHostSwitches(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if _, ok := store.proxyHosts.get(id); !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+
+	records := []SwitchRecord{}
+	for _, record := range store.switches.all() {
+		if record.ProxyHostID == id {
+			records = append(records, record)
+		}
+	}
+	return c.JSON(records)
+}
+
+// switchColor activates a color, applies the configuration and records the
+// outcome. A failed apply keeps the previous color active.
+// Callers must hold store.mu.
+func switchColor(c *fiber.Ctx, host ProxyHost, target string, revertOf *int) error {
+	if len(store.serversOf(host.BlueGreen.upstreamID(target))) == 0 {
+		return respondError(c, 409, "Conflict", fmt.Sprintf("The %s upstream has no servers", target))
+	}
+
+	original := host
+	previous := *host.BlueGreen
+	switched := previous
+	switched.Active = target
+	host.BlueGreen = &switched
+	host = store.proxyHosts.put(host.ID, host)
+
+	record := SwitchRecord{
+		ID:          store.switches.newID(),
+		ProxyHostID: host.ID,
+		From:        previous.Active,
+		To:          target,
+		Status:      "applied",
+		RevertOf:    revertOf,
+		SwitchedAt:  now(),
+	}
+
+	output, err := applyConfig()
+	record.Output = output
+	if err != nil {
+		store.proxyHosts.restore(original.ID, original)
+
+		record.Status = "failed"
+		store.switches.put(record.ID, record)
+		setETag(c, original.Version)
+		return respondError(c, 500, "Switch failed", fmt.Sprintf("%v: %s", err, output))
+	}
+
+	store.switches.put(record.ID, record)
+	setETag(c, host.Version)
+	return c.JSON(record)
+}
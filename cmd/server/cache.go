@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// nginxSizePattern matches nginx sizes such as 512k, 10m or 1g
+var nginxSizePattern = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+
+// cacheUseStaleConditions lists the values proxy_cache_use_stale accepts
+var cacheUseStaleConditions = []string{
+	"error", "timeout", "invalid_header", "updating",
+	"http_500", "http_502", "http_503", "http_504", "http_403", "http_404", "http_429",
+}
+
+// defaultCacheKey is the key nginx uses when proxy_cache_key is not set
+const defaultCacheKey = "$scheme$proxy_host$request_uri"
+
+// CacheConfig enables nginx proxy caching for a proxy host
+type CacheConfig struct {
+	ZoneSize         string     `json:"zone_size,omitempty" example:"10m"`
+	MaxSize          string     `json:"max_size,omitempty" example:"1g"`
+	Inactive         string     `json:"inactive,omitempty" example:"60m"`
+	TTLs             []CacheTTL `json:"ttls,omitempty"`
+	Key              string     `json:"key,omitempty" example:"$scheme$host$request_uri"`
+	BypassCookies    []string   `json:"bypass_cookies,omitempty" example:"session_id"`
+	BypassHeaders    []string   `json:"bypass_headers,omitempty" example:"Authorization"`
+	UseStale         []string   `json:"use_stale,omitempty" example:"error,timeout,updating"`
+	BackgroundUpdate bool       `json:"background_update,omitempty" example:"true"`
+	Lock             bool       `json:"lock,omitempty" example:"true"`
+}
+
+// CacheTTL sets how long responses with the given status codes are cached.
+// Status codes may contain "any".
+type CacheTTL struct {
+	StatusCodes []string `json:"status_codes" example:"200,302"`
+	TTL         string   `json:"ttl" example:"10m"`
+}
+
+// validateCache normalizes and checks a cache configuration
+func validateCache(cache *CacheConfig) error {
+	if cache == nil {
+		return nil
+	}
+	var errs fieldErrors
+
+	if cache.ZoneSize == "" {
+		cache.ZoneSize = "10m"
+	}
+	if cache.Inactive == "" {
+		cache.Inactive = "60m"
+	}
+	if cache.Key == "" {
+		cache.Key = defaultCacheKey
+	}
+	if len(cache.TTLs) == 0 {
+		cache.TTLs = []CacheTTL{{StatusCodes: []string{"200", "301", "302"}, TTL: "10m"}}
+	}
+
+	if !nginxSizePattern.MatchString(cache.ZoneSize) {
+		errs.add("zone_size", "zone_size must be an nginx size such as 10m")
+	}
+	if cache.MaxSize != "" && !nginxSizePattern.MatchString(cache.MaxSize) {
+		errs.add("max_size", "max_size must be an nginx size such as 1g")
+	}
+	if !nginxTimePattern.MatchString(cache.Inactive) {
+		errs.add("inactive", "inactive must be an nginx time such as 60m")
+	}
+
+	for i, ttl := range cache.TTLs {
+		if len(ttl.StatusCodes) == 0 {
+			errs.add(fmt.Sprintf("ttls[%d].status_codes", i), "ttls[%d].status_codes is required", i)
+		}
+		for j, code := range ttl.StatusCodes {
+			if code == "any" {
+				continue
+			}
+			if n, err := strconv.Atoi(code); err != nil || n < 100 || n > 599 {
+				errs.add(fmt.Sprintf("ttls[%d].status_codes[%d]", i, j), "ttls[%d].status_codes[%d]: %q is not a status code", i, j, code)
+			}
+		}
+		if !nginxTimePattern.MatchString(ttl.TTL) {
+			errs.add(fmt.Sprintf("ttls[%d].ttl", i), "ttls[%d].ttl must be an nginx time such as 10m", i)
+		}
+	}
+
+	for i, name := range cache.BypassCookies {
+		if !cookieNamePattern.MatchString(name) {
+			errs.add(fmt.Sprintf("bypass_cookies[%d]", i), "bypass_cookies[%d]: %q is not a valid cookie name", i, name)
+		}
+	}
+	for i, name := range cache.BypassHeaders {
+		if !headerNamePattern.MatchString(name) {
+			errs.add(fmt.Sprintf("bypass_headers[%d]", i), "bypass_headers[%d]: %q is not a valid header name", i, name)
+		}
+	}
+	for i, condition := range cache.UseStale {
+		if !slices.Contains(cacheUseStaleConditions, condition) {
+			errs.add(fmt.Sprintf("use_stale[%d]", i), "use_stale[%d]: unknown condition %q", i, condition)
+		}
+	}
+	if cache.BackgroundUpdate && !slices.Contains(cache.UseStale, "updating") {
+		errs.add("background_update", "background_update requires the \"updating\" use_stale condition")
+	}
+
+	return errs.err()
+}
+
+// cacheDir returns the directory that holds the cache of a proxy host
+func cacheDir(host ProxyHost) string {
+	return filepath.Join(nginxController.Config().DataPath, "cache", hostZone(host))
+}
+
+// cacheZone names the keys zone of a proxy host cache
+func cacheZone(host ProxyHost) string {
+	return "bs_cache_" + hostZone(host)
+}
+
+// renderCachePath renders the proxy_cache_path of a proxy host
+func renderCachePath(host ProxyHost) []nginx.Directive {
+	cache := host.Cache
+	if cache == nil {
+		return nil
+	}
+
+	args := []string{
+		cacheDir(host),
+		"levels=1:2",
+		"keys_zone=" + cacheZone(host) + ":" + cache.ZoneSize,
+		"inactive=" + cache.Inactive,
+		"use_temp_path=off",
+	}
+	if cache.MaxSize != "" {
+		args = append(args, "max_size="+cache.MaxSize)
+	}
+	return []nginx.Directive{nginx.Simple("proxy_cache_path", args...)}
+}
+
+// renderCache renders the caching directives of a proxy host server block
+func renderCache(host ProxyHost) []nginx.Directive {
+	cache := host.Cache
+	if cache == nil {
+		return nil
+	}
+
+	directives := []nginx.Directive{
+		nginx.Simple("proxy_cache", cacheZone(host)),
+		nginx.Simple("proxy_cache_key", cache.Key),
+	}
+	for _, ttl := range cache.TTLs {
+		directives = append(directives, nginx.Simple("proxy_cache_valid", append(slices.Clone(ttl.StatusCodes), ttl.TTL)...))
+	}
+
+	var bypass []string
+	for _, name := range cache.BypassCookies {
+		bypass = append(bypass, "$cookie_"+name)
+	}
+	for _, name := range cache.BypassHeaders {
+		bypass = append(bypass, headerVariable(name))
+	}
+	if len(bypass) > 0 {
+		directives = append(directives,
+			nginx.Simple("proxy_cache_bypass", bypass...),
+			nginx.Simple("proxy_no_cache", bypass...),
+		)
+	}
+
+	if len(cache.UseStale) > 0 {
+		directives = append(directives, nginx.Simple("proxy_cache_use_stale", cache.UseStale...))
+	}
+	if cache.BackgroundUpdate {
+		directives = append(directives, nginx.Simple("proxy_cache_background_update", "on"))
+	}
+	if cache.Lock {
+		directives = append(directives, nginx.Simple("proxy_cache_lock", "on"))
+	}
+	return directives
+}
+
+// PurgeProxyHostCache godoc
+// @Summary      Purge proxy host cache
+// @Description  Remove every cached response of a proxy host
+// @Tags         proxy-hosts
+// @Produce      json
+// @Param        id path int true "Proxy Host ID"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Router       /proxy-hosts/{id}/cache/purge [post]
+func PurgeProxyHostCache(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	host, ok := store.proxyHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+	if host.Cache == nil {
+		return respondError(c, 409, "Conflict", "Caching is not enabled for this proxy host")
+	}
+
+	removed, err := nginxController.Purge(cacheDir(host))
+	if err != nil {
+		return respondError(c, 500, "Purge failed", err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"message":       "Cache purged successfully",
+		"id":            id,
+		"files_removed": removed,
+	})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const certificateCheckInterval = time.Hour
+
+// certificateExpiryDays are the days before expiry a certificate is reported
+// at, from the first warning to the last
+var certificateExpiryDays = []int{30, 14, 7, 1}
+
+// certificateWarnings remembers which expiry and threshold each certificate
+// was already reported for, so that a renewed certificate is reported again.
+// Only certificateTick touches it.
+var certificateWarnings = map[int]string{}
+
+// validateCertificateRef checks that an optional certificate reference
+// exists. Callers must hold store.mu.
+func validateCertificateRef(id *int) error {
+	if id == nil {
+		return nil
+	}
+	if _, ok := store.certificates.get(*id); !ok {
+		return fmt.Errorf("certificate %d does not exist", *id)
+	}
+	return nil
+}
+
+// startCertificateChecks reports expiring certificates in the background
+func startCertificateChecks() {
+	go func() {
+		certificateTick(time.Now())
+		ticker := time.NewTicker(certificateCheckInterval)
+		defer ticker.Stop()
+		for current := range ticker.C {
+			certificateTick(current)
+		}
+	}()
+}
+
+// certificateTick publishes an event for every certificate that crossed an
+// expiry threshold or expired since the last check
+func certificateTick(current time.Time) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	for _, cert := range store.certificates.all() {
+		expiresAt, err := time.Parse(time.RFC3339, cert.ExpiresAt)
+		if err != nil {
+			continue
+		}
+
+		daysLeft := int(expiresAt.Sub(current).Hours() / 24)
+
+		eventType := "certificate.expired"
+		threshold := 0
+		if expiresAt.After(current) {
+			eventType = "certificate.expiring"
+			for _, days := range certificateExpiryDays {
+				if daysLeft < days {
+					threshold = days
+				}
+			}
+			if threshold == 0 {
+				continue
+			}
+		}
+
+		reported := fmt.Sprintf("%s %s %d", eventType, cert.ExpiresAt, threshold)
+		if certificateWarnings[cert.ID] == reported {
+			continue
+		}
+		certificateWarnings[cert.ID] = reported
+
+		publishEvent(topicCertificates, eventType, map[string]any{
+			"certificate_id": cert.ID,
+			"domain_name":    cert.DomainName,
+			"expires_at":     cert.ExpiresAt,
+			"days_left":      daysLeft,
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Client certificate verification modes, named after ssl_verify_client
+const (
+	clientVerifyOn           = "on"
+	clientVerifyOptional     = "optional"
+	clientVerifyOptionalNoCA = "optional_no_ca"
+)
+
+// defaultClientVerifyDepth matches the nginx default of ssl_verify_depth
+const defaultClientVerifyDepth = 1
+
+// CABundle holds PEM encoded CA certificates used to verify client certificates
+type CABundle struct {
+	ID int `json:"id" example:"1"`
+	Revision
+	Name      string   `json:"name" example:"Internal clients CA"`
+	PEM       string   `json:"pem"`
+	Subjects  []string `json:"subjects" example:"CN=Internal Clients CA,O=Example"`
+	NotAfter  string   `json:"not_after" example:"2030-01-01T00:00:00Z"`
+	CreatedAt string   `json:"created_at" example:"2025-12-08T10:00:00Z"`
+}
+
+// CABundleRequest represents the request body for uploading CA bundles
+type CABundleRequest struct {
+	Name string `json:"name" binding:"required" example:"Internal clients CA"`
+	PEM  string `json:"pem" binding:"required"`
+}
+
+// ClientAuth requires or requests client certificates on an SSL-enabled host
+type ClientAuth struct {
+	CABundleID *int   `json:"ca_bundle_id,omitempty" example:"1"`
+	Verify     string `json:"verify" example:"on"`
+	Depth      int    `json:"depth,omitempty" example:"2"`
+	// DNHeader forwards the subject DN of a verified client certificate to the backend
+	DNHeader string `json:"dn_header,omitempty" example:"X-Client-DN"`
+}
+
+// parseCABundle checks that a bundle holds only CA certificates and returns
+// their subjects and the earliest expiry
+func parseCABundle(data string) ([]string, time.Time, error) {
+	var subjects []string
+	var notAfter time.Time
+
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, time.Time{}, fmt.Errorf("unexpected PEM block %q, only certificates are allowed", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("certificate %d: %w", len(subjects)+1, err)
+		}
+		if !cert.IsCA {
+			return nil, time.Time{}, fmt.Errorf("certificate %q is not a CA certificate", cert.Subject.String())
+		}
+		subjects = append(subjects, cert.Subject.String())
+		if notAfter.IsZero() || cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+
+	if len(subjects) == 0 {
+		return nil, time.Time{}, fmt.Errorf("pem must contain at least one certificate")
+	}
+	return subjects, notAfter, nil
+}
+
+// validateCABundle checks a CA bundle request and fills the parsed fields
+func validateCABundle(req CABundleRequest, bundle *CABundle) error {
+	subjects, notAfter, err := parseCABundle(req.PEM)
+	if err != nil {
+		var errs fieldErrors
+		errs.check("pem", err)
+		return errs
+	}
+
+	bundle.Name = req.Name
+	bundle.PEM = req.PEM
+	bundle.Subjects = subjects
+	bundle.NotAfter = notAfter.UTC().Format(time.RFC3339)
+	return nil
+}
+
+// validateClientAuth normalizes client certificate settings of a host.
+// Callers must hold store.mu.
+func validateClientAuth(auth *ClientAuth, sslEnabled bool) error {
+	if auth == nil {
+		return nil
+	}
+	var errs fieldErrors
+	if !sslEnabled {
+		errs.add("", "requires ssl_enabled")
+	}
+
+	if auth.Verify == "" {
+		auth.Verify = clientVerifyOn
+	}
+	switch auth.Verify {
+	case clientVerifyOn, clientVerifyOptional:
+		if auth.CABundleID == nil {
+			errs.add("ca_bundle_id", "ca_bundle_id is required for verify %q", auth.Verify)
+		}
+	case clientVerifyOptionalNoCA:
+	default:
+		errs.add("verify", "verify must be %q, %q or %q", clientVerifyOn, clientVerifyOptional, clientVerifyOptionalNoCA)
+	}
+	if auth.CABundleID != nil {
+		if _, ok := store.caBundles.get(*auth.CABundleID); !ok {
+			errs.add("ca_bundle_id", "ca_bundle_id: CA bundle %d does not exist", *auth.CABundleID)
+		}
+	}
+
+	if auth.Depth == 0 {
+		auth.Depth = defaultClientVerifyDepth
+	}
+	if auth.Depth < 0 {
+		errs.add("depth", "depth must not be negative")
+	}
+	if auth.DNHeader != "" && !headerNamePattern.MatchString(auth.DNHeader) {
+		errs.add("dn_header", "dn_header: %q is not a valid header name", auth.DNHeader)
+	}
+	return errs.err()
+}
+
+// caBundleUsedBy reports whether a proxy host verifies clients or its
+// backend with a CA bundle
+func caBundleUsedBy(host ProxyHost, id int) bool {
+	if auth := host.ClientAuth; auth != nil && auth.CABundleID != nil && *auth.CABundleID == id {
+		return true
+	}
+	if backend := host.Backend; backend != nil && backend.CABundleID != nil && *backend.CABundleID == id {
+		return true
+	}
+	return false
+}
+
+// caBundlePath returns where a CA bundle is written
+func caBundlePath(id int) string {
+	return filepath.Join(nginxController.Config().DataPath, "ca-bundles", strconv.Itoa(id)+".pem")
+}
+
+// renderCABundleFiles renders one file per CA bundle and removes the files
+// of deleted bundles
+func renderCABundleFiles() []nginx.File {
+	var files []nginx.File
+	for _, bundle := range store.caBundles.all() {
+		files = append(files, nginx.File{Path: caBundlePath(bundle.ID), Content: bundle.PEM, Mode: 0o644})
+	}
+	return removeStale(files, filepath.Join(filepath.Dir(caBundlePath(0)), "*.pem"))
+}
+
+// clientDNVariable names the map variable holding the verified client DN
+func clientDNVariable(host ProxyHost) string {
+	return "$bs_client_dn_" + hostZone(host)
+}
+
+// renderClientDNMap renders a map that only exposes the subject DN once the
+// certificate passed verification. With optional_no_ca nginx fills
+// $ssl_client_s_dn for any certificate, so it must not reach the backend
+// unchecked.
+func renderClientDNMap(host ProxyHost) []nginx.Directive {
+	auth := host.ClientAuth
+	if auth == nil || auth.DNHeader == "" || !host.SSLEnabled {
+		return nil
+	}
+	return []nginx.Directive{
+		nginx.NewBlock("map", []string{"$ssl_client_verify", clientDNVariable(host)},
+			nginx.Simple("SUCCESS", "$ssl_client_s_dn"),
+			nginx.Simple("default", ""),
+		),
+	}
+}
+
+// clientDNRule forwards the verified client DN to the backend
+func clientDNRule(host ProxyHost) HeaderRule {
+	return HeaderRule{
+		Direction: headerRequest,
+		Action:    headerSet,
+		Name:      host.ClientAuth.DNHeader,
+		Value:     clientDNVariable(host),
+	}
+}
+
+// renderClientAuth renders the client certificate directives of a server block
+func renderClientAuth(host ProxyHost) []nginx.Directive {
+	auth := host.ClientAuth
+	if auth == nil || !host.SSLEnabled || host.SSLCertID == nil {
+		return nil
+	}
+
+	var directives []nginx.Directive
+	if auth.CABundleID != nil {
+		directives = append(directives, nginx.Simple("ssl_client_certificate", caBundlePath(*auth.CABundleID)))
+	}
+	directives = append(directives,
+		nginx.Simple("ssl_verify_client", auth.Verify),
+		nginx.Simple("ssl_verify_depth", strconv.Itoa(auth.Depth)),
+	)
+
+	// The server also listens on plain HTTP, where nginx never asks for a
+	// certificate, so a required certificate is enforced explicitly
+	if auth.Verify == clientVerifyOn {
+		directives = append(directives, nginx.NewBlock("if", []string{"($ssl_client_verify", "!=", "SUCCESS)"},
+			nginx.Simple("return", "403"),
+		))
+	}
+	return directives
+}
+
+// ListCABundles godoc
+// @Summary      List CA bundles
+// @Description  Get all CA bundles used to verify client certificates
+// @Tags         ca-bundles
+// @Produce      json
+// @Success      200 {array} CABundle
+// @Router       /ca-bundles [get]
+func ListCABundles(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return c.JSON(store.caBundles.all())
+}
+
+// CreateCABundle godoc
+// @Summary      Upload a CA bundle
+// @Description  Upload PEM encoded CA certificates for mutual TLS
+// @Tags         ca-bundles
+// @Accept       json
+// @Produce      json
+// @Param        bundle body CABundleRequest true "CA Bundle"
+// @Success      201 {object} CABundle
+// @Header       201 {string} ETag "Version of the CA bundle"
+// @Failure      400 {object} ErrorResponse
+// @Router       /ca-bundles [post]
+func CreateCABundle(c *fiber.Ctx) error {
+	var req CABundleRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	bundle := CABundle{CreatedAt: now()}
+	if err := validateCABundle(req, &bundle); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	bundle.ID = store.caBundles.newID()
+	bundle = store.caBundles.put(bundle.ID, bundle)
+
+	setETag(c, bundle.Version)
+	return c.Status(201).JSON(bundle)
+}
+
+// GetCABundle godoc
+// @Summary      Get a CA bundle
+// @Description  Get a specific CA bundle by ID
+// @Tags         ca-bundles
+// @Produce      json
+// @Param        id path int true "CA Bundle ID"
+// @Success      200 {object} CABundle
+// @Header       200 {string} ETag "Version of the CA bundle"
+// @Failure      404 {object} ErrorResponse
+// @Router       /ca-bundles/{id} [get]
+func GetCABundle(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	bundle, ok := store.caBundles.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "CA bundle not found")
+	}
+
+	setETag(c, bundle.Version)
+	return c.JSON(bundle)
+}
+
+// UpdateCABundle godoc
+// @Summary      Replace a CA bundle
+// @Description  Replace the certificates of a CA bundle, for example to roll over a CA
+// @Tags         ca-bundles
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "CA Bundle ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        bundle body CABundleRequest true "Updated CA Bundle"
+// @Success      200 {object} CABundle
+// @Header       200 {string} ETag "Version of the CA bundle"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /ca-bundles/{id} [put]
+func UpdateCABundle(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req CABundleRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	bundle, ok := store.caBundles.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "CA bundle not found")
+	}
+	if err := checkIfMatch(c, bundle.Version); err != nil {
+		return respondStale(c, bundle.Version, err)
+	}
+	if err := validateCABundle(req, &bundle); err != nil {
+		return respondInvalid(c, err)
+	}
+	bundle = store.caBundles.put(bundle.ID, bundle)
+
+	setETag(c, bundle.Version)
+	return c.JSON(bundle)
+}
+
+// PatchCABundle godoc
+// @Summary      Partially update a CA bundle
+// @Description  Rename a CA bundle or replace its certificates with a JSON merge patch (RFC 7396)
+// @Tags         ca-bundles
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "CA Bundle ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        bundle body CABundleRequest true "Fields to change"
+// @Success      200 {object} CABundle
+// @Header       200 {string} ETag "Version of the CA bundle"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /ca-bundles/{id} [patch]
+func PatchCABundle(c *fiber.Ctx) error {
+	return patchResource(c, store.caBundles, "CA bundle not found", caBundleRequest, UpdateCABundle)
+}
+
+// caBundleRequest returns the request that would upload a CA bundle as it is
+func caBundleRequest(bundle CABundle) CABundleRequest {
+	return CABundleRequest{Name: bundle.Name, PEM: bundle.PEM}
+}
+
+// DeleteCABundle godoc
+// @Summary      Delete a CA bundle
+// @Description  Delete a CA bundle that no proxy host uses
+// @Tags         ca-bundles
+// @Produce      json
+// @Param        id path int true "CA Bundle ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /ca-bundles/{id} [delete]
+func DeleteCABundle(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	bundle, ok := store.caBundles.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "CA bundle not found")
+	}
+	if err := checkIfMatch(c, bundle.Version); err != nil {
+		return respondStale(c, bundle.Version, err)
+	}
+	for _, host := range store.proxyHosts.all() {
+		if caBundleUsedBy(host, id) {
+			return respondError(c, 409, "Conflict", fmt.Sprintf("CA bundle is used by proxy host %d", host.ID))
+		}
+	}
+	store.caBundles.remove(id)
+
+	return c.JSON(fiber.Map{
+		"message": "CA bundle deleted successfully",
+		"id":      id,
+	})
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Resource types that claim domain names or ports
+const (
+	resourceProxyHost       = "proxy_host"
+	resourceRedirectionHost = "redirection_host"
+	resourceStream          = "stream"
+	resourceHTTPListener    = "http_listener"
+	resourceTLSProfile      = "tls_profile"
+)
+
+// Conflict kinds
+const (
+	conflictDuplicate = "duplicate"
+	conflictWildcard  = "wildcard"
+	conflictPort      = "port"
+)
+
+// ConflictSide is a resource and the domain name or port it claims. ID is
+// empty for a resource that is being created and for the http listeners.
+type ConflictSide struct {
+	Type  string `json:"type" example:"proxy_host"`
+	ID    int    `json:"id,omitempty" example:"3"`
+	Value string `json:"value" example:"*.example.com"`
+}
+
+// Conflict is a domain name or port claimed by two resources. Duplicates
+// are the same name twice, also within one request, wildcard conflicts a
+// wildcard that also matches the other name, port conflicts a stream on a
+// port that another stream or the http context listens on.
+type Conflict struct {
+	Kind          string       `json:"kind" example:"wildcard"`
+	Resource      ConflictSide `json:"resource"`
+	ConflictsWith ConflictSide `json:"conflicts_with"`
+}
+
+// respondConflicts sends a 409 listing the conflicts of a request
+func respondConflicts(c *fiber.Ctx, conflicts []Conflict) error {
+	message := describeConflict(conflicts[0])
+	if len(conflicts) > 1 {
+		message += fmt.Sprintf(" (and %d more)", len(conflicts)-1)
+	}
+	return c.Status(409).JSON(ErrorResponse{
+		Error:     "Conflict",
+		Message:   message,
+		Conflicts: conflicts,
+	})
+}
+
+func describeConflict(conflict Conflict) string {
+	if conflict.Resource == conflict.ConflictsWith {
+		return fmt.Sprintf("%s is listed more than once", conflict.Resource.Value)
+	}
+	other := "the " + strings.ReplaceAll(conflict.ConflictsWith.Type, "_", " ")
+	if conflict.ConflictsWith.ID != 0 {
+		other = fmt.Sprintf("%s %d", strings.ReplaceAll(conflict.ConflictsWith.Type, "_", " "), conflict.ConflictsWith.ID)
+	}
+	if conflict.Kind == conflictWildcard {
+		return fmt.Sprintf("%s overlaps %s of %s", conflict.Resource.Value, conflict.ConflictsWith.Value, other)
+	}
+	return fmt.Sprintf("%s is already used by %s", conflict.Resource.Value, other)
+}
+
+// domainClaims returns the domain names of every proxy and redirection
+// host. Disabled hosts are included, they conflict once enabled again.
+// Callers must hold store.mu.
+func domainClaims() []ConflictSide {
+	var claims []ConflictSide
+	for _, host := range store.proxyHosts.all() {
+		for _, name := range host.DomainNames {
+			claims = append(claims, ConflictSide{Type: resourceProxyHost, ID: host.ID, Value: name})
+		}
+	}
+	for _, host := range store.redirectionHosts.all() {
+		for _, name := range host.DomainNames {
+			claims = append(claims, ConflictSide{Type: resourceRedirectionHost, ID: host.ID, Value: name})
+		}
+	}
+	return claims
+}
+
+// domainConflicts returns the names listed twice in a request and the
+// existing domain names that overlap names of the given resource. id is 0 on
+// create. Callers must hold store.mu.
+func domainConflicts(resourceType string, id int, names []string) []Conflict {
+	var conflicts []Conflict
+	for i, name := range names {
+		if slices.Contains(names[:i], name) {
+			side := ConflictSide{Type: resourceType, ID: id, Value: name}
+			conflicts = append(conflicts, Conflict{Kind: conflictDuplicate, Resource: side, ConflictsWith: side})
+		}
+	}
+
+	claims := domainClaims()
+	for _, name := range names {
+		for _, claim := range claims {
+			if claim.Type == resourceType && claim.ID == id {
+				continue
+			}
+			if kind, ok := domainOverlap(name, claim.Value); ok {
+				conflicts = append(conflicts, Conflict{
+					Kind:          kind,
+					Resource:      ConflictSide{Type: resourceType, ID: id, Value: name},
+					ConflictsWith: claim,
+				})
+			}
+		}
+	}
+	return conflicts
+}
+
+// streamConflicts returns the streams and http listeners that already use
+// the port and protocol of a stream. id is 0 on create. Callers must hold
+// store.mu.
+func streamConflicts(id int, port int, protocol string) []Conflict {
+	resource := ConflictSide{Type: resourceStream, ID: id, Value: streamPort(port, protocol)}
+	conflicts := listenerConflicts(resource, httpListeners())
+	for _, stream := range store.streams.all() {
+		if stream.ID != id && stream.ListenPort == port && stream.Protocol == protocol {
+			conflicts = append(conflicts, Conflict{
+				Kind:          conflictPort,
+				Resource:      resource,
+				ConflictsWith: ConflictSide{Type: resourceStream, ID: stream.ID, Value: streamPort(stream.ListenPort, stream.Protocol)},
+			})
+		}
+	}
+	return conflicts
+}
+
+// httpListeners returns the ports the http context listens on: 80 and 443
+// for hosts, the stub_status port and UDP 443 while a host serves HTTP/3.
+// nginx binds them on every address, so a stream on the same port fails
+// the reload. Callers must hold store.mu.
+func httpListeners() []ConflictSide {
+	var listeners []ConflictSide
+	listen := func(port int, protocol string) {
+		listeners = append(listeners, ConflictSide{Type: resourceHTTPListener, Value: streamPort(port, protocol)})
+	}
+
+	for _, port := range httpListenPorts {
+		listen(port, protocolTCP)
+	}
+	if _, port, err := net.SplitHostPort(nginxController.Config().StatusAddr); err == nil {
+		if n, err := strconv.Atoi(port); err == nil {
+			listen(n, protocolTCP)
+		}
+	}
+
+	served := slices.ContainsFunc(store.proxyHosts.all(), func(host ProxyHost) bool {
+		return servesHTTP3(host.SSLEnabled, host.SSLCertID, host.TLSProfileID)
+	}) || slices.ContainsFunc(store.redirectionHosts.all(), func(host RedirectionHost) bool {
+		return servesHTTP3(host.SSLEnabled, host.SSLCertID, nil)
+	})
+	if served {
+		listen(443, protocolUDP)
+	}
+	return listeners
+}
+
+// servesHTTP3 reports whether a host with these settings listens on UDP 443.
+// Callers must hold store.mu.
+func servesHTTP3(sslEnabled bool, sslCertID, tlsProfileID *int) bool {
+	profile, ok := tlsProfileFor(tlsProfileID)
+	return sslEnabled && sslCertID != nil && ok && profile.HTTP3
+}
+
+// http3Conflicts returns the streams on UDP 443 that a resource starting
+// to serve HTTP/3 would take the port from. Callers must hold store.mu.
+func http3Conflicts(resource ConflictSide) []Conflict {
+	resource.Value = streamPort(443, protocolUDP)
+	var conflicts []Conflict
+	for _, stream := range store.streams.all() {
+		side := ConflictSide{Type: resourceStream, ID: stream.ID, Value: streamPort(stream.ListenPort, stream.Protocol)}
+		if side.Value == resource.Value {
+			conflicts = append(conflicts, Conflict{Kind: conflictPort, Resource: resource, ConflictsWith: side})
+		}
+	}
+	return conflicts
+}
+
+// listenerConflicts returns the http listeners on the port of a stream
+func listenerConflicts(stream ConflictSide, listeners []ConflictSide) []Conflict {
+	var conflicts []Conflict
+	for _, listener := range listeners {
+		if listener.Value == stream.Value {
+			conflicts = append(conflicts, Conflict{Kind: conflictPort, Resource: stream, ConflictsWith: listener})
+		}
+	}
+	return conflicts
+}
+
+func streamPort(port int, protocol string) string {
+	return fmt.Sprintf("%s port %d", protocol, port)
+}
+
+// domainOverlap reports whether a request for some host name could match
+// both server names, and how. Names are normalized, see normalizeDomain.
+func domainOverlap(a, b string) (string, bool) {
+	if a == b {
+		return conflictDuplicate, true
+	}
+	pa, pb := parseServerName(a), parseServerName(b)
+	if pa.wildcard > pb.wildcard {
+		pa, pb = pb, pa
+	}
+
+	var overlap bool
+	switch {
+	case pa.wildcard == wildcardNone && pb.wildcard == wildcardNone:
+		return "", false
+	case pa.wildcard == wildcardNone:
+		overlap = pb.matches(pa.labels)
+	case pa.wildcard == pb.wildcard:
+		// One pattern covers the other when its fixed labels are a suffix
+		// (leading wildcards) or prefix (trailing wildcards) of the other's
+		if len(pa.labels) > len(pb.labels) {
+			pa, pb = pb, pa
+		}
+		overlap = pa.matches(pb.labels)
+	default:
+		// A leading and a trailing wildcard always overlap: www.foo.* and
+		// *.example.com both match www.foo.example.com. nginx gives such
+		// names to the leading wildcard, so the trailing one silently loses
+		// them.
+		overlap = true
+	}
+	if !overlap {
+		return "", false
+	}
+	return conflictWildcard, true
+}
+
+// Wildcard positions of a server name
+const (
+	wildcardNone = iota
+	wildcardLeading
+	wildcardTrailing
+)
+
+// serverName is a server name without its wildcard label
+type serverName struct {
+	labels   []string
+	wildcard int
+}
+
+func parseServerName(name string) serverName {
+	labels := strings.Split(name, ".")
+	switch {
+	case len(labels) > 1 && labels[0] == "*":
+		return serverName{labels: labels[1:], wildcard: wildcardLeading}
+	case len(labels) > 1 && labels[len(labels)-1] == "*":
+		return serverName{labels: labels[:len(labels)-1], wildcard: wildcardTrailing}
+	}
+	return serverName{labels: labels}
+}
+
+// matches reports whether a wildcard matches a name, which needs at least
+// one label in place of the wildcard
+func (n serverName) matches(labels []string) bool {
+	if len(labels) <= len(n.labels) {
+		return false
+	}
+	if n.wildcard == wildcardLeading {
+		return slices.Equal(labels[len(labels)-len(n.labels):], n.labels)
+	}
+	return slices.Equal(labels[:len(n.labels)], n.labels)
+}
+
+// ListConflicts godoc
+// @Summary      List conflicts
+// @Description  Domain names claimed by more than one proxy or redirection host, including wildcards that overlap other names, and streams sharing a port with each other or with the http listeners. nginx silently picks one of two conflicting servers and cannot bind a port twice.
+// @Tags         conflicts
+// @Produce      json
+// @Success      200 {array} Conflict
+// @Router       /conflicts [get]
+func ListConflicts(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	conflicts := []Conflict{}
+	claims := domainClaims()
+	for i, a := range claims {
+		for _, b := range claims[i+1:] {
+			if a.Type == b.Type && a.ID == b.ID {
+				continue
+			}
+			if kind, ok := domainOverlap(a.Value, b.Value); ok {
+				conflicts = append(conflicts, Conflict{Kind: kind, Resource: a, ConflictsWith: b})
+			}
+		}
+	}
+
+	streams := store.streams.all()
+	listeners := httpListeners()
+	for i, a := range streams {
+		side := ConflictSide{Type: resourceStream, ID: a.ID, Value: streamPort(a.ListenPort, a.Protocol)}
+		conflicts = append(conflicts, listenerConflicts(side, listeners)...)
+		for _, b := range streams[i+1:] {
+			if a.ListenPort == b.ListenPort && a.Protocol == b.Protocol {
+				conflicts = append(conflicts, Conflict{
+					Kind:          conflictPort,
+					Resource:      side,
+					ConflictsWith: ConflictSide{Type: resourceStream, ID: b.ID, Value: streamPort(b.ListenPort, b.Protocol)},
+				})
+			}
+		}
+	}
+
+	return c.JSON(conflicts)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestParseServerName(t *testing.T) {
+	tests := []struct {
+		name string
+		want serverName
+	}{
+		{"example.com", serverName{labels: []string{"example", "com"}}},
+		{"*.example.com", serverName{labels: []string{"example", "com"}, wildcard: wildcardLeading}},
+		{"www.example.*", serverName{labels: []string{"www", "example"}, wildcard: wildcardTrailing}},
+		{"*", serverName{labels: []string{"*"}}},
+		{"localhost", serverName{labels: []string{"localhost"}}},
+	}
+	for _, tt := range tests {
+		if got := parseServerName(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseServerName(%q) = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDomainOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string // empty when the names do not overlap
+	}{
+		{"example.com", "example.com", conflictDuplicate},
+		{"*.example.com", "*.example.com", conflictDuplicate},
+		{"example.com", "www.example.com", ""},
+
+		// Leading wildcards need at least one label in their place
+		{"*.example.com", "www.example.com", conflictWildcard},
+		{"*.example.com", "a.b.example.com", conflictWildcard},
+		{"*.example.com", "example.com", ""},
+		{"*.example.com", "www.example.org", ""},
+
+		// Trailing wildcards
+		{"www.example.*", "www.example.com", conflictWildcard},
+		{"www.example.*", "www.example", ""},
+		{"www.example.*", "api.example.com", ""},
+
+		// Two wildcards of the same kind overlap when one covers the other
+		{"*.example.com", "*.api.example.com", conflictWildcard},
+		{"*.api.example.com", "*.example.com", conflictWildcard},
+		{"*.example.com", "*.example.org", ""},
+		{"www.*", "www.example.*", conflictWildcard},
+		{"www.example.*", "api.example.*", ""},
+
+		// A leading and a trailing wildcard always overlap
+		{"*.example.com", "www.example.*", conflictWildcard},
+		{"*.example.com", "www.foo.*", conflictWildcard},
+		{"www.foo.*", "*.example.com", conflictWildcard},
+	}
+	for _, tt := range tests {
+		kind, ok := domainOverlap(tt.a, tt.b)
+		if ok != (tt.want != "") || kind != tt.want {
+			t.Errorf("domainOverlap(%q, %q) = %q, %v, want %q", tt.a, tt.b, kind, ok, tt.want)
+		}
+	}
+}
+
+func TestStreamConflictsWithHTTPListeners(t *testing.T) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tests := []struct {
+		port     int
+		protocol string
+		want     int
+	}{
+		{80, protocolTCP, 1},
+		{443, protocolTCP, 1},
+		{8081, protocolTCP, 1},
+		{80, protocolUDP, 0},
+		{5432, protocolTCP, 0},
+	}
+	for _, tt := range tests {
+		conflicts := streamConflicts(0, tt.port, tt.protocol)
+		if len(conflicts) != tt.want {
+			t.Errorf("%s: %d conflicts, want %d", streamPort(tt.port, tt.protocol), len(conflicts), tt.want)
+			continue
+		}
+		if tt.want > 0 && conflicts[0].ConflictsWith.Type != resourceHTTPListener {
+			t.Errorf("%s conflicts with %+v", streamPort(tt.port, tt.protocol), conflicts[0].ConflictsWith)
+		}
+	}
+}
+
+func TestDomainConflictsWithinRequest(t *testing.T) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	conflicts := domainConflicts(resourceProxyHost, 0, []string{"twice.test", "once.test", "twice.test"})
+	if len(conflicts) != 1 || conflicts[0].Kind != conflictDuplicate {
+		t.Fatalf("conflicts = %+v, want one duplicate", conflicts)
+	}
+	if got, want := describeConflict(conflicts[0]), "twice.test is listed more than once"; got != want {
+		t.Errorf("describeConflict = %q, want %q", got, want)
+	}
+}
+
+func TestHTTP3Conflicts(t *testing.T) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	id := store.streams.newID()
+	store.streams.put(id, Stream{ID: id, ListenPort: 443, Protocol: protocolUDP})
+	defer store.streams.remove(id)
+
+	conflicts := http3Conflicts(ConflictSide{Type: resourceProxyHost, ID: 1})
+	if len(conflicts) != 1 || conflicts[0].ConflictsWith.ID != id {
+		t.Fatalf("conflicts = %+v, want stream %d", conflicts, id)
+	}
+	if got, want := describeConflict(conflicts[0]), fmt.Sprintf("udp port 443 is already used by stream %d", id); got != want {
+		t.Errorf("describeConflict = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Default server actions for requests to unknown domains
+const (
+	defaultActionNginx     = "nginx"
+	defaultActionClose     = "close"
+	defaultActionNotFound  = "not_found"
+	defaultActionRedirect  = "redirect"
+	defaultActionProxyHost = "proxy_host"
+)
+
+// defaultServerZone names the generated files of the default server
+const defaultServerZone = "default"
+
+// DefaultServer decides what happens to requests for domains no host
+// serves. The "nginx" action leaves the existing nginx default in place.
+type DefaultServer struct {
+	Revision
+	Action       string `json:"action" example:"close"`
+	RedirectURL  string `json:"redirect_url,omitempty" example:"https://example.com"`
+	RedirectCode int    `json:"redirect_code,omitempty" example:"302"`
+	ProxyHostID  *int   `json:"proxy_host_id,omitempty" example:"1"`
+	// Template is the HTML page of the not_found action
+	Template string `json:"template,omitempty"`
+	// SSLCertID answers HTTPS for unknown domains with this certificate.
+	// Without it such TLS handshakes are rejected.
+	SSLCertID *int   `json:"ssl_cert_id,omitempty" example:"1"`
+	UpdatedAt string `json:"updated_at,omitempty" example:"2025-12-08T10:00:00Z"`
+}
+
+// validateDefaultServer normalizes and checks default server settings.
+// Callers must hold store.mu.
+func validateDefaultServer(req *DefaultServer) error {
+	var errs fieldErrors
+	switch req.Action {
+	case defaultActionNginx, defaultActionClose:
+	case defaultActionNotFound:
+		if req.Template == "" {
+			req.Template = defaultErrorPageTemplate
+		}
+		if _, err := renderErrorPage(req.Template, 404, ""); err != nil {
+			errs.check("template", err)
+		}
+	case defaultActionRedirect:
+		target, err := url.Parse(req.RedirectURL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			errs.add("redirect_url", "redirect_url must be an absolute http or https URL")
+		}
+		if req.RedirectCode == 0 {
+			req.RedirectCode = 302
+		}
+		if !slices.Contains(redirectStatusCodes, req.RedirectCode) {
+			errs.add("redirect_code", "redirect_code must be one of 301, 302, 307 or 308")
+		}
+	case defaultActionProxyHost:
+		if req.ProxyHostID == nil {
+			errs.add("proxy_host_id", "proxy_host_id is required for the %q action", defaultActionProxyHost)
+		} else if _, ok := store.proxyHosts.get(*req.ProxyHostID); !ok {
+			errs.add("proxy_host_id", "proxy_host_id: proxy host %d does not exist", *req.ProxyHostID)
+		}
+		if req.SSLCertID != nil {
+			errs.add("ssl_cert_id", "ssl_cert_id is not used by the %q action, the proxy host's certificate applies", defaultActionProxyHost)
+		}
+	default:
+		errs.add("action", "action must be one of %q, %q, %q, %q or %q",
+			defaultActionNginx, defaultActionClose, defaultActionNotFound, defaultActionRedirect, defaultActionProxyHost)
+	}
+
+	if req.Action != defaultActionRedirect && (req.RedirectURL != "" || req.RedirectCode != 0) {
+		errs.add("redirect_url", "redirect_url and redirect_code are only used by the %q action", defaultActionRedirect)
+	}
+	if req.Action != defaultActionProxyHost && req.ProxyHostID != nil {
+		errs.add("proxy_host_id", "proxy_host_id is only used by the %q action", defaultActionProxyHost)
+	}
+	if req.Action != defaultActionNotFound && req.Template != "" {
+		errs.add("template", "template is only used by the %q action", defaultActionNotFound)
+	}
+	if req.Action == defaultActionNginx && req.SSLCertID != nil {
+		errs.add("ssl_cert_id", "ssl_cert_id is not used by the %q action", defaultActionNginx)
+	}
+	errs.check("ssl_cert_id", validateCertificateRef(req.SSLCertID))
+	return errs.err()
+}
+
+// isDefaultProxyHost reports whether unknown domains are handed to a proxy
+// host, which must then be neither deleted nor disabled
+func isDefaultProxyHost(id int) bool {
+	target := store.defaultServer.ProxyHostID
+	return target != nil && *target == id
+}
+
+// defaultProxyHost returns the proxy host that serves unknown domains
+func defaultProxyHost() (ProxyHost, bool) {
+	settings := store.defaultServer
+	if settings.Action != defaultActionProxyHost || settings.ProxyHostID == nil {
+		return ProxyHost{}, false
+	}
+	host, ok := store.proxyHosts.get(*settings.ProxyHostID)
+	if !ok || !host.Enabled {
+		return ProxyHost{}, false
+	}
+	return host, true
+}
+
+// defaultServerPage returns the generated page of the not_found action
+func defaultServerPage() (ErrorPage, bool) {
+	if store.defaultServer.Action != defaultActionNotFound {
+		return ErrorPage{}, false
+	}
+	return ErrorPage{StatusCode: 404, Template: store.defaultServer.Template}, true
+}
+
+// markDefaultServer adds default_server to the TCP listeners of a server block
+func markDefaultServer(directives []nginx.Directive) []nginx.Directive {
+	for i, d := range directives {
+		if d.Name == "listen" && !slices.Contains(d.Args, "quic") {
+			directives[i].Args = append(slices.Clone(d.Args), "default_server")
+		}
+	}
+	return directives
+}
+
+// renderDefaultTLS renders the handshake settings of the default TLS profile.
+// The catch-all server owns port 443, so every host shares them.
+func renderDefaultTLS() []nginx.Directive {
+	profile, ok := tlsProfileFor(nil)
+	if !ok {
+		return nil
+	}
+	return renderHandshakeSettings(profile)
+}
+
+// renderDefaultServer renders the catch-all server block, if one is needed
+func renderDefaultServer() []nginx.Directive {
+	settings := store.defaultServer
+
+	var server []nginx.Directive
+	switch settings.Action {
+	case defaultActionNginx:
+		return nil
+	case defaultActionProxyHost:
+		host, ok := defaultProxyHost()
+		if !ok {
+			return nil
+		}
+		if host.SSLEnabled && host.SSLCertID != nil {
+			// The proxy host already catches both ports
+			return nil
+		}
+		server = append(server, nginx.Simple("listen", "443", "ssl", "default_server"))
+		server = append(server, renderDefaultTLS()...)
+		return []nginx.Directive{nginx.NewBlock("server", nil,
+			append(server, nginx.Simple("ssl_reject_handshake", "on"))...,
+		)}
+	}
+
+	server = append(server, nginx.Simple("listen", "80", "default_server"))
+	server = append(server, nginx.Simple("listen", "443", "ssl", "default_server"))
+	server = append(server, renderDefaultTLS()...)
+	if settings.SSLCertID != nil {
+		server = append(server, renderCertificate(*settings.SSLCertID)...)
+	} else {
+		server = append(server, nginx.Simple("ssl_reject_handshake", "on"))
+	}
+	server = append(server, nginx.Simple("server_name", "_"))
+
+	switch settings.Action {
+	case defaultActionClose:
+		server = append(server, nginx.Simple("return", "444"))
+	case defaultActionRedirect:
+		server = append(server, nginx.Simple("return", strconv.Itoa(settings.RedirectCode), settings.RedirectURL))
+	case defaultActionNotFound:
+		page, _ := defaultServerPage()
+		server = append(server, renderErrorPageDirectives(defaultServerZone, []ErrorPage{page})...)
+		// A server-level return would also answer the error page redirect
+		server = append(server, nginx.NewBlock("location", []string{"/"}, nginx.Simple("return", "404")))
+	}
+	return []nginx.Directive{nginx.NewBlock("server", nil, server...)}
+}
+
+// GetDefaultServer godoc
+// @Summary      Get default server
+// @Description  Get how requests for unknown domains are handled
+// @Tags         nginx
+// @Produce      json
+// @Success      200 {object} DefaultServer
+// @Header       200 {string} ETag "Version of the default server settings"
+// @Router       /nginx/default-server [get]
+func GetDefaultServer(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	setETag(c, store.defaultServer.Version)
+	return c.JSON(store.defaultServer)
+}
+
+// UpdateDefaultServer godoc
+// @Summary      Update default server
+// @Description  Close connections (444), answer 404, redirect, or hand unknown domains to a proxy host. Use "nginx" to keep the existing nginx default server.
+// @Tags         nginx
+// @Accept       json
+// @Produce      json
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        settings body DefaultServer true "Default Server"
+// @Success      200 {object} DefaultServer
+// @Header       200 {string} ETag "Version of the default server settings"
+// @Failure      400 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /nginx/default-server [put]
+func UpdateDefaultServer(c *fiber.Ctx) error {
+	var req DefaultServer
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	version := store.defaultServer.Version
+	if err := checkIfMatch(c, version); err != nil {
+		return respondStale(c, version, err)
+	}
+	if err := validateDefaultServer(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+	req.Version = version + 1
+	req.UpdatedAt = now()
+	store.defaultServer = req
+
+	setETag(c, req.Version)
+	return c.JSON(req)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	maxDomainLength = 253
+	maxLabelLength  = 63
+)
+
+// normalizeDomain checks a server name and returns it lower cased, with
+// internationalized labels converted to punycode as nginx matches the Host
+// header against ASCII names. Besides exact names it accepts the two nginx
+// wildcard forms, *.example.com and www.example.*.
+func normalizeDomain(name string) (string, error) {
+	labels := strings.Split(name, ".")
+	first, last := 0, len(labels)
+	if labels[0] == "*" && len(labels) > 1 {
+		first = 1
+	} else if labels[len(labels)-1] == "*" && len(labels) > 1 {
+		last--
+	}
+	if first == 1 && labels[last-1] == "*" {
+		return "", fmt.Errorf("only one wildcard is allowed")
+	}
+
+	ascii, err := normalizeLabels(labels[first:last])
+	if err != nil {
+		return "", err
+	}
+	if first == 1 {
+		ascii = "*." + ascii
+	} else if last < len(labels) {
+		ascii += ".*"
+	}
+	if len(ascii) > maxDomainLength {
+		return "", fmt.Errorf("longer than %d characters", maxDomainLength)
+	}
+	return ascii, nil
+}
+
+// normalizeHostname is normalizeDomain without wildcards
+func normalizeHostname(name string) (string, error) {
+	ascii, err := normalizeLabels(strings.Split(name, "."))
+	if err != nil {
+		return "", err
+	}
+	if len(ascii) > maxDomainLength {
+		return "", fmt.Errorf("longer than %d characters", maxDomainLength)
+	}
+	return ascii, nil
+}
+
+// normalizeLabels converts labels to lower case ASCII and checks that each
+// holds letters, digits and inner hyphens only
+func normalizeLabels(labels []string) (string, error) {
+	ascii := make([]string, len(labels))
+	for i, label := range labels {
+		if label == "" {
+			return "", fmt.Errorf("empty label")
+		}
+		if isASCII(label) {
+			label = strings.ToLower(label)
+		} else {
+			// Lookup maps the label the way browsers do before encoding it
+			encoded, err := idna.Lookup.ToASCII(label)
+			if err != nil {
+				return "", fmt.Errorf("label %q is not a valid internationalized name: %v", label, err)
+			}
+			label = encoded
+		}
+		if len(label) > maxLabelLength {
+			return "", fmt.Errorf("label %q is longer than %d characters", label, maxLabelLength)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return "", fmt.Errorf("label %q starts or ends with '-'", label)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				return "", fmt.Errorf("label %q contains %q", label, r)
+			}
+		}
+		ascii[i] = label
+	}
+	return strings.Join(ascii, "."), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		name, want, err string
+	}{
+		{"Example.COM", "example.com", ""},
+		{"*.Example.com", "*.example.com", ""},
+		{"www.example.*", "www.example.*", ""},
+		// Expected punycode comes from RFC 3492 and the IDNA test data
+		{"bücher.example", "xn--bcher-kva.example", ""},
+		{"BÜCHER.example", "xn--bcher-kva.example", ""},
+		{"*.münchen.de", "*.xn--mnchen-3ya.de", ""},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah", ""},
+		{"über.example", "xn--ber-goa.example", ""},
+		{"shop_old.example.com", "", `label "shop_old" contains '_'`},
+		{"-shop.example.com", "", "starts or ends with '-'"},
+		{"a..example", "", "empty label"},
+		{"*.example.*", "", "only one wildcard is allowed"},
+		{strings.Repeat("a", 64) + ".example", "", "longer than 63 characters"},
+	}
+	for _, tt := range tests {
+		got, err := normalizeDomain(tt.name)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("normalizeDomain(%q) error = %v, want one containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeDomain(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
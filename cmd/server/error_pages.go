@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+// errorPageStatusCodes lists the status codes a custom page can replace
+var errorPageStatusCodes = []int{404, 502, 503, 504}
+
+// errorPagesPrefix is the internal URI prefix generated pages are served from
+const errorPagesPrefix = "/__bs_errors/"
+
+// defaultErrorPageTemplate is used when a page is enabled without a template
+const defaultErrorPageTemplate = `<!DOCTYPE html>
+<html>
+<head><title>{{.StatusCode}} {{.StatusText}}</title></head>
+<body>
+<h1>{{.StatusCode}} {{.StatusText}}</h1>
+<p>{{.Host}}</p>
+</body>
+</html>
+`
+
+// ErrorPages replaces error responses of a proxy host with custom pages
+type ErrorPages struct {
+	Pages []ErrorPage `json:"pages"`
+	// InterceptBackend also replaces matching errors returned by the backend,
+	// not only errors nginx generates itself
+	InterceptBackend bool `json:"intercept_backend,omitempty" example:"true"`
+}
+
+// ErrorPage is an HTML template for one status code. Templates may use
+// {{.StatusCode}}, {{.StatusText}} and {{.Host}}.
+type ErrorPage struct {
+	StatusCode int    `json:"status_code" example:"502"`
+	Template   string `json:"template,omitempty" example:"<h1>{{.StatusCode}} {{.StatusText}}</h1><p>We'll be right back.</p>"`
+}
+
+// errorPageData is passed to error page templates
+type errorPageData struct {
+	StatusCode int
+	StatusText string
+	Host       string
+}
+
+// validateErrorPages normalizes and checks the error pages of a proxy host
+func validateErrorPages(pages *ErrorPages) error {
+	if pages == nil {
+		return nil
+	}
+	var errs fieldErrors
+	if len(pages.Pages) == 0 {
+		errs.add("pages", "pages is required")
+	}
+
+	seen := map[int]bool{}
+	for i := range pages.Pages {
+		page := &pages.Pages[i]
+		if !slices.Contains(errorPageStatusCodes, page.StatusCode) {
+			errs.add(fmt.Sprintf("pages[%d].status_code", i), "pages[%d].status_code must be one of %v", i, errorPageStatusCodes)
+		} else if seen[page.StatusCode] {
+			errs.add(fmt.Sprintf("pages[%d].status_code", i), "pages[%d].status_code: duplicate status code %d", i, page.StatusCode)
+		}
+		seen[page.StatusCode] = true
+
+		if page.Template == "" {
+			page.Template = defaultErrorPageTemplate
+		}
+		if _, err := renderErrorPage(page.Template, page.StatusCode, ""); err != nil {
+			errs.add(fmt.Sprintf("pages[%d].template", i), "pages[%d].template: %v", i, err)
+		}
+	}
+	return errs.err()
+}
+
+// renderErrorPage executes an error page template
+func renderErrorPage(text string, statusCode int, host string) (string, error) {
+	tmpl, err := template.New("error_page").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+
+	var b strings.Builder
+	data := errorPageData{StatusCode: statusCode, StatusText: http.StatusText(statusCode), Host: host}
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// errorPagesDir returns the directory that holds the pages of a zone, for
+// example host1 or default
+func errorPagesDir(zone string) string {
+	return filepath.Join(nginxController.Config().DataPath, "error-pages", zone)
+}
+
+// errorPageFile renders the static file of one page
+func errorPageFile(zone string, page ErrorPage, host string) nginx.File {
+	// Templates were validated on save, so an error here cannot happen
+	content, _ := renderErrorPage(page.Template, page.StatusCode, host)
+	return nginx.File{
+		Path:    filepath.Join(errorPagesDir(zone), strconv.Itoa(page.StatusCode)+".html"),
+		Content: content,
+		Mode:    0o644,
+	}
+}
+
+// renderErrorPageFiles renders the static pages of every proxy host and the
+// default server, and removes pages that are no longer configured
+func renderErrorPageFiles() []nginx.File {
+	var files []nginx.File
+	for _, host := range store.proxyHosts.all() {
+		if host.ErrorPages == nil {
+			continue
+		}
+		for _, page := range host.ErrorPages.Pages {
+			files = append(files, errorPageFile(hostZone(host), page, strings.Join(host.DomainNames, ", ")))
+		}
+	}
+	if page, ok := defaultServerPage(); ok {
+		files = append(files, errorPageFile(defaultServerZone, page, ""))
+	}
+	return removeStale(files, filepath.Join(errorPagesDir("*"), "*.html"))
+}
+
+// renderErrorPageDirectives renders error_page directives and the internal
+// location serving the generated files
+func renderErrorPageDirectives(zone string, pages []ErrorPage) []nginx.Directive {
+	var directives []nginx.Directive
+	for _, page := range pages {
+		code := strconv.Itoa(page.StatusCode)
+		directives = append(directives, nginx.Simple("error_page", code, errorPagesPrefix+code+".html"))
+	}
+	return append(directives, nginx.NewBlock("location", []string{"^~", errorPagesPrefix},
+		nginx.Simple("internal"),
+		nginx.Simple("alias", errorPagesDir(zone)+"/"),
+	))
+}
+
+// renderErrorPages renders the custom error pages of a proxy host
+func renderErrorPages(host ProxyHost) []nginx.Directive {
+	pages := host.ErrorPages
+	if pages == nil {
+		return nil
+	}
+
+	directives := renderErrorPageDirectives(hostZone(host), pages.Pages)
+	if pages.InterceptBackend {
+		module := "proxy"
+		if isGRPC(host.Backend) {
+			module = "grpc"
+		}
+		directives = append([]nginx.Directive{nginx.Simple(module+"_intercept_errors", "on")}, directives...)
+	}
+	return directives
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Event topics clients can subscribe to
+const (
+	topicConfig       = "config"
+	topicNginx        = "nginx"
+	topicProxyHosts   = "proxy_hosts"
+	topicUpstreams    = "upstreams"
+	topicCertificates = "certificates"
+	topicAlerts       = "alerts"
+	// topicStatus carries stub_status counters. They are sampled per stream
+	// and never kept in the event history.
+	topicStatus = "status"
+)
+
+var eventTopics = []string{topicConfig, topicNginx, topicProxyHosts, topicUpstreams, topicCertificates, topicAlerts, topicStatus}
+
+// eventTypes lists every event published on the bus. Status samples are not
+// included since they only exist on event streams.
+var eventTypes = []string{
+	"config.applied", "config.failed",
+	"nginx.reloaded", "nginx.reload_failed",
+	"proxy_host.created", "proxy_host.updated", "proxy_host.deleted",
+	"upstream_server.up", "upstream_server.down",
+	"certificate.expiring", "certificate.expired",
+	"alert.firing", "alert.resolved",
+}
+
+const (
+	// eventHistory is how many events are kept for clients resuming with
+	// Last-Event-ID
+	eventHistory = 256
+	// eventBuffer is how many events a subscriber may lag behind before it
+	// is disconnected and has to resume
+	eventBuffer       = 64
+	statusInterval    = 5 * time.Second
+	heartbeatInterval = 15 * time.Second
+)
+
+// eventsToken protects the event and log streams. The streams stay closed
+// while it is unset. Browsers cannot set headers on an EventSource, so the
+// token is also accepted as the access_token query parameter.
+var eventsToken = os.Getenv("EVENTS_TOKEN")
+
+// Event is a change pushed to event stream subscribers
+type Event struct {
+	ID    int64  `json:"id,omitempty" example:"42"`
+	Topic string `json:"topic" example:"config"`
+	Type  string `json:"type" example:"config.applied"`
+	Time  string `json:"time" example:"2025-12-08T10:00:00Z"`
+	Data  any    `json:"data,omitempty"`
+}
+
+// subscription receives the events of the topics it asked for
+type subscription struct {
+	topics map[string]bool
+	ch     chan Event
+}
+
+// eventBus fans events out to subscribers and keeps a short history
+type eventBus struct {
+	mu          sync.Mutex
+	nextID      int64
+	history     []Event
+	subscribers map[*subscription]struct{}
+}
+
+var events = &eventBus{subscribers: map[*subscription]struct{}{}}
+
+// publish sends an event to every subscriber of its topic. Subscribers that
+// fall behind are dropped rather than blocking the publisher.
+func (b *eventBus) publish(topic, eventType string, data any) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := Event{ID: b.nextID, Topic: topic, Type: eventType, Time: now(), Data: data}
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistory {
+		b.history = slices.Clone(b.history[len(b.history)-eventHistory:])
+	}
+
+	for sub := range b.subscribers {
+		if !sub.topics[topic] {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+	return event
+}
+
+// subscribe registers a subscriber and returns the events after lastID it
+// missed, so that no event is lost between the two
+func (b *eventBus) subscribe(topics map[string]bool, lastID int64) (*subscription, []Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if lastID > 0 {
+		for _, event := range b.history {
+			if event.ID > lastID && topics[event.Topic] {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	sub := &subscription{topics: topics, ch: make(chan Event, eventBuffer)}
+	b.subscribers[sub] = struct{}{}
+	return sub, missed
+}
+
+func (b *eventBus) unsubscribe(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// publishEvent publishes an event on the global bus
+func publishEvent(topic, eventType string, data any) {
+	events.publish(topic, eventType, data)
+}
+
+// parseTopics reads a comma separated topic list. An empty list subscribes
+// to every topic.
+func parseTopics(value string) (map[string]bool, error) {
+	topics := map[string]bool{}
+	if value == "" {
+		for _, topic := range eventTopics {
+			topics[topic] = true
+		}
+		return topics, nil
+	}
+	for _, topic := range strings.Split(value, ",") {
+		topic = strings.TrimSpace(topic)
+		if !slices.Contains(eventTopics, topic) {
+			return nil, fmt.Errorf("unknown topic %q, expected one of %s", topic, strings.Join(eventTopics, ", "))
+		}
+		topics[topic] = true
+	}
+	return topics, nil
+}
+
+// eventsAuthorized checks the bearer token of an event stream request
+func eventsAuthorized(c *fiber.Ctx) bool {
+	if eventsToken == "" {
+		return false
+	}
+	token := c.Query("access_token")
+	if header := c.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimPrefix(header, "Bearer ")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(eventsToken)) == 1
+}
+
+// writeEvent writes one server-sent event. Status samples have no ID so
+// that they do not move the client's Last-Event-ID.
+func writeEvent(w *bufio.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.ID > 0 {
+		fmt.Fprintf(w, "id: %d\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return w.Flush()
+}
+
+// StreamEvents godoc
+// @Summary      Stream events
+// @Description  Server-sent events for applied and failed configuration, nginx reloads, proxy host changes, upstream server health changes, expiring certificates and alerts, plus stub_status counters every 5 seconds on the "status" topic. Reconnecting clients get missed events through Last-Event-ID.
+// @Tags         events
+// @Produce      text/event-stream
+// @Param        topics query string false "Comma separated topics: config, nginx, proxy_hosts, upstreams, certificates, alerts, status (default all)"
+// @Param        Last-Event-ID header int false "Resume after this event"
+// @Param        access_token query string false "Token for clients that cannot set the Authorization header"
+// @Success      200 {object} Event
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Security     Bearer
+// @Router       /events [get]
+func StreamEvents(c *fiber.Ctx) error {
+	if !eventsAuthorized(c) {
+		return respondError(c, 401, "Unauthorized", "A valid event stream token is required")
+	}
+
+	topics, err := parseTopics(c.Query("topics"))
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	lastID := c.Get("Last-Event-ID", c.Query("last_event_id"))
+	var after int64
+	if lastID != "" {
+		if after, err = strconv.ParseInt(lastID, 10, 64); err != nil {
+			return respondError(c, 400, "Invalid request", "Last-Event-ID must be an event ID")
+		}
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	// Keeps nginx in front of the API from buffering the stream
+	c.Set("X-Accel-Buffering", "no")
+
+	sub, missed := events.subscribe(topics, after)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer events.unsubscribe(sub)
+
+		for _, event := range missed {
+			if writeEvent(w, event) != nil {
+				return
+			}
+		}
+		// Lets clients know the stream is open before the first event
+		fmt.Fprint(w, ": connected\n\n")
+		if w.Flush() != nil {
+			return
+		}
+
+		var statusC <-chan time.Time
+		if topics[topicStatus] {
+			ticker := time.NewTicker(statusInterval)
+			defer ticker.Stop()
+			statusC = ticker.C
+		}
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case event, ok := <-sub.ch:
+				if !ok {
+					// Dropped for falling behind, the client resumes with Last-Event-ID
+					return
+				}
+				if writeEvent(w, event) != nil {
+					return
+				}
+			case <-statusC:
+				sample := Event{Topic: topicStatus, Type: "nginx.status", Time: now()}
+				if status, err := nginxStatus(); err != nil {
+					sample.Data = map[string]string{"error": err.Error()}
+				} else {
+					sample.Data = status
+				}
+				if writeEvent(w, sample) != nil {
+					return
+				}
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				if w.Flush() != nil {
+					return
+				}
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+// Header rule directions and actions
+const (
+	headerRequest  = "request"
+	headerResponse = "response"
+
+	headerSet    = "set"
+	headerAdd    = "add"
+	headerRemove = "remove"
+)
+
+// defaultHSTSMaxAge is one year, the minimum accepted by the preload list
+const defaultHSTSMaxAge = 31536000
+
+// HeaderRule sets, adds or removes a request header sent to the backend or a
+// response header sent to the client
+type HeaderRule struct {
+	Direction string `json:"direction" example:"response"`
+	Action    string `json:"action" example:"set"`
+	Name      string `json:"name" example:"X-Frame-Options"`
+	Value     string `json:"value,omitempty" example:"DENY"`
+	Always    bool   `json:"always,omitempty" example:"true"`
+}
+
+// HSTS configures the Strict-Transport-Security header of an SSL-enabled host
+type HSTS struct {
+	MaxAge            int  `json:"max_age,omitempty" example:"31536000"`
+	IncludeSubdomains bool `json:"include_subdomains,omitempty" example:"true"`
+	Preload           bool `json:"preload,omitempty" example:"false"`
+}
+
+// validateHeaderRules checks header rules of a proxy host or location
+func validateHeaderRules(rules []HeaderRule) error {
+	var errs fieldErrors
+	for i, rule := range rules {
+		field := func(name string) string { return fmt.Sprintf("[%d].%s", i, name) }
+
+		if rule.Direction != headerRequest && rule.Direction != headerResponse {
+			errs.add(field("direction"), "%s must be %q or %q", field("direction"), headerRequest, headerResponse)
+		}
+		if !headerNamePattern.MatchString(rule.Name) {
+			errs.add(field("name"), "%s: %q is not a valid header name", field("name"), rule.Name)
+		}
+
+		switch rule.Action {
+		case headerSet, headerAdd:
+			if rule.Value == "" {
+				errs.add(field("value"), "%s is required for %s", field("value"), rule.Action)
+			}
+		case headerRemove:
+			if rule.Value != "" {
+				errs.add(field("value"), "%s is not allowed for %s", field("value"), rule.Action)
+			}
+		default:
+			errs.add(field("action"), "%s must be %q, %q or %q", field("action"), headerSet, headerAdd, headerRemove)
+		}
+
+		if rule.Always && (rule.Direction != headerResponse || rule.Action == headerRemove) {
+			errs.add(field("always"), "%s only applies to response set and add rules", field("always"))
+		}
+	}
+	return errs.err()
+}
+
+// validateHSTS normalizes HSTS settings. Browsers ignore the header on plain
+// HTTP, so it is only accepted for SSL-enabled hosts.
+func validateHSTS(hsts *HSTS, sslEnabled bool) error {
+	if hsts == nil {
+		return nil
+	}
+	if !sslEnabled {
+		return fmt.Errorf("hsts requires ssl_enabled")
+	}
+	if hsts.MaxAge == 0 {
+		hsts.MaxAge = defaultHSTSMaxAge
+	}
+	if hsts.MaxAge < 0 {
+		return fmt.Errorf("hsts max_age must not be negative")
+	}
+	if hsts.Preload && (!hsts.IncludeSubdomains || hsts.MaxAge < defaultHSTSMaxAge) {
+		return fmt.Errorf("hsts preload requires include_subdomains and a max_age of at least %d", defaultHSTSMaxAge)
+	}
+	return nil
+}
+
+// hstsRule turns HSTS settings into a response header rule
+func hstsRule(hsts *HSTS) HeaderRule {
+	value := "max-age=" + strconv.Itoa(hsts.MaxAge)
+	if hsts.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if hsts.Preload {
+		value += "; preload"
+	}
+	return HeaderRule{
+		Direction: headerResponse,
+		Action:    headerSet,
+		Name:      "Strict-Transport-Security",
+		Value:     value,
+		Always:    true,
+	}
+}
+
+// scopedHeaderRule is a header rule together with the map variable that
+// backs it when it appends to a request header
+type scopedHeaderRule struct {
+	HeaderRule
+	variable string
+}
+
+// hostHeaderRules returns the rules of a proxy host including HSTS, the
+// Alt-Svc header of HTTP/3 profiles and the forwarded client certificate DN
+func hostHeaderRules(host ProxyHost) []HeaderRule {
+	var generated []HeaderRule
+	if host.HSTS != nil {
+		generated = append(generated, hstsRule(host.HSTS))
+	}
+	if host.SSLEnabled && host.SSLCertID != nil {
+		if profile, ok := tlsProfileFor(host.TLSProfileID); ok && profile.HTTP3 {
+			generated = append(generated, altSvcRule())
+		}
+	}
+	if host.SSLEnabled && host.ClientAuth != nil && host.ClientAuth.DNHeader != "" {
+		generated = append(generated, clientDNRule(host))
+	}
+	return append(generated, host.HeaderRules...)
+}
+
+// effectiveHeaderRules merges the rules of a proxy host and one of its
+// locations. nginx drops inherited add_header and proxy_set_header as soon
+// as a location defines its own, so host rules are rendered into every
+// location and a location rule replaces host rules for the same header.
+func effectiveHeaderRules(host ProxyHost, index int, loc Location) []scopedHeaderRule {
+	overridden := func(rule HeaderRule) bool {
+		for _, own := range loc.HeaderRules {
+			if own.Direction == rule.Direction && strings.EqualFold(own.Name, rule.Name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var rules []scopedHeaderRule
+	for i, rule := range hostHeaderRules(host) {
+		if !overridden(rule) {
+			rules = append(rules, scopedHeaderRule{rule, headerMapVariable(hostZone(host), i)})
+		}
+	}
+	for i, rule := range loc.HeaderRules {
+		rules = append(rules, scopedHeaderRule{rule, headerMapVariable(locationZone(host, index), i)})
+	}
+	return rules
+}
+
+// headerMapVariable names the map variable of a request add rule
+func headerMapVariable(zone string, index int) string {
+	return "$bs_header_" + zone + "_" + strconv.Itoa(index)
+}
+
+// renderHeaderMaps renders the http-context maps that append a value to a
+// request header, or send the value alone when the client sent none
+func renderHeaderMaps(host ProxyHost) []nginx.Directive {
+	var directives []nginx.Directive
+
+	add := func(zone string, rules []HeaderRule) {
+		for i, rule := range rules {
+			if rule.Direction != headerRequest || rule.Action != headerAdd {
+				continue
+			}
+			source := headerVariable(rule.Name)
+			directives = append(directives, nginx.NewBlock("map", []string{source, headerMapVariable(zone, i)},
+				nginx.Simple(`""`, rule.Value),
+				nginx.Simple("default", source+", "+rule.Value),
+			))
+		}
+	}
+
+	add(hostZone(host), hostHeaderRules(host))
+	for i, loc := range host.Locations {
+		add(locationZone(host, i), loc.HeaderRules)
+	}
+	return directives
+}
+
+// renderHeaderRules renders header rules into location directives
+func renderHeaderRules(rules []scopedHeaderRule) []nginx.Directive {
+	var directives []nginx.Directive
+
+	for _, rule := range rules {
+		if rule.Direction == headerRequest {
+			switch rule.Action {
+			case headerSet:
+				directives = append(directives, nginx.Simple("proxy_set_header", rule.Name, rule.Value))
+			case headerAdd:
+				directives = append(directives, nginx.Simple("proxy_set_header", rule.Name, rule.variable))
+			case headerRemove:
+				directives = append(directives, nginx.Simple("proxy_set_header", rule.Name, ""))
+			}
+			continue
+		}
+
+		// Hide the backend's copy so set replaces rather than duplicates
+		if rule.Action == headerSet || rule.Action == headerRemove {
+			directives = append(directives, nginx.Simple("proxy_hide_header", rule.Name))
+		}
+		if rule.Action == headerSet || rule.Action == headerAdd {
+			args := []string{rule.Name, rule.Value}
+			if rule.Always {
+				args = append(args, "always")
+			}
+			directives = append(directives, nginx.Simple("add_header", args...))
+		}
+	}
+	return directives
+}
+
+// setsRequestHeader reports whether a rule defines the request header name
+func setsRequestHeader(rules []scopedHeaderRule, name string) bool {
+	for _, rule := range rules {
+		if rule.Direction == headerRequest && strings.EqualFold(rule.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Upstream server health as seen by the TCP probe
+const (
+	healthHealthy   = "healthy"
+	healthUnhealthy = "unhealthy"
+)
+
+const (
+	healthInterval = 10 * time.Second
+	healthTimeout  = 2 * time.Second
+)
+
+// healthProbe is the result of probing one upstream server
+type healthProbe struct {
+	server  UpstreamServer
+	healthy bool
+}
+
+// startHealthChecks probes upstream servers in the background. The probe only
+// reports health; nginx keeps its own passive checks through max_fails.
+func startHealthChecks() {
+	go func() {
+		ticker := time.NewTicker(healthInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			healthTick()
+		}
+	}()
+}
+
+// healthTick probes every upstream server and records health changes
+func healthTick() {
+	store.mu.RLock()
+	servers := store.upstreamServers.all()
+	store.mu.RUnlock()
+
+	probes := make([]healthProbe, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			address := net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+			conn, err := net.DialTimeout("tcp", address, healthTimeout)
+			if err == nil {
+				conn.Close()
+			}
+			probes[i] = healthProbe{server: server, healthy: err == nil}
+		}()
+	}
+	wg.Wait()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, probe := range probes {
+		server, ok := store.upstreamServers.get(probe.server.ID)
+		if !ok || server.Host != probe.server.Host || server.Port != probe.server.Port {
+			// Removed or moved while probing
+			continue
+		}
+		health := healthUnhealthy
+		if probe.healthy {
+			health = healthHealthy
+		}
+		if server.Health == health {
+			continue
+		}
+
+		previous := server.Health
+		server.Health = health
+		server.CheckedAt = now()
+		store.upstreamServers.put(server.ID, server)
+
+		eventType := "upstream_server.up"
+		if !probe.healthy {
+			eventType = "upstream_server.down"
+		} else if previous == "" {
+			// A server found healthy by the first probe did not change
+			continue
+		}
+		publishEvent(topicUpstreams, eventType, map[string]any{
+			"upstream_id": server.UpstreamID,
+			"server_id":   server.ID,
+			"address":     net.JoinHostPort(server.Host, strconv.Itoa(server.Port)),
+			"previous":    previous,
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+// Location match types
+const (
+	matchPrefix = "prefix"
+	matchExact  = "exact"
+	matchRegex  = "regex"
+	matchIRegex = "iregex"
+)
+
+// locationModifiers maps match types to nginx location modifiers
+var locationModifiers = map[string]string{
+	matchPrefix: "",
+	matchExact:  "=",
+	matchRegex:  "~",
+	matchIRegex: "~*",
+}
+
+// rewriteFlags lists the flags nginx accepts on a rewrite directive
+var rewriteFlags = []string{"last", "break", "redirect", "permanent"}
+
+// headerNamePattern matches header names nginx can set and read
+var headerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Location represents a custom location block of a proxy host
+type Location struct {
+	Path            string            `json:"path" example:"/api/"`
+	MatchType       string            `json:"match_type" example:"prefix"`
+	ForwardHost     string            `json:"forward_host,omitempty" binding:"host" example:"192.168.1.110"`
+	ForwardPort     int               `json:"forward_port,omitempty" binding:"port" example:"9000"`
+	UpstreamID      *int              `json:"upstream_id,omitempty" example:"2"`
+	AccessListID    *int              `json:"access_list_id,omitempty" example:"1"`
+	RateLimit       *RateLimit        `json:"rate_limit,omitempty"`
+	Rewrite         *Rewrite          `json:"rewrite,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	HeaderRules     []HeaderRule      `json:"header_rules,omitempty"`
+	ExtraDirectives string            `json:"extra_directives,omitempty" example:"client_max_body_size 50m;"`
+}
+
+// Rewrite represents a rewrite rule applied before proxying
+type Rewrite struct {
+	Pattern     string `json:"pattern" example:"^/api/(.*)$"`
+	Replacement string `json:"replacement" example:"/$1"`
+	Flag        string `json:"flag,omitempty" example:"break"`
+}
+
+// validateLocations normalizes and checks the locations of a proxy host.
+// Callers must hold store.mu.
+func validateLocations(locations []Location) error {
+	var errs fieldErrors
+	seen := map[string]bool{}
+
+	for i := range locations {
+		loc := &locations[i]
+		field := func(name string) string { return fmt.Sprintf("[%d].%s", i, name) }
+
+		if loc.MatchType == "" {
+			loc.MatchType = matchPrefix
+		}
+		if _, ok := locationModifiers[loc.MatchType]; !ok {
+			errs.add(field("match_type"), "%s: unknown match_type %q", field("match_type"), loc.MatchType)
+		}
+		if loc.Path == "" {
+			errs.add(field("path"), "%s is required", field("path"))
+		} else if (loc.MatchType == matchPrefix || loc.MatchType == matchExact) && !strings.HasPrefix(loc.Path, "/") {
+			errs.add(field("path"), "%s must start with '/'", field("path"))
+		}
+
+		key := loc.MatchType + " " + loc.Path
+		if seen[key] {
+			errs.add(field("path"), "%s: duplicate %s location %q", field("path"), loc.MatchType, loc.Path)
+		}
+		seen[key] = true
+
+		if loc.UpstreamID != nil {
+			if loc.ForwardHost != "" || loc.ForwardPort != 0 {
+				errs.add(field("upstream_id"), "%s: set either upstream_id or forward_host/forward_port, not both", field("upstream_id"))
+			}
+			if _, ok := store.upstreams.get(*loc.UpstreamID); !ok {
+				errs.add(field("upstream_id"), "%s: upstream %d does not exist", field("upstream_id"), *loc.UpstreamID)
+			} else if len(store.serversOf(*loc.UpstreamID)) == 0 {
+				errs.add(field("upstream_id"), "%s: upstream %d has no servers", field("upstream_id"), *loc.UpstreamID)
+			}
+		}
+		if (loc.ForwardHost == "") != (loc.ForwardPort == 0) {
+			errs.add(field("forward_host"), "%s and forward_port must be set together", field("forward_host"))
+		}
+		errs.check(field("access_list_id"), validateAccessListRef(loc.AccessListID))
+		errs.nest(field("rate_limit"), validateRateLimit(loc.RateLimit))
+
+		if loc.Rewrite != nil {
+			if loc.Rewrite.Pattern == "" || loc.Rewrite.Replacement == "" {
+				errs.add(field("rewrite"), "%s needs a pattern and a replacement", field("rewrite"))
+			}
+			if loc.Rewrite.Flag == "" {
+				loc.Rewrite.Flag = "break"
+			}
+			if !slices.Contains(rewriteFlags, loc.Rewrite.Flag) {
+				errs.add(field("rewrite.flag"), "%s must be one of %s", field("rewrite.flag"), strings.Join(rewriteFlags, ", "))
+			}
+		}
+
+		for name := range loc.Headers {
+			if !headerNamePattern.MatchString(name) {
+				errs.add(field("headers"), "%s: invalid header name %q", field("headers"), name)
+			}
+		}
+		errs.nest(field("header_rules"), validateHeaderRules(loc.HeaderRules))
+
+		if strings.Count(loc.ExtraDirectives, "{") != strings.Count(loc.ExtraDirectives, "}") {
+			errs.add(field("extra_directives"), "%s has unbalanced braces", field("extra_directives"))
+		}
+	}
+
+	return errs.err()
+}
+
+// renderLocations renders the location blocks of a proxy host in the order
+// nginx evaluates them: exact matches, then prefixes from the longest, then
+// regular expressions in the order they were defined. A catch-all "/"
+// forwarding to the host target is added unless the host defines one.
+func renderLocations(host ProxyHost) []nginx.Directive {
+	// index is the position in host.Locations, or -1 for the implicit root
+	type indexed struct {
+		index int
+		loc   Location
+	}
+
+	var exact, prefix, regex []indexed
+	hasRoot := false
+
+	for i, loc := range host.Locations {
+		switch loc.MatchType {
+		case matchExact:
+			exact = append(exact, indexed{i, loc})
+		case matchRegex, matchIRegex:
+			regex = append(regex, indexed{i, loc})
+		default:
+			prefix = append(prefix, indexed{i, loc})
+			if loc.Path == "/" {
+				hasRoot = true
+			}
+		}
+	}
+	if !hasRoot {
+		prefix = append(prefix, indexed{-1, Location{Path: "/", MatchType: matchPrefix}})
+	}
+
+	slices.SortStableFunc(prefix, func(a, b indexed) int {
+		return cmp.Compare(len(b.loc.Path), len(a.loc.Path))
+	})
+
+	var directives []nginx.Directive
+	for _, group := range [][]indexed{exact, prefix, regex} {
+		for _, l := range group {
+			directives = append(directives, renderLocation(host, l.index, l.loc))
+		}
+	}
+	return directives
+}
+
+// renderLocation renders a single location block. index is the position of
+// the location in host.Locations and names its rate limit zones.
+func renderLocation(host ProxyHost, index int, loc Location) nginx.Directive {
+	var args []string
+	if modifier := locationModifiers[loc.MatchType]; modifier != "" {
+		args = append(args, modifier)
+	}
+	args = append(args, loc.Path)
+
+	body := renderMaintenanceCheck(host)
+	body = append(body, renderAccessList(loc.AccessListID)...)
+	body = append(body, renderRateLimit(locationZone(host, index), loc.RateLimit)...)
+	if loc.Rewrite != nil {
+		body = append(body, nginx.Simple("rewrite", loc.Rewrite.Pattern, loc.Rewrite.Replacement, loc.Rewrite.Flag))
+	}
+
+	// Custom headers replace the standard forwarding header of the same name
+	rules := effectiveHeaderRules(host, index, loc)
+	forward := append(proxyDirectives(locationTarget(host, loc), backendScheme(host.Backend)), renderWebSocket(host)...)
+	for _, d := range forward {
+		if d.Name == "proxy_set_header" && (hasHeader(loc.Headers, d.Args[0]) || setsRequestHeader(rules, d.Args[0])) {
+			continue
+		}
+		body = append(body, d)
+	}
+	for _, name := range slices.Sorted(maps.Keys(loc.Headers)) {
+		body = append(body, nginx.Simple("proxy_set_header", name, loc.Headers[name]))
+	}
+	body = append(body, renderHeaderRules(rules)...)
+	if loc.ExtraDirectives != "" {
+		body = append(body, nginx.Raw(loc.ExtraDirectives))
+	}
+	if isGRPC(host.Backend) {
+		body = grpcLocation(body)
+	}
+
+	return nginx.NewBlock("location", args, body...)
+}
+
+// locationTarget returns the backend of a location, falling back to the
+// target of the proxy host
+func locationTarget(host ProxyHost, loc Location) proxyTarget {
+	if loc.UpstreamID != nil {
+		if upstream, ok := store.upstreams.get(*loc.UpstreamID); ok {
+			return proxyTarget{upstream: &upstream}
+		}
+	}
+	if loc.ForwardHost != "" {
+		return proxyTarget{host: loc.ForwardHost, port: loc.ForwardPort}
+	}
+	return hostTarget(host)
+}
+
+// headerVariable returns the nginx variable holding a request header
+func headerVariable(name string) string {
+	return "$http_" + strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// hasHeader reports whether headers contains name, ignoring case
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// logTailInterval is how often a followed log is checked for new lines
+	logTailInterval = 500 * time.Millisecond
+	// defaultLogRate and maxLogRate cap the lines streamed per second
+	defaultLogRate = 50
+	maxLogRate     = 1000
+)
+
+// errorLogClientPattern and errorLogRequestPattern pick the client address and
+// request line out of an nginx error log line
+var (
+	errorLogClientPattern  = regexp.MustCompile(`client: ([^,\s]+)`)
+	errorLogRequestPattern = regexp.MustCompile(`request: "\S+ (\S+)`)
+)
+
+// logFilter selects the lines of a log stream
+type logFilter struct {
+	statusMin  int
+	statusMax  int
+	path       *regexp.Regexp
+	client     *netip.Prefix
+	minLatency time.Duration
+}
+
+// parseStatusRange reads "502", "5xx" or "500-599"
+func parseStatusRange(value string) (int, int, error) {
+	invalid := fmt.Errorf("status must be a code, a class such as 5xx or a range such as 500-504")
+	if len(value) == 3 && strings.HasSuffix(value, "xx") {
+		class, err := strconv.Atoi(value[:1])
+		if err != nil || class < 1 || class > 5 {
+			return 0, 0, invalid
+		}
+		return class * 100, class*100 + 99, nil
+	}
+
+	low, high, isRange := strings.Cut(value, "-")
+	if !isRange {
+		high = low
+	}
+	from, err := strconv.Atoi(low)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	to, err := strconv.Atoi(high)
+	if err != nil || from < 100 || to > 599 || from > to {
+		return 0, 0, invalid
+	}
+	return from, to, nil
+}
+
+// parseLogFilter reads the filters of a log stream request
+func parseLogFilter(c *fiber.Ctx, source string) (logFilter, error) {
+	var filter logFilter
+
+	if value := c.Query("status"); value != "" {
+		if source != "access" {
+			return filter, fmt.Errorf("status only filters the access log")
+		}
+		from, to, err := parseStatusRange(value)
+		if err != nil {
+			return filter, err
+		}
+		filter.statusMin, filter.statusMax = from, to
+	}
+
+	if value := c.Query("path"); value != "" {
+		path, err := regexp.Compile(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid path pattern: %w", err)
+		}
+		filter.path = path
+	}
+
+	if value := c.Query("ip"); value != "" {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return filter, fmt.Errorf("ip must be an address or CIDR range")
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefix = prefix.Masked()
+		filter.client = &prefix
+	}
+
+	if value := c.Query("min_latency"); value != "" {
+		if source != "access" {
+			return filter, fmt.Errorf("min_latency only filters the access log")
+		}
+		latency, err := time.ParseDuration(value)
+		if err != nil || latency < 0 {
+			return filter, fmt.Errorf("min_latency must be a duration such as 250ms")
+		}
+		filter.minLatency = latency
+	}
+	return filter, nil
+}
+
+// matchClient reports whether an address falls into the client filter
+func (f logFilter) matchClient(address string) bool {
+	if f.client == nil {
+		return true
+	}
+	addr, err := netip.ParseAddr(address)
+	return err == nil && f.client.Contains(addr.Unmap())
+}
+
+// matchAccess reports whether an access log entry passes the filter
+func (f logFilter) matchAccess(entry accessLogEntry) bool {
+	if f.statusMax > 0 {
+		status, _ := strconv.Atoi(entry.Status)
+		if status < f.statusMin || status > f.statusMax {
+			return false
+		}
+	}
+	if f.path != nil && !f.path.MatchString(entry.URI) {
+		return false
+	}
+	if f.minLatency > 0 {
+		seconds, _ := strconv.ParseFloat(entry.RequestTime, 64)
+		if time.Duration(seconds*float64(time.Second)) < f.minLatency {
+			return false
+		}
+	}
+	return f.matchClient(entry.RemoteAddr)
+}
+
+// matchError reports whether an error log line passes the filter. Lines
+// without a client or request fail the filters that need them.
+func (f logFilter) matchError(line string) bool {
+	if f.path != nil {
+		match := errorLogRequestPattern.FindStringSubmatch(line)
+		if match == nil || !f.path.MatchString(match[1]) {
+			return false
+		}
+	}
+	if f.client != nil {
+		match := errorLogClientPattern.FindStringSubmatch(line)
+		if match == nil || !f.matchClient(match[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// StreamProxyHostLogs godoc
+// @Summary      Stream proxy host logs
+// @Description  Follow the access or error log of a proxy host as server-sent events. Access lines are sent as "access" events with the parsed JSON entry, error lines as "error" events. Lines above the rate cap are dropped and counted in a "dropped" event.
+// @Tags         proxy-hosts
+// @Produce      text/event-stream
+// @Param        id path int true "Proxy Host ID"
+// @Param        log query string false "access (default) or error"
+// @Param        status query string false "Status code, class or range, for example 502, 5xx or 500-504 (access log only)"
+// @Param        path query string false "Regular expression matched against the request URI"
+// @Param        ip query string false "Client address or CIDR range"
+// @Param        min_latency query string false "Minimum request time, for example 250ms (access log only)"
+// @Param        rate query int false "Maximum lines per second (default 50, max 1000)"
+// @Success      200 {string} string
+// @Failure      400 {object} ErrorResponse
+// @Failure      401 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Security     Bearer
+// @Router       /proxy-hosts/{id}/logs/stream [get]
+func StreamProxyHostLogs(c *fiber.Ctx) error {
+	if !eventsAuthorized(c) {
+		return respondError(c, 401, "Unauthorized", "A valid event stream token is required")
+	}
+
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	source := c.Query("log", "access")
+	if source != "access" && source != "error" {
+		return respondError(c, 400, "Invalid request", `log must be "access" or "error"`)
+	}
+	filter, err := parseLogFilter(c, source)
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	rate := c.QueryInt("rate", defaultLogRate)
+	if rate < 1 || rate > maxLogRate {
+		return respondError(c, 400, "Invalid request", fmt.Sprintf("rate must be between 1 and %d", maxLogRate))
+	}
+
+	store.mu.RLock()
+	host, ok := store.proxyHosts.get(id)
+	store.mu.RUnlock()
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+
+	path := accessLogPath(host)
+	if source == "error" {
+		path = errorLogPath(host)
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	tail := newLogTailAtEnd(path)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		fmt.Fprint(w, ": connected\n\n")
+		if w.Flush() != nil {
+			return
+		}
+
+		poll := time.NewTicker(logTailInterval)
+		defer poll.Stop()
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		window := time.Now()
+		sent, dropped := 0, 0
+		for {
+			select {
+			case <-poll.C:
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+				if w.Flush() != nil {
+					return
+				}
+				continue
+			}
+
+			if time.Since(window) >= time.Second {
+				if dropped > 0 {
+					fmt.Fprintf(w, "event: dropped\ndata: {\"count\":%d}\n\n", dropped)
+				}
+				window = time.Now()
+				sent, dropped = 0, 0
+			}
+
+			// A missing log just means nothing was written yet
+			_ = tail.read(path, func(line []byte) {
+				line = bytes.TrimSpace(line)
+				var data []byte
+				if source == "access" {
+					var entry accessLogEntry
+					if json.Unmarshal(line, &entry) != nil || !filter.matchAccess(entry) {
+						return
+					}
+					data = line
+				} else {
+					if !filter.matchError(string(line)) {
+						return
+					}
+					data, _ = json.Marshal(map[string]string{"line": string(line)})
+				}
+
+				if sent >= rate {
+					dropped++
+					return
+				}
+				sent++
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", source, data)
+			})
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+	return nil
+}
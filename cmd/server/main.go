@@ -1,7 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -27,14 +32,82 @@ import (
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
 
+// @tag.name         system
+// @tag.description  System operations
+
+// @tag.name         proxy-hosts
+// @tag.description  Proxy host management
+
+// @tag.name         redirection-hosts
+// @tag.description  Redirection host management
+
+// @tag.name         streams
+// @tag.description  TCP/UDP stream proxying
+
+// @tag.name         access-lists
+// @tag.description  IP rules and basic authentication
+
+// @tag.name         tls-profiles
+// @tag.description  TLS policy profiles
+
+// @tag.name         ca-bundles
+// @tag.description  CA bundles for client certificate verification
+
+// @tag.name         certificates
+// @tag.description  SSL certificate management
+
+// @tag.name         upstreams
+// @tag.description  Upstream server management
+
+// @tag.name         nginx
+// @tag.description  Nginx control operations
+
+// @tag.name         schedules
+// @tag.description  Scheduled configuration changes
+
+// @tag.name         analytics
+// @tag.description  Traffic analytics from access logs
+
+// @tag.name         events
+// @tag.description  Real-time event stream
+
+// @tag.name         webhooks
+// @tag.description  Outgoing webhooks for events
+
+// @tag.name         alerts
+// @tag.description  Alert rules and notifiers
+
+// @tag.name         conflicts
+// @tag.description  Overlapping domain names and ports
+
 func main() {
+	app := newApp()
+
+	startScheduler()
+	startAnalytics()
+	startHealthChecks()
+	startCertificateChecks()
+	startWebhooks()
+	startAlerts()
+
+	if eventsToken == "" {
+		log.Println("⚠️  EVENTS_TOKEN is not set, event and log streams are disabled")
+	}
+	log.Println("🚀 Balancer Studio starting on http://localhost:3000")
+	log.Println("📚 API Documentation: http://localhost:3000/docs")
+	log.Fatal(app.Listen(":3000"))
+}
+
+// newApp creates the app with its middleware and routes
+func newApp() *fiber.App {
 	app := fiber.New(fiber.Config{
 		AppName: "Balancer Studio v1.0",
 	})
 
 	// Middleware
 	app.Use(logger.New())
-	app.Use(cors.New())
+	// Browsers hide response headers from scripts unless they are exposed
+	app.Use(cors.New(cors.Config{ExposeHeaders: fiber.HeaderETag}))
 
 	// Serve Scalar API Documentation
 	app.Get("/docs", func(c *fiber.Ctx) error {
@@ -63,7 +136,7 @@ func main() {
 
 	// Serve OpenAPI JSON
 	app.Get("/swagger.json", func(c *fiber.Ctx) error {
-		return c.JSON(getOpenAPISpec())
+		return c.Type("json").Send(openAPISpec)
 	})
 
 	// API Routes
@@ -78,7 +151,60 @@ func main() {
 	proxyHosts.Post("/", CreateProxyHost)
 	proxyHosts.Get("/:id", GetProxyHost)
 	proxyHosts.Put("/:id", UpdateProxyHost)
+	proxyHosts.Patch("/:id", PatchProxyHost)
 	proxyHosts.Delete("/:id", DeleteProxyHost)
+	proxyHosts.Post("/:id/switch", SwitchProxyHost)
+	proxyHosts.Post("/:id/switch/revert", RevertProxyHostSwitch)
+	proxyHosts.Get("/:id/switches", ListProxyHostSwitches)
+	proxyHosts.Post("/:id/cache/purge", PurgeProxyHostCache)
+	proxyHosts.Put("/:id/maintenance", EnableMaintenance)
+	proxyHosts.Delete("/:id/maintenance", DisableMaintenance)
+	proxyHosts.Get("/:id/logs/stream", StreamProxyHostLogs)
+
+	// Redirection Hosts routes
+	redirectionHosts := api.Group("/redirection-hosts")
+	redirectionHosts.Get("/", ListRedirectionHosts)
+	redirectionHosts.Post("/", CreateRedirectionHost)
+	redirectionHosts.Get("/:id", GetRedirectionHost)
+	redirectionHosts.Put("/:id", UpdateRedirectionHost)
+	redirectionHosts.Patch("/:id", PatchRedirectionHost)
+	redirectionHosts.Delete("/:id", DeleteRedirectionHost)
+
+	// Access Lists routes
+	accessLists := api.Group("/access-lists")
+	accessLists.Get("/", ListAccessLists)
+	accessLists.Post("/", CreateAccessList)
+	accessLists.Get("/:id", GetAccessList)
+	accessLists.Put("/:id", UpdateAccessList)
+	accessLists.Patch("/:id", PatchAccessList)
+	accessLists.Delete("/:id", DeleteAccessList)
+
+	// Streams routes
+	streams := api.Group("/streams")
+	streams.Get("/", ListStreams)
+	streams.Post("/", CreateStream)
+	streams.Get("/:id", GetStream)
+	streams.Put("/:id", UpdateStream)
+	streams.Patch("/:id", PatchStream)
+	streams.Delete("/:id", DeleteStream)
+
+	// TLS profiles routes
+	tlsProfiles := api.Group("/tls-profiles")
+	tlsProfiles.Get("/", ListTLSProfiles)
+	tlsProfiles.Post("/", CreateTLSProfile)
+	tlsProfiles.Get("/deprecated-hosts", ListDeprecatedTLSHosts)
+	tlsProfiles.Put("/:id", UpdateTLSProfile)
+	tlsProfiles.Patch("/:id", PatchTLSProfile)
+	tlsProfiles.Delete("/:id", DeleteTLSProfile)
+
+	// CA bundles routes
+	caBundles := api.Group("/ca-bundles")
+	caBundles.Get("/", ListCABundles)
+	caBundles.Post("/", CreateCABundle)
+	caBundles.Get("/:id", GetCABundle)
+	caBundles.Put("/:id", UpdateCABundle)
+	caBundles.Patch("/:id", PatchCABundle)
+	caBundles.Delete("/:id", DeleteCABundle)
 
 	// SSL Certificates routes
 	certificates := api.Group("/certificates")
@@ -90,17 +216,67 @@ func main() {
 	nginx.Post("/reload", ReloadNginx)
 	nginx.Post("/test", TestNginxConfig)
 	nginx.Get("/status", GetNginxStatus)
+	nginx.Get("/config", GetNginxConfig)
+	nginx.Post("/apply", ApplyNginxConfig)
+	nginx.Get("/default-server", GetDefaultServer)
+	nginx.Put("/default-server", UpdateDefaultServer)
+
+	// Scheduled changes
+	schedules := api.Group("/schedules")
+	schedules.Get("/", ListSchedules)
+	schedules.Post("/", CreateSchedule)
+	schedules.Get("/:id", GetSchedule)
+	schedules.Delete("/:id", CancelSchedule)
+
+	// Traffic analytics
+	analyticsRoutes := api.Group("/analytics")
+	analyticsRoutes.Get("/proxy-hosts", ListHostTraffic)
+	analyticsRoutes.Get("/proxy-hosts/:id", GetHostTraffic)
+	analyticsRoutes.Get("/upstream-servers", ListServerTraffic)
+
+	// Event stream
+	api.Get("/events", StreamEvents)
+
+	// Webhooks
+	webhooks := api.Group("/webhooks")
+	webhooks.Get("/", ListWebhooks)
+	webhooks.Post("/", CreateWebhook)
+	webhooks.Get("/:id", GetWebhook)
+	webhooks.Put("/:id", UpdateWebhook)
+	webhooks.Patch("/:id", PatchWebhook)
+	webhooks.Delete("/:id", DeleteWebhook)
+	webhooks.Get("/:id/deliveries", ListWebhookDeliveries)
+	webhooks.Post("/:id/deliveries/:deliveryId/redeliver", RedeliverWebhook)
+
+	// Alerting
+	notifiers := api.Group("/notifiers")
+	notifiers.Get("/", ListNotifiers)
+	notifiers.Post("/", CreateNotifier)
+	notifiers.Put("/:id", UpdateNotifier)
+	notifiers.Patch("/:id", PatchNotifier)
+	notifiers.Delete("/:id", DeleteNotifier)
+	notifiers.Post("/:id/test", TestNotifier)
+	alertRules := api.Group("/alert-rules")
+	alertRules.Get("/", ListAlertRules)
+	alertRules.Post("/", CreateAlertRule)
+	alertRules.Put("/:id", UpdateAlertRule)
+	alertRules.Patch("/:id", PatchAlertRule)
+	alertRules.Delete("/:id", DeleteAlertRule)
+	api.Get("/alerts", ListAlerts)
+
+	// Conflicts
+	api.Get("/conflicts", ListConflicts)
 
 	// Upstream servers management
 	upstreams := api.Group("/upstreams")
 	upstreams.Get("/", ListUpstreams)
 	upstreams.Post("/", CreateUpstream)
+	upstreams.Put("/:id", UpdateUpstream)
+	upstreams.Patch("/:id", PatchUpstream)
 	upstreams.Get("/:id/servers", ListUpstreamServers)
 	upstreams.Post("/:id/servers", AddUpstreamServer)
 
-	log.Println("🚀 Balancer Studio starting on http://localhost:3000")
-	log.Println("📚 API Documentation: http://localhost:3000/docs")
-	log.Fatal(app.Listen(":3000"))
+	return app
 }
 
 // HealthCheck godoc
@@ -118,25 +294,52 @@ func HealthCheck(c *fiber.Ctx) error {
 	})
 }
 
+// upstreamNamePattern matches names nginx accepts for upstream blocks
+var upstreamNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 // ProxyHost represents a proxy host configuration
 type ProxyHost struct {
-	ID          int      `json:"id" example:"1"`
-	DomainNames []string `json:"domain_names" example:"example.com,www.example.com"`
-	ForwardHost string   `json:"forward_host" example:"192.168.1.100"`
-	ForwardPort int      `json:"forward_port" example:"8080"`
-	SSLEnabled  bool     `json:"ssl_enabled" example:"true"`
-	SSLCertID   *int     `json:"ssl_cert_id,omitempty" example:"1"`
-	Enabled     bool     `json:"enabled" example:"true"`
-	CreatedAt   string   `json:"created_at" example:"2025-12-08T10:00:00Z"`
+	ID int `json:"id" example:"1"`
+	Revision
+	DomainNames  []string        `json:"domain_names" example:"example.com,www.example.com"`
+	ForwardHost  string          `json:"forward_host" example:"192.168.1.100"`
+	ForwardPort  int             `json:"forward_port" example:"8080"`
+	SSLEnabled   bool            `json:"ssl_enabled" example:"true"`
+	SSLCertID    *int            `json:"ssl_cert_id,omitempty" example:"1"`
+	BlueGreen    *BlueGreen      `json:"blue_green,omitempty"`
+	Locations    []Location      `json:"locations,omitempty"`
+	AccessListID *int            `json:"access_list_id,omitempty" example:"1"`
+	RateLimit    *RateLimit      `json:"rate_limit,omitempty"`
+	Cache        *CacheConfig    `json:"cache,omitempty"`
+	HeaderRules  []HeaderRule    `json:"header_rules,omitempty"`
+	HSTS         *HSTS           `json:"hsts,omitempty"`
+	TLSProfileID *int            `json:"tls_profile_id,omitempty" example:"2"`
+	ClientAuth   *ClientAuth     `json:"client_auth,omitempty"`
+	Backend      *BackendOptions `json:"backend,omitempty"`
+	ErrorPages   *ErrorPages     `json:"error_pages,omitempty"`
+	Maintenance  *Maintenance    `json:"maintenance,omitempty"`
+	Enabled      bool            `json:"enabled" example:"true"`
+	CreatedAt    string          `json:"created_at" example:"2025-12-08T10:00:00Z"`
 }
 
 // ProxyHostRequest represents the request body for creating/updating proxy hosts
 type ProxyHostRequest struct {
-	DomainNames []string `json:"domain_names" binding:"required" example:"example.com"`
-	ForwardHost string   `json:"forward_host" binding:"required" example:"192.168.1.100"`
-	ForwardPort int      `json:"forward_port" binding:"required" example:"8080"`
-	SSLEnabled  bool     `json:"ssl_enabled" example:"false"`
-	SSLCertID   *int     `json:"ssl_cert_id,omitempty" example:"1"`
+	DomainNames  []string        `json:"domain_names" binding:"required,domain" example:"example.com"`
+	ForwardHost  string          `json:"forward_host" binding:"required,host" example:"192.168.1.100"`
+	ForwardPort  int             `json:"forward_port" binding:"required,port" example:"8080"`
+	SSLEnabled   bool            `json:"ssl_enabled" example:"false"`
+	SSLCertID    *int            `json:"ssl_cert_id,omitempty" example:"1"`
+	BlueGreen    *BlueGreen      `json:"blue_green,omitempty"`
+	Locations    []Location      `json:"locations,omitempty"`
+	AccessListID *int            `json:"access_list_id,omitempty" example:"1"`
+	RateLimit    *RateLimit      `json:"rate_limit,omitempty"`
+	Cache        *CacheConfig    `json:"cache,omitempty"`
+	HeaderRules  []HeaderRule    `json:"header_rules,omitempty"`
+	HSTS         *HSTS           `json:"hsts,omitempty"`
+	TLSProfileID *int            `json:"tls_profile_id,omitempty" example:"2"`
+	ClientAuth   *ClientAuth     `json:"client_auth,omitempty"`
+	Backend      *BackendOptions `json:"backend,omitempty"`
+	ErrorPages   *ErrorPages     `json:"error_pages,omitempty"`
 }
 
 // Certificate represents an SSL certificate
@@ -151,58 +354,129 @@ type Certificate struct {
 
 // Upstream represents an upstream server group
 type Upstream struct {
-	ID          int    `json:"id" example:"1"`
-	Name        string `json:"name" example:"backend"`
-	Algorithm   string `json:"algorithm" example:"round_robin"`
-	Description string `json:"description" example:"Backend application servers"`
+	ID int `json:"id" example:"1"`
+	Revision
+	Name        string    `json:"name" example:"backend"`
+	Algorithm   string    `json:"algorithm" example:"round_robin"`
+	Description string    `json:"description" example:"Backend application servers"`
+	Affinity    *Affinity `json:"affinity,omitempty"`
+}
+
+// UpstreamRequest represents the request body for creating/updating upstream groups
+type UpstreamRequest struct {
+	Name        string    `json:"name" binding:"required" example:"backend"`
+	Algorithm   string    `json:"algorithm" example:"round_robin"`
+	Description string    `json:"description" example:"Backend application servers"`
+	Affinity    *Affinity `json:"affinity,omitempty"`
 }
 
 // UpstreamServer represents a server in an upstream group
 type UpstreamServer struct {
-	ID       int    `json:"id" example:"1"`
-	Host     string `json:"host" example:"192.168.1.100"`
-	Port     int    `json:"port" example:"8080"`
+	ID         int    `json:"id" example:"1"`
+	UpstreamID int    `json:"upstream_id" example:"1"`
+	Host       string `json:"host" example:"192.168.1.100"`
+	Port       int    `json:"port" example:"8080"`
+	Weight     int    `json:"weight" example:"1"`
+	MaxFails   int    `json:"max_fails" example:"3"`
+	Status     string `json:"status" example:"up"`
+	// Health is the result of the last TCP probe, empty until the first one
+	Health    string `json:"health,omitempty" example:"healthy"`
+	CheckedAt string `json:"checked_at,omitempty" example:"2025-12-08T10:00:00Z"`
+}
+
+// UpstreamServerRequest represents the request body for adding servers to a group
+type UpstreamServerRequest struct {
+	Host     string `json:"host" binding:"required,host" example:"192.168.1.102"`
+	Port     int    `json:"port" binding:"required,port" example:"8080"`
 	Weight   int    `json:"weight" example:"1"`
 	MaxFails int    `json:"max_fails" example:"3"`
-	Status   string `json:"status" example:"up"`
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error   string `json:"error" example:"Invalid request"`
-	Message string `json:"message" example:"Domain names are required"`
+	Message string `json:"message" example:"domain_names is required"`
+	// Fields lists every invalid field of a request body
+	Fields []FieldError `json:"fields,omitempty"`
+	// Conflicts lists the resources that already claim a domain name or port
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+}
+
+// respondError sends an ErrorResponse with the given status code
+func respondError(c *fiber.Ctx, status int, err, message string) error {
+	return c.Status(status).JSON(ErrorResponse{
+		Error:   err,
+		Message: message,
+	})
+}
+
+// paramID parses a numeric path parameter
+func paramID(c *fiber.Ctx, name string) (int, error) {
+	id, err := strconv.Atoi(c.Params(name))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return id, nil
+}
+
+// validateProxyHost checks the references and nested settings of a proxy
+// host request, reporting every invalid field. Callers must hold store.mu.
+func validateProxyHost(req *ProxyHostRequest) error {
+	var errs fieldErrors
+	errs.check("ssl_cert_id", validateCertificateRef(req.SSLCertID))
+	errs.nest("blue_green", validateBlueGreen(req.BlueGreen))
+	errs.nest("locations", validateLocations(req.Locations))
+	errs.check("access_list_id", validateAccessListRef(req.AccessListID))
+	errs.nest("rate_limit", validateRateLimit(req.RateLimit))
+	errs.nest("cache", validateCache(req.Cache))
+	errs.nest("header_rules", validateHeaderRules(req.HeaderRules))
+	errs.check("hsts", validateHSTS(req.HSTS, req.SSLEnabled))
+	errs.check("tls_profile_id", validateTLSProfileRef(req.TLSProfileID, req.SSLEnabled))
+	errs.nest("client_auth", validateClientAuth(req.ClientAuth, req.SSLEnabled))
+	errs.nest("backend", validateBackend(req.Backend, req.Cache))
+	errs.nest("error_pages", validateErrorPages(req.ErrorPages))
+	return errs.err()
 }
 
 // ListProxyHosts godoc
 // @Summary      List all proxy hosts
-// @Description  Get a list of all configured proxy hosts
+// @Description  Get a page of configured proxy hosts
 // @Tags         proxy-hosts
 // @Produce      json
-// @Success      200 {array} ProxyHost
+// @Param        domain query string false "Domain name substring"
+// @Param        enabled query bool false "Enabled filter"
+// @Param        ssl_enabled query bool false "SSL filter"
+// @Param        sort query string false "id, domain, forward_host or created_at, prefix with '-' for descending"
+// @Param        limit query int false "Page size (default 50, max 500)"
+// @Param        offset query int false "Rows to skip"
+// @Success      200 {object} Page[ProxyHost]
+// @Failure      400 {object} ErrorResponse
 // @Router       /proxy-hosts [get]
 func ListProxyHosts(c *fiber.Ctx) error {
-	// Mock data - replace with database query
-	hosts := []ProxyHost{
-		{
-			ID:          1,
-			DomainNames: []string{"example.com", "www.example.com"},
-			ForwardHost: "192.168.1.100",
-			ForwardPort: 8080,
-			SSLEnabled:  true,
-			Enabled:     true,
-			CreatedAt:   "2025-12-08T10:00:00Z",
-		},
-		{
-			ID:          2,
-			DomainNames: []string{"api.example.com"},
-			ForwardHost: "192.168.1.101",
-			ForwardPort: 3000,
-			SSLEnabled:  true,
-			Enabled:     true,
-			CreatedAt:   "2025-12-08T11:00:00Z",
-		},
-	}
-	return c.JSON(hosts)
+	domain := c.Query("domain")
+	enabled, err := queryBool(c, "enabled")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	sslEnabled, err := queryBool(c, "ssl_enabled")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	hosts := filter(store.proxyHosts.all(), func(host ProxyHost) bool {
+		if domain != "" && !slices.ContainsFunc(host.DomainNames, func(name string) bool { return containsFold(name, domain) }) {
+			return false
+		}
+		return (enabled == nil || host.Enabled == *enabled) && (sslEnabled == nil || host.SSLEnabled == *sslEnabled)
+	})
+	page, err := paginate(c, hosts, proxyHostSortKeys)
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	return c.JSON(page)
 }
 
 // CreateProxyHost godoc
@@ -213,29 +487,55 @@ func ListProxyHosts(c *fiber.Ctx) error {
 // @Produce      json
 // @Param        host body ProxyHostRequest true "Proxy Host Configuration"
 // @Success      201 {object} ProxyHost
+// @Header       201 {string} ETag "Version of the proxy host"
 // @Failure      400 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
 // @Router       /proxy-hosts [post]
 func CreateProxyHost(c *fiber.Ctx) error {
 	var req ProxyHostRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-		})
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := validateProxyHost(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+	conflicts := domainConflicts(resourceProxyHost, 0, req.DomainNames)
+	if servesHTTP3(req.SSLEnabled, req.SSLCertID, req.TLSProfileID) {
+		conflicts = append(conflicts, http3Conflicts(ConflictSide{Type: resourceProxyHost})...)
+	}
+	if len(conflicts) > 0 {
+		return respondConflicts(c, conflicts)
 	}
 
-	// Mock response - replace with actual logic
 	host := ProxyHost{
-		ID:          3,
-		DomainNames: req.DomainNames,
-		ForwardHost: req.ForwardHost,
-		ForwardPort: req.ForwardPort,
-		SSLEnabled:  req.SSLEnabled,
-		SSLCertID:   req.SSLCertID,
-		Enabled:     true,
-		CreatedAt:   "2025-12-08T12:00:00Z",
+		ID:           store.proxyHosts.newID(),
+		DomainNames:  req.DomainNames,
+		ForwardHost:  req.ForwardHost,
+		ForwardPort:  req.ForwardPort,
+		SSLEnabled:   req.SSLEnabled,
+		SSLCertID:    req.SSLCertID,
+		BlueGreen:    req.BlueGreen,
+		Locations:    req.Locations,
+		AccessListID: req.AccessListID,
+		RateLimit:    req.RateLimit,
+		Cache:        req.Cache,
+		HeaderRules:  req.HeaderRules,
+		HSTS:         req.HSTS,
+		TLSProfileID: req.TLSProfileID,
+		ClientAuth:   req.ClientAuth,
+		Backend:      req.Backend,
+		ErrorPages:   req.ErrorPages,
+		Enabled:      true,
+		CreatedAt:    now(),
 	}
+	host = store.proxyHosts.put(host.ID, host)
+	publishEvent(topicProxyHosts, "proxy_host.created", host)
 
+	setETag(c, host.Version)
 	return c.Status(201).JSON(host)
 }
 
@@ -246,74 +546,178 @@ func CreateProxyHost(c *fiber.Ctx) error {
 // @Produce      json
 // @Param        id path int true "Proxy Host ID"
 // @Success      200 {object} ProxyHost
+// @Header       200 {string} ETag "Version of the proxy host"
 // @Failure      404 {object} ErrorResponse
 // @Router       /proxy-hosts/{id} [get]
 func GetProxyHost(c *fiber.Ctx) error {
-	_ = c.Params("id")
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
 
-	// Mock response
-	host := ProxyHost{
-		ID:          1,
-		DomainNames: []string{"example.com"},
-		ForwardHost: "192.168.1.100",
-		ForwardPort: 8080,
-		SSLEnabled:  true,
-		Enabled:     true,
-		CreatedAt:   "2025-12-08T10:00:00Z",
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	host, ok := store.proxyHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
 	}
 
+	setETag(c, host.Version)
 	return c.JSON(host)
 }
 
 // UpdateProxyHost godoc
 // @Summary      Update a proxy host
-// @Description  Update an existing proxy host configuration
+// @Description  Replace the configuration of a proxy host. With If-Match the update fails with 412 when the host changed since that version was read.
 // @Tags         proxy-hosts
 // @Accept       json
 // @Produce      json
 // @Param        id path int true "Proxy Host ID"
+// @Param        If-Match header string false "ETag of the version being updated"
 // @Param        host body ProxyHostRequest true "Updated Proxy Host Configuration"
 // @Success      200 {object} ProxyHost
+// @Header       200 {string} ETag "Version of the proxy host"
 // @Failure      400 {object} ErrorResponse
 // @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
 // @Router       /proxy-hosts/{id} [put]
 func UpdateProxyHost(c *fiber.Ctx) error {
-	_ = c.Params("id")
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
 
 	var req ProxyHostRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(ErrorResponse{
-			Error:   "Invalid request",
-			Message: err.Error(),
-		})
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
 	}
 
-	// Mock response
-	host := ProxyHost{
-		ID:          1,
-		DomainNames: req.DomainNames,
-		ForwardHost: req.ForwardHost,
-		ForwardPort: req.ForwardPort,
-		SSLEnabled:  req.SSLEnabled,
-		SSLCertID:   req.SSLCertID,
-		Enabled:     true,
-		CreatedAt:   "2025-12-08T10:00:00Z",
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	host, ok := store.proxyHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+	if err := checkIfMatch(c, host.Version); err != nil {
+		return respondStale(c, host.Version, err)
+	}
+	if err := validateProxyHost(&req); err != nil {
+		return respondInvalid(c, err)
 	}
+	conflicts := domainConflicts(resourceProxyHost, id, req.DomainNames)
+	if servesHTTP3(req.SSLEnabled, req.SSLCertID, req.TLSProfileID) {
+		conflicts = append(conflicts, http3Conflicts(ConflictSide{Type: resourceProxyHost, ID: id})...)
+	}
+	if len(conflicts) > 0 {
+		return respondConflicts(c, conflicts)
+	}
+
+	host.DomainNames = req.DomainNames
+	host.ForwardHost = req.ForwardHost
+	host.ForwardPort = req.ForwardPort
+	host.SSLEnabled = req.SSLEnabled
+	host.SSLCertID = req.SSLCertID
+	host.BlueGreen = req.BlueGreen
+	host.Locations = req.Locations
+	host.AccessListID = req.AccessListID
+	host.RateLimit = req.RateLimit
+	host.Cache = req.Cache
+	host.HeaderRules = req.HeaderRules
+	host.HSTS = req.HSTS
+	host.TLSProfileID = req.TLSProfileID
+	host.ClientAuth = req.ClientAuth
+	host.Backend = req.Backend
+	host.ErrorPages = req.ErrorPages
+	host = store.proxyHosts.put(host.ID, host)
+	publishEvent(topicProxyHosts, "proxy_host.updated", host)
 
+	setETag(c, host.Version)
 	return c.JSON(host)
 }
 
+// PatchProxyHost godoc
+// @Summary      Partially update a proxy host
+// @Description  Change some fields of a proxy host with a JSON merge patch (RFC 7396): fields that are left out keep their value and null clears a field. The result is validated like a full update.
+// @Tags         proxy-hosts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Proxy Host ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        host body ProxyHostRequest true "Fields to change"
+// @Success      200 {object} ProxyHost
+// @Header       200 {string} ETag "Version of the proxy host"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /proxy-hosts/{id} [patch]
+func PatchProxyHost(c *fiber.Ctx) error {
+	return patchResource(c, store.proxyHosts, "Proxy host not found", proxyHostRequest, UpdateProxyHost)
+}
+
+// proxyHostRequest returns the request that would set up a proxy host as it is
+func proxyHostRequest(host ProxyHost) ProxyHostRequest {
+	return ProxyHostRequest{
+		DomainNames:  host.DomainNames,
+		ForwardHost:  host.ForwardHost,
+		ForwardPort:  host.ForwardPort,
+		SSLEnabled:   host.SSLEnabled,
+		SSLCertID:    host.SSLCertID,
+		BlueGreen:    host.BlueGreen,
+		Locations:    host.Locations,
+		AccessListID: host.AccessListID,
+		RateLimit:    host.RateLimit,
+		Cache:        host.Cache,
+		HeaderRules:  host.HeaderRules,
+		HSTS:         host.HSTS,
+		TLSProfileID: host.TLSProfileID,
+		ClientAuth:   host.ClientAuth,
+		Backend:      host.Backend,
+		ErrorPages:   host.ErrorPages,
+	}
+}
+
 // DeleteProxyHost godoc
 // @Summary      Delete a proxy host
 // @Description  Delete a proxy host configuration
 // @Tags         proxy-hosts
 // @Produce      json
 // @Param        id path int true "Proxy Host ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
 // @Success      200 {object} map[string]interface{}
 // @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
 // @Router       /proxy-hosts/{id} [delete]
 func DeleteProxyHost(c *fiber.Ctx) error {
-	id := c.Params("id")
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	host, ok := store.proxyHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+	if err := checkIfMatch(c, host.Version); err != nil {
+		return respondStale(c, host.Version, err)
+	}
+	if isDefaultProxyHost(id) {
+		return respondError(c, 409, "Conflict", "Proxy host is the default server")
+	}
+	store.proxyHosts.remove(id)
+	for _, record := range store.switches.all() {
+		if record.ProxyHostID == id {
+			store.switches.remove(record.ID)
+		}
+	}
+	publishEvent(topicProxyHosts, "proxy_host.deleted", host)
 
 	return c.JSON(fiber.Map{
 		"message": "Proxy host deleted successfully",
@@ -326,28 +730,31 @@ func DeleteProxyHost(c *fiber.Ctx) error {
 // @Description  Get a list of all SSL certificates
 // @Tags         certificates
 // @Produce      json
-// @Success      200 {array} Certificate
+// @Param        domain query string false "Domain name substring"
+// @Param        status query string false "Status filter, for example active or pending"
+// @Param        provider query string false "Provider filter, for example letsencrypt"
+// @Param        sort query string false "id, name, domain_name or expires_at, prefix with '-' for descending"
+// @Param        limit query int false "Page size (default 50, max 500)"
+// @Param        offset query int false "Rows to skip"
+// @Success      200 {object} Page[Certificate]
+// @Failure      400 {object} ErrorResponse
 // @Router       /certificates [get]
 func ListCertificates(c *fiber.Ctx) error {
-	certs := []Certificate{
-		{
-			ID:         1,
-			Name:       "example.com SSL",
-			Provider:   "letsencrypt",
-			DomainName: "example.com",
-			ExpiresAt:  "2025-12-31T23:59:59Z",
-			Status:     "active",
-		},
-		{
-			ID:         2,
-			Name:       "api.example.com SSL",
-			Provider:   "letsencrypt",
-			DomainName: "api.example.com",
-			ExpiresAt:  "2026-01-15T23:59:59Z",
-			Status:     "active",
-		},
-	}
-	return c.JSON(certs)
+	domain, status, provider := c.Query("domain"), c.Query("status"), c.Query("provider")
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	certs := filter(store.certificates.all(), func(cert Certificate) bool {
+		return (domain == "" || containsFold(cert.DomainName, domain)) &&
+			(status == "" || cert.Status == status) &&
+			(provider == "" || cert.Provider == provider)
+	})
+	page, err := paginate(c, certs, certificateSortKeys)
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	return c.JSON(page)
 }
 
 // CreateCertificate godoc
@@ -376,24 +783,28 @@ func CreateCertificate(c *fiber.Ctx) error {
 // @Description  Get a list of all configured upstream server groups
 // @Tags         upstreams
 // @Produce      json
-// @Success      200 {array} Upstream
+// @Param        name query string false "Name substring"
+// @Param        algorithm query string false "Algorithm filter"
+// @Param        sort query string false "id or name, prefix with '-' for descending"
+// @Param        limit query int false "Page size (default 50, max 500)"
+// @Param        offset query int false "Rows to skip"
+// @Success      200 {object} Page[Upstream]
+// @Failure      400 {object} ErrorResponse
 // @Router       /upstreams [get]
 func ListUpstreams(c *fiber.Ctx) error {
-	upstreams := []Upstream{
-		{
-			ID:          1,
-			Name:        "backend",
-			Algorithm:   "round_robin",
-			Description: "Backend application servers",
-		},
-		{
-			ID:          2,
-			Name:        "api_servers",
-			Algorithm:   "least_conn",
-			Description: "API server pool",
-		},
-	}
-	return c.JSON(upstreams)
+	name, algorithm := c.Query("name"), c.Query("algorithm")
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	upstreams := filter(store.upstreams.all(), func(upstream Upstream) bool {
+		return (name == "" || containsFold(upstream.Name, name)) && (algorithm == "" || upstream.Algorithm == algorithm)
+	})
+	page, err := paginate(c, upstreams, upstreamSortKeys)
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	return c.JSON(page)
 }
 
 // CreateUpstream godoc
@@ -402,15 +813,168 @@ func ListUpstreams(c *fiber.Ctx) error {
 // @Tags         upstreams
 // @Accept       json
 // @Produce      json
+// @Param        upstream body UpstreamRequest true "Upstream Group"
 // @Success      201 {object} Upstream
+// @Header       201 {string} ETag "Version of the upstream group"
+// @Failure      400 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
 // @Router       /upstreams [post]
 func CreateUpstream(c *fiber.Ctx) error {
-	return c.Status(201).JSON(Upstream{
-		ID:          3,
-		Name:        "new_upstream",
-		Algorithm:   "round_robin",
-		Description: "New upstream group",
-	})
+	var req UpstreamRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateUpstream(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, exists := store.upstreamByName(req.Name); exists {
+		return respondError(c, 409, "Conflict", fmt.Sprintf("Upstream %q already exists", req.Name))
+	}
+
+	upstream := Upstream{
+		ID:          store.upstreams.newID(),
+		Name:        req.Name,
+		Algorithm:   req.Algorithm,
+		Description: req.Description,
+		Affinity:    req.Affinity,
+	}
+	upstream = store.upstreams.put(upstream.ID, upstream)
+
+	setETag(c, upstream.Version)
+	return c.Status(201).JSON(upstream)
+}
+
+// UpdateUpstream godoc
+// @Summary      Update an upstream group
+// @Description  Update the name, algorithm or session affinity of an upstream group
+// @Tags         upstreams
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Upstream ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        upstream body UpstreamRequest true "Updated Upstream Group"
+// @Success      200 {object} Upstream
+// @Header       200 {string} ETag "Version of the upstream group"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /upstreams/{id} [put]
+func UpdateUpstream(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req UpstreamRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateUpstream(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	upstream, ok := store.upstreams.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Upstream not found")
+	}
+	if err := checkIfMatch(c, upstream.Version); err != nil {
+		return respondStale(c, upstream.Version, err)
+	}
+	if existing, exists := store.upstreamByName(req.Name); exists && existing.ID != id {
+		return respondError(c, 409, "Conflict", fmt.Sprintf("Upstream %q already exists", req.Name))
+	}
+
+	upstream.Name = req.Name
+	upstream.Algorithm = req.Algorithm
+	upstream.Description = req.Description
+	upstream.Affinity = req.Affinity
+
+	// Streams accept fewer affinity modes than locations
+	for _, stream := range store.streams.all() {
+		if stream.UpstreamID == nil || *stream.UpstreamID != id {
+			continue
+		}
+		if err := streamAffinityError(upstream); err != nil {
+			return respondError(c, 409, "Conflict", fmt.Sprintf("Stream %d: %s", stream.ID, err))
+		}
+	}
+
+	upstream = store.upstreams.put(upstream.ID, upstream)
+
+	setETag(c, upstream.Version)
+	return c.JSON(upstream)
+}
+
+// PatchUpstream godoc
+// @Summary      Partially update an upstream group
+// @Description  Change some fields of an upstream group with a JSON merge patch (RFC 7396)
+// @Tags         upstreams
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Upstream ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        upstream body UpstreamRequest true "Fields to change"
+// @Success      200 {object} Upstream
+// @Header       200 {string} ETag "Version of the upstream group"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /upstreams/{id} [patch]
+func PatchUpstream(c *fiber.Ctx) error {
+	return patchResource(c, store.upstreams, "Upstream not found", upstreamRequest, UpdateUpstream)
+}
+
+// upstreamRequest returns the request that would set up an upstream group as it is
+func upstreamRequest(upstream Upstream) UpstreamRequest {
+	return UpstreamRequest{
+		Name:        upstream.Name,
+		Algorithm:   upstream.Algorithm,
+		Description: upstream.Description,
+		Affinity:    upstream.Affinity,
+	}
+}
+
+// validateUpstream normalizes and checks an upstream request
+func validateUpstream(req *UpstreamRequest) error {
+	var errs fieldErrors
+	if !upstreamNamePattern.MatchString(req.Name) {
+		errs.add("name", "name may only contain letters, digits, '_' and '-'")
+	}
+	if req.Algorithm == "" {
+		req.Algorithm = "round_robin"
+	}
+	if _, ok := upstreamAlgorithms[req.Algorithm]; !ok {
+		errs.add("algorithm", "algorithm: unknown algorithm %q", req.Algorithm)
+	} else {
+		errs.nest("affinity", validateAffinity(req.Name, req.Algorithm, req.Affinity))
+	}
+	return errs.err()
+}
+
+// validateUpstreamServer normalizes and checks an upstream server request
+func validateUpstreamServer(req *UpstreamServerRequest) error {
+	var errs fieldErrors
+	if req.Weight == 0 {
+		req.Weight = 1
+	}
+	if req.Weight < 1 {
+		errs.add("weight", "weight must be at least 1")
+	}
+	if req.MaxFails < 0 {
+		errs.add("max_fails", "max_fails must not be negative")
+	}
+	return errs.err()
 }
 
 // ListUpstreamServers godoc
@@ -419,28 +983,37 @@ func CreateUpstream(c *fiber.Ctx) error {
 // @Tags         upstreams
 // @Produce      json
 // @Param        id path int true "Upstream ID"
-// @Success      200 {array} UpstreamServer
+// @Param        status query string false "Status filter (up, down)"
+// @Param        health query string false "Health filter (healthy, unhealthy)"
+// @Param        sort query string false "id, host or weight, prefix with '-' for descending"
+// @Param        limit query int false "Page size (default 50, max 500)"
+// @Param        offset query int false "Rows to skip"
+// @Success      200 {object} Page[UpstreamServer]
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
 // @Router       /upstreams/{id}/servers [get]
 func ListUpstreamServers(c *fiber.Ctx) error {
-	servers := []UpstreamServer{
-		{
-			ID:       1,
-			Host:     "192.168.1.100",
-			Port:     8080,
-			Weight:   1,
-			MaxFails: 3,
-			Status:   "up",
-		},
-		{
-			ID:       2,
-			Host:     "192.168.1.101",
-			Port:     8080,
-			Weight:   1,
-			MaxFails: 3,
-			Status:   "up",
-		},
-	}
-	return c.JSON(servers)
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if _, ok := store.upstreams.get(id); !ok {
+		return respondError(c, 404, "Not found", "Upstream not found")
+	}
+
+	status, health := c.Query("status"), c.Query("health")
+	servers := filter(store.serversOf(id), func(server UpstreamServer) bool {
+		return (status == "" || server.Status == status) && (health == "" || server.Health == health)
+	})
+	page, err := paginate(c, servers, upstreamServerSortKeys)
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	return c.JSON(page)
 }
 
 // AddUpstreamServer godoc
@@ -450,17 +1023,44 @@ func ListUpstreamServers(c *fiber.Ctx) error {
 // @Accept       json
 // @Produce      json
 // @Param        id path int true "Upstream ID"
+// @Param        server body UpstreamServerRequest true "Upstream Server"
 // @Success      201 {object} UpstreamServer
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
 // @Router       /upstreams/{id}/servers [post]
 func AddUpstreamServer(c *fiber.Ctx) error {
-	return c.Status(201).JSON(UpstreamServer{
-		ID:       3,
-		Host:     "192.168.1.102",
-		Port:     8080,
-		Weight:   1,
-		MaxFails: 3,
-		Status:   "up",
-	})
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req UpstreamServerRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+	if err := validateUpstreamServer(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, ok := store.upstreams.get(id); !ok {
+		return respondError(c, 404, "Not found", "Upstream not found")
+	}
+
+	server := UpstreamServer{
+		ID:         store.upstreamServers.newID(),
+		UpstreamID: id,
+		Host:       req.Host,
+		Port:       req.Port,
+		Weight:     req.Weight,
+		MaxFails:   req.MaxFails,
+		Status:     "up",
+	}
+	store.upstreamServers.put(server.ID, server)
+
+	return c.Status(201).JSON(server)
 }
 
 // ReloadNginx godoc
@@ -472,7 +1072,13 @@ func AddUpstreamServer(c *fiber.Ctx) error {
 // @Failure      500 {object} ErrorResponse
 // @Router       /nginx/reload [post]
 func ReloadNginx(c *fiber.Ctx) error {
-	// Execute: nginx -s reload
+	output, err := nginxController.Reload()
+	if err != nil {
+		publishEvent(topicNginx, "nginx.reload_failed", fiber.Map{"output": output, "error": err.Error()})
+		return respondError(c, 500, "Reload failed", fmt.Sprintf("%v: %s", err, output))
+	}
+	publishEvent(topicNginx, "nginx.reloaded", fiber.Map{"output": output})
+
 	return c.JSON(fiber.Map{
 		"message": "Nginx reloaded successfully",
 		"status":  "ok",
@@ -488,161 +1094,122 @@ func ReloadNginx(c *fiber.Ctx) error {
 // @Failure      400 {object} ErrorResponse
 // @Router       /nginx/test [post]
 func TestNginxConfig(c *fiber.Ctx) error {
-	// Execute: nginx -t
+	output, err := nginxController.Test()
+	if err != nil {
+		return respondError(c, 400, "Invalid configuration", output)
+	}
+
 	return c.JSON(fiber.Map{
 		"message": "Configuration is valid",
 		"status":  "ok",
-		"output":  "nginx: configuration file /etc/nginx/nginx.conf test is successful",
+		"output":  output,
 	})
 }
 
+// GetNginxConfig godoc
+// @Summary      Preview generated configuration
+// @Description  Render the Nginx configuration for the current state without applying it
+// @Tags         nginx
+// @Produce      plain
+// @Success      200 {string} string
+// @Router       /nginx/config [get]
+func GetNginxConfig(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var b strings.Builder
+	for _, file := range renderConfigFiles() {
+		if file.Remove {
+			continue
+		}
+		fmt.Fprintf(&b, "# %s\n%s\n", nginxController.Path(file.Path), file.Content)
+	}
+	return c.Type("txt").SendString(b.String())
+}
+
+// ApplyNginxConfig godoc
+// @Summary      Apply generated configuration
+// @Description  Write the generated configuration, test it and reload Nginx. The previous files are restored on failure.
+// @Tags         nginx
+// @Produce      json
+// @Success      200 {object} map[string]interface{}
+// @Failure      500 {object} ErrorResponse
+// @Router       /nginx/apply [post]
+func ApplyNginxConfig(c *fiber.Ctx) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	output, err := applyConfig()
+	if err != nil {
+		return respondError(c, 500, "Apply failed", fmt.Sprintf("%v: %s", err, output))
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Configuration applied successfully",
+		"status":  "ok",
+		"output":  output,
+	})
+}
+
+// NginxStatus holds the stub_status counters of the running nginx
+type NginxStatus struct {
+	ActiveConnections int `json:"active_connections" example:"42"`
+	Accepts           int `json:"accepts" example:"1234"`
+	Handled           int `json:"handled" example:"1234"`
+	Requests          int `json:"requests" example:"5678"`
+	Reading           int `json:"reading" example:"0"`
+	Writing           int `json:"writing" example:"1"`
+	Waiting           int `json:"waiting" example:"41"`
+}
+
 // GetNginxStatus godoc
 // @Summary      Get Nginx status
-// @Description  Get current Nginx status and metrics
+// @Description  Get the stub_status counters of the running Nginx, read from NGINX_STATUS_ADDR
 // @Tags         nginx
 // @Produce      json
-// @Success      200 {object} map[string]interface{}
+// @Success      200 {object} NginxStatus
+// @Failure      502 {object} ErrorResponse
 // @Router       /nginx/status [get]
 func GetNginxStatus(c *fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"active_connections": 42,
-		"accepts":            1234,
-		"handled":            1234,
-		"requests":           5678,
-		"reading":            0,
-		"writing":            1,
-		"waiting":            41,
-		"uptime":             "5 days, 3 hours",
-	})
+	status, err := nginxStatus()
+	if err != nil {
+		return respondError(c, 502, "Status unavailable", err.Error())
+	}
+	return c.JSON(status)
 }
 
-// getOpenAPISpec returns the OpenAPI specification
-func getOpenAPISpec() map[string]interface{} {
-	return map[string]interface{}{
-		"openapi": "3.0.0",
-		"info": map[string]interface{}{
-			"title":       "Balancer Studio API",
-			"description": "Professional Nginx management platform with beautiful UI and powerful API. Modern alternative to Nginx Plus.",
-			"version":     "1.0.0",
-			"contact": map[string]string{
-				"name":  "Balancer Studio Support",
-				"email": "support@balancer.studio",
-			},
-			"license": map[string]string{
-				"name": "MIT",
-				"url":  "https://opensource.org/licenses/MIT",
-			},
-		},
-		"servers": []map[string]string{
-			{"url": "http://localhost:3000/api/v1", "description": "Development server"},
-		},
-		"tags": []map[string]string{
-			{"name": "system", "description": "System operations"},
-			{"name": "proxy-hosts", "description": "Proxy host management"},
-			{"name": "certificates", "description": "SSL certificate management"},
-			{"name": "upstreams", "description": "Upstream server management"},
-			{"name": "nginx", "description": "Nginx control operations"},
-		},
-		"paths": map[string]interface{}{
-			"/health": map[string]interface{}{
-				"get": map[string]interface{}{
-					"summary":     "Health check",
-					"description": "Check if Balancer Studio API is running",
-					"tags":        []string{"system"},
-					"responses": map[string]interface{}{
-						"200": map[string]interface{}{
-							"description": "Service is healthy",
-							"content": map[string]interface{}{
-								"application/json": map[string]interface{}{
-									"schema": map[string]interface{}{
-										"type": "object",
-										"properties": map[string]interface{}{
-											"status":  map[string]string{"type": "string", "example": "ok"},
-											"service": map[string]string{"type": "string", "example": "balancer-studio"},
-											"version": map[string]string{"type": "string", "example": "1.0.0"},
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			"/proxy-hosts": map[string]interface{}{
-				"get": map[string]interface{}{
-					"summary":     "List all proxy hosts",
-					"description": "Get a list of all configured proxy hosts",
-					"tags":        []string{"proxy-hosts"},
-					"responses": map[string]interface{}{
-						"200": map[string]interface{}{
-							"description": "List of proxy hosts",
-							"content": map[string]interface{}{
-								"application/json": map[string]interface{}{
-									"schema": map[string]interface{}{
-										"type": "array",
-										"items": map[string]interface{}{
-											"$ref": "#/components/schemas/ProxyHost",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-				"post": map[string]interface{}{
-					"summary":     "Create a new proxy host",
-					"description": "Create a new proxy host configuration",
-					"tags":        []string{"proxy-hosts"},
-					"requestBody": map[string]interface{}{
-						"required": true,
-						"content": map[string]interface{}{
-							"application/json": map[string]interface{}{
-								"schema": map[string]interface{}{
-									"$ref": "#/components/schemas/ProxyHostRequest",
-								},
-							},
-						},
-					},
-					"responses": map[string]interface{}{
-						"201": map[string]interface{}{
-							"description": "Proxy host created",
-							"content": map[string]interface{}{
-								"application/json": map[string]interface{}{
-									"schema": map[string]interface{}{
-										"$ref": "#/components/schemas/ProxyHost",
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-		},
-		"components": map[string]interface{}{
-			"schemas": map[string]interface{}{
-				"ProxyHost": map[string]interface{}{
-					"type": "object",
-					"properties": map[string]interface{}{
-						"id":           map[string]interface{}{"type": "integer", "example": 1},
-						"domain_names": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "example": []string{"example.com"}},
-						"forward_host": map[string]interface{}{"type": "string", "example": "192.168.1.100"},
-						"forward_port": map[string]interface{}{"type": "integer", "example": 8080},
-						"ssl_enabled":  map[string]interface{}{"type": "boolean", "example": true},
-						"enabled":      map[string]interface{}{"type": "boolean", "example": true},
-						"created_at":   map[string]interface{}{"type": "string", "example": "2025-12-08T10:00:00Z"},
-					},
-				},
-				"ProxyHostRequest": map[string]interface{}{
-					"type":     "object",
-					"required": []string{"domain_names", "forward_host", "forward_port"},
-					"properties": map[string]interface{}{
-						"domain_names": map[string]interface{}{"type": "array", "items": map[string]string{"type": "string"}, "example": []string{"example.com"}},
-						"forward_host": map[string]interface{}{"type": "string", "example": "192.168.1.100"},
-						"forward_port": map[string]interface{}{"type": "integer", "example": 8080},
-						"ssl_enabled":  map[string]interface{}{"type": "boolean", "example": false},
-					},
-				},
-			},
-		},
+// nginxStatus reads the stub_status counters served by renderStatusServer
+func nginxStatus() (NginxStatus, error) {
+	text, err := nginxController.StubStatus()
+	if err != nil {
+		return NginxStatus{}, err
+	}
+	return parseStubStatus(text)
+}
+
+// parseStubStatus parses the plain text output of stub_status:
+//
+//	Active connections: 291
+//	server accepts handled requests
+//	 16630948 16630948 31070465
+//	Reading: 6 Writing: 179 Waiting: 106
+func parseStubStatus(text string) (NginxStatus, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 16 || fields[0] != "Active" || fields[10] != "Reading:" || fields[12] != "Writing:" || fields[14] != "Waiting:" {
+		return NginxStatus{}, fmt.Errorf("unexpected stub_status output %q", text)
+	}
+
+	var status NginxStatus
+	counters := map[int]*int{
+		2: &status.ActiveConnections, 7: &status.Accepts, 8: &status.Handled, 9: &status.Requests,
+		11: &status.Reading, 13: &status.Writing, 15: &status.Waiting,
+	}
+	for i, counter := range counters {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return NginxStatus{}, fmt.Errorf("unexpected stub_status output %q", text)
+		}
+		*counter = n
 	}
+	return status, nil
 }
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestParseStubStatus(t *testing.T) {
+	text := "Active connections: 291 \nserver accepts handled requests\n 16630948 16630948 31070465 \nReading: 6 Writing: 179 Waiting: 106 \n"
+	got, err := parseStubStatus(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := NginxStatus{ActiveConnections: 291, Accepts: 16630948, Handled: 16630948, Requests: 31070465, Reading: 6, Writing: 179, Waiting: 106}
+	if got != want {
+		t.Errorf("parseStubStatus = %+v, want %+v", got, want)
+	}
+
+	for _, text := range []string{"", "<html>404 Not Found</html>", "Active connections: x \nserver accepts handled requests\n 1 1 1 \nReading: 0 Writing: 1 Waiting: 0 \n"} {
+		if _, err := parseStubStatus(text); err == nil {
+			t.Errorf("parseStubStatus(%q) did not fail", text)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultMaintenanceRetryAfter is sent when neither retry_after nor ends_at is set
+const defaultMaintenanceRetryAfter = 300
+
+// maintenancePrefix is the internal URI prefix the maintenance page is served from
+const maintenancePrefix = "/__bs_maintenance/"
+
+// defaultMaintenanceTemplate is used when maintenance is enabled without a template
+const defaultMaintenanceTemplate = `<!DOCTYPE html>
+<html>
+<head><title>Down for maintenance</title></head>
+<body>
+<h1>Down for maintenance</h1>
+<p>{{.Host}} is undergoing scheduled maintenance and will be back shortly.</p>
+</body>
+</html>
+`
+
+// Maintenance answers a proxy host with a 503 page while allowlisted
+// addresses still reach the backend
+type Maintenance struct {
+	RetryAfter int      `json:"retry_after,omitempty" example:"600"`
+	Template   string   `json:"template,omitempty"`
+	AllowIPs   []string `json:"allow_ips,omitempty" example:"203.0.113.0/24"`
+	StartsAt   string   `json:"starts_at,omitempty" example:"2025-12-10T03:00:00Z"`
+	EndsAt     string   `json:"ends_at,omitempty" example:"2025-12-10T04:00:00Z"`
+	// Active reports whether the applied configuration serves the maintenance page
+	Active    bool   `json:"active" example:"true"`
+	UpdatedAt string `json:"updated_at" example:"2025-12-08T10:00:00Z"`
+}
+
+// MaintenanceRequest represents the request body for enabling maintenance mode
+type MaintenanceRequest struct {
+	RetryAfter int      `json:"retry_after,omitempty" example:"600"`
+	Template   string   `json:"template,omitempty" example:"<h1>Back at 04:00 UTC</h1>"`
+	AllowIPs   []string `json:"allow_ips,omitempty" example:"203.0.113.0/24"`
+	StartsAt   string   `json:"starts_at,omitempty" example:"2025-12-10T03:00:00Z"`
+	EndsAt     string   `json:"ends_at,omitempty" example:"2025-12-10T04:00:00Z"`
+}
+
+// validateMaintenance normalizes and checks a maintenance request
+func validateMaintenance(req *MaintenanceRequest, current time.Time) error {
+	var errs fieldErrors
+	if req.RetryAfter < 0 {
+		errs.add("retry_after", "retry_after must not be negative")
+	}
+	if req.RetryAfter == 0 && req.EndsAt == "" {
+		req.RetryAfter = defaultMaintenanceRetryAfter
+	}
+	if req.Template == "" {
+		req.Template = defaultMaintenanceTemplate
+	}
+	if _, err := renderErrorPage(req.Template, 503, ""); err != nil {
+		errs.check("template", err)
+	}
+
+	for i, address := range req.AllowIPs {
+		if net.ParseIP(address) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(address); err != nil {
+			errs.add(fmt.Sprintf("allow_ips[%d]", i), "allow_ips[%d] must be an IP or a CIDR range", i)
+		}
+	}
+
+	var start, end time.Time
+	var err error
+	if req.StartsAt != "" {
+		if start, err = time.Parse(time.RFC3339, req.StartsAt); err != nil {
+			errs.add("starts_at", "starts_at must be an RFC 3339 time")
+		}
+	}
+	if req.EndsAt != "" {
+		if end, err = time.Parse(time.RFC3339, req.EndsAt); err != nil {
+			errs.add("ends_at", "ends_at must be an RFC 3339 time")
+		} else if !end.After(current) {
+			errs.add("ends_at", "ends_at must be in the future")
+		} else if !start.IsZero() && !end.After(start) {
+			errs.add("ends_at", "ends_at must be after starts_at")
+		}
+	}
+	return errs.err()
+}
+
+// maintenanceStarted reports whether a maintenance window has begun
+func maintenanceStarted(m *Maintenance, current time.Time) bool {
+	if m.StartsAt == "" {
+		return true
+	}
+	start, err := time.Parse(time.RFC3339, m.StartsAt)
+	return err == nil && !current.Before(start)
+}
+
+// maintenanceEnded reports whether a maintenance window is over
+func maintenanceEnded(m *Maintenance, current time.Time) bool {
+	if m.EndsAt == "" {
+		return false
+	}
+	end, err := time.Parse(time.RFC3339, m.EndsAt)
+	return err == nil && !current.Before(end)
+}
+
+// inMaintenance reports whether the maintenance page is rendered for a host
+func inMaintenance(host ProxyHost) bool {
+	return host.Maintenance != nil && host.Maintenance.Active
+}
+
+// maintenanceVariable names the geo variable that is 1 for blocked clients
+func maintenanceVariable(host ProxyHost) string {
+	return "$bs_maintenance_" + hostZone(host)
+}
+
+// maintenanceDir returns the directory that holds the maintenance page of a host
+func maintenanceDir(host ProxyHost) string {
+	return filepath.Join(nginxController.Config().DataPath, "maintenance", hostZone(host))
+}
+
+// retryAfter returns the Retry-After value, as seconds or as the HTTP date
+// the window ends
+func retryAfter(m *Maintenance) string {
+	if m.RetryAfter > 0 {
+		return strconv.Itoa(m.RetryAfter)
+	}
+	end, _ := time.Parse(time.RFC3339, m.EndsAt)
+	return end.UTC().Format(http.TimeFormat)
+}
+
+// renderMaintenanceFiles renders the maintenance pages of hosts in
+// maintenance and removes the pages of hosts that left it
+func renderMaintenanceFiles() []nginx.File {
+	var files []nginx.File
+	for _, host := range store.proxyHosts.all() {
+		if !inMaintenance(host) {
+			continue
+		}
+		content, _ := renderErrorPage(host.Maintenance.Template, 503, strings.Join(host.DomainNames, ", "))
+		files = append(files, nginx.File{Path: filepath.Join(maintenanceDir(host), "index.html"), Content: content, Mode: 0o644})
+	}
+	return removeStale(files, filepath.Join(filepath.Dir(maintenanceDir(ProxyHost{})), "*", "index.html"))
+}
+
+// renderMaintenanceGeo renders the http-context geo block of allowlisted addresses
+func renderMaintenanceGeo(host ProxyHost) []nginx.Directive {
+	if !inMaintenance(host) {
+		return nil
+	}
+
+	body := []nginx.Directive{nginx.Simple("default", "1")}
+	for _, address := range host.Maintenance.AllowIPs {
+		body = append(body, nginx.Simple(address, "0"))
+	}
+	return []nginx.Directive{nginx.NewBlock("geo", []string{maintenanceVariable(host)}, body...)}
+}
+
+// renderMaintenanceCheck renders the location directives that answer
+// blocked clients with the maintenance page. They live in every location
+// rather than the server block so the internal page location is not
+// blocked as well.
+func renderMaintenanceCheck(host ProxyHost) []nginx.Directive {
+	if !inMaintenance(host) {
+		return nil
+	}
+	return []nginx.Directive{
+		nginx.NewBlock("if", []string{"(" + maintenanceVariable(host) + ")"},
+			nginx.Simple("error_page", "503", maintenancePrefix+"index.html"),
+			nginx.Simple("return", "503"),
+		),
+	}
+}
+
+// renderMaintenancePage renders the internal location serving the page
+func renderMaintenancePage(host ProxyHost) []nginx.Directive {
+	if !inMaintenance(host) {
+		return nil
+	}
+	return []nginx.Directive{
+		nginx.NewBlock("location", []string{"^~", maintenancePrefix},
+			nginx.Simple("internal"),
+			nginx.Simple("alias", maintenanceDir(host)+"/"),
+			nginx.Simple("add_header", "Retry-After", retryAfter(host.Maintenance), "always"),
+			nginx.Simple("add_header", "Cache-Control", "no-store", "always"),
+		),
+	}
+}
+
+// maintenanceTick applies maintenance windows that started or ended. When
+// the apply fails the hosts are left unchanged so the next tick retries.
+func maintenanceTick(current time.Time) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var previous []ProxyHost
+	for _, host := range store.proxyHosts.all() {
+		m := host.Maintenance
+		if m == nil {
+			continue
+		}
+
+		switch {
+		case maintenanceEnded(m, current):
+			previous = append(previous, host)
+			host.Maintenance = nil
+		case !m.Active && maintenanceStarted(m, current):
+			previous = append(previous, host)
+			started := *m
+			started.Active = true
+			host.Maintenance = &started
+		default:
+			continue
+		}
+		store.proxyHosts.put(host.ID, host)
+	}
+	if len(previous) == 0 {
+		return
+	}
+
+	output, err := applyConfig()
+	if err != nil {
+		for _, host := range previous {
+			store.proxyHosts.restore(host.ID, host)
+		}
+		log.Printf("maintenance: apply failed, retrying in %s: %v: %s", schedulerInterval, err, output)
+		return
+	}
+	for _, host := range previous {
+		if current, _ := store.proxyHosts.get(host.ID); current.Maintenance != nil && current.Maintenance.Active {
+			log.Printf("maintenance: proxy host %d entered maintenance mode", host.ID)
+		} else {
+			log.Printf("maintenance: proxy host %d left maintenance mode", host.ID)
+		}
+	}
+}
+
+// EnableMaintenance godoc
+// @Summary      Enable maintenance mode
+// @Description  Serve a 503 page with Retry-After to everyone except allowlisted addresses. Without starts_at the page is applied immediately; with ends_at maintenance is lifted automatically.
+// @Tags         proxy-hosts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Proxy Host ID"
+// @Param        If-Match header string false "ETag of the proxy host version being updated"
+// @Param        maintenance body MaintenanceRequest true "Maintenance"
+// @Success      200 {object} Maintenance
+// @Header       200 {string} ETag "Version of the proxy host"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Router       /proxy-hosts/{id}/maintenance [put]
+func EnableMaintenance(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req MaintenanceRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	current := time.Now().UTC()
+	if err := validateMaintenance(&req, current); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	host, ok := store.proxyHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+	if err := checkIfMatch(c, host.Version); err != nil {
+		return respondStale(c, host.Version, err)
+	}
+
+	m := &Maintenance{
+		RetryAfter: req.RetryAfter,
+		Template:   req.Template,
+		AllowIPs:   req.AllowIPs,
+		StartsAt:   req.StartsAt,
+		EndsAt:     req.EndsAt,
+		UpdatedAt:  now(),
+	}
+	m.Active = maintenanceStarted(m, current)
+
+	return setMaintenance(c, host, m)
+}
+
+// DisableMaintenance godoc
+// @Summary      Disable maintenance mode
+// @Description  Send traffic to the backend again and cancel any scheduled maintenance window
+// @Tags         proxy-hosts
+// @Produce      json
+// @Param        id path int true "Proxy Host ID"
+// @Param        If-Match header string false "ETag of the proxy host version being updated"
+// @Success      200 {object} map[string]interface{}
+// @Header       200 {string} ETag "Version of the proxy host"
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Failure      500 {object} ErrorResponse
+// @Router       /proxy-hosts/{id}/maintenance [delete]
+func DisableMaintenance(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	host, ok := store.proxyHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Proxy host not found")
+	}
+	if err := checkIfMatch(c, host.Version); err != nil {
+		return respondStale(c, host.Version, err)
+	}
+	if host.Maintenance == nil {
+		return respondError(c, 409, "Conflict", "Proxy host is not in maintenance mode")
+	}
+
+	return setMaintenance(c, host, nil)
+}
+
+// setMaintenance stores the maintenance state of a host and applies it when
+// the rendered configuration changes. Callers must hold store.mu.
+func setMaintenance(c *fiber.Ctx, host ProxyHost, m *Maintenance) error {
+	wasActive := inMaintenance(host)
+	previous := host
+	host.Maintenance = m
+	host = store.proxyHosts.put(host.ID, host)
+
+	output := ""
+	if wasActive || inMaintenance(host) {
+		var err error
+		if output, err = applyConfig(); err != nil {
+			store.proxyHosts.restore(previous.ID, previous)
+			return respondError(c, 500, "Apply failed", fmt.Sprintf("%v: %s", err, output))
+		}
+	}
+
+	setETag(c, host.Version)
+	if m == nil {
+		return c.JSON(fiber.Map{
+			"message": "Maintenance mode disabled",
+			"id":      host.ID,
+			"output":  output,
+		})
+	}
+	return c.JSON(m)
+}
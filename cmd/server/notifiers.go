@@ -0,0 +1,485 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Notifier types
+const (
+	notifierSlack   = "slack"
+	notifierSMTP    = "smtp"
+	notifierWebhook = "webhook"
+)
+
+// smtpTimeout bounds a whole SMTP conversation, from dialing to QUIT
+const smtpTimeout = webhookTimeout
+
+// Notifier is a channel alert notifications are sent through
+type Notifier struct {
+	ID int `json:"id" example:"1"`
+	Revision
+	Name string `json:"name" example:"ops-slack"`
+	Type string `json:"type" example:"slack"`
+	// URL is the Slack incoming webhook or the generic webhook endpoint
+	URL       string        `json:"url,omitempty" example:"https://hooks.slack.com/services/T000/B000/XXXX"`
+	SMTP      *SMTPSettings `json:"smtp,omitempty"`
+	CreatedAt string        `json:"created_at" example:"2025-12-08T10:00:00Z"`
+}
+
+// NotifierRequest creates or updates a notifier. An empty SMTP password
+// keeps the current one on update.
+type NotifierRequest struct {
+	Name string        `json:"name" binding:"required" example:"ops-slack"`
+	Type string        `json:"type" example:"slack"`
+	URL  string        `json:"url,omitempty" example:"https://hooks.slack.com/services/T000/B000/XXXX"`
+	SMTP *SMTPSettings `json:"smtp,omitempty"`
+}
+
+// SMTPSettings sends notifications as email. Password is never returned.
+type SMTPSettings struct {
+	Host     string   `json:"host" binding:"host" example:"smtp.example.com"`
+	Port     int      `json:"port" binding:"port" example:"587"`
+	Username string   `json:"username,omitempty" example:"alerts@example.com"`
+	Password string   `json:"password,omitempty" example:"s3cret"`
+	From     string   `json:"from" example:"alerts@example.com"`
+	To       []string `json:"to" example:"ops@example.com"`
+}
+
+// Notification is what notifiers send when an alert fires or resolves
+type Notification struct {
+	Status     string  `json:"status" example:"firing"`
+	AlertID    int     `json:"alert_id" example:"1"`
+	Rule       string  `json:"rule" example:"backend capacity"`
+	Subject    string  `json:"subject" example:"upstream backend"`
+	Message    string  `json:"message" example:"1 of 3 servers healthy, expected at least 2"`
+	Value      float64 `json:"value" example:"1"`
+	StartedAt  string  `json:"started_at" example:"2025-12-08T10:00:00Z"`
+	ResolvedAt string  `json:"resolved_at,omitempty" example:"2025-12-08T10:05:00Z"`
+}
+
+// title is the one-line summary of a notification
+func (n Notification) title() string {
+	return fmt.Sprintf("[%s] %s: %s", strings.ToUpper(n.Status), n.Rule, n.Subject)
+}
+
+// notifier sends notifications through one channel
+type notifier interface {
+	notify(n Notification) error
+}
+
+// newNotifier returns the implementation of a notifier's type
+func newNotifier(config Notifier) notifier {
+	switch config.Type {
+	case notifierSlack:
+		return slackNotifier{url: config.URL}
+	case notifierSMTP:
+		return smtpNotifier{settings: *config.SMTP}
+	default:
+		return webhookNotifier{url: config.URL}
+	}
+}
+
+// postJSON sends a JSON body and fails on non-2xx responses
+func postJSON(target string, body any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(target, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		response, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+		return fmt.Errorf("%s answered %d: %s", target, resp.StatusCode, response)
+	}
+	return nil
+}
+
+// slackNotifier posts to a Slack incoming webhook
+type slackNotifier struct {
+	url string
+}
+
+func (s slackNotifier) notify(n Notification) error {
+	return postJSON(s.url, map[string]string{"text": "*" + n.title() + "*\n" + n.Message})
+}
+
+// webhookNotifier posts the notification as JSON to any endpoint
+type webhookNotifier struct {
+	url string
+}
+
+func (w webhookNotifier) notify(n Notification) error {
+	return postJSON(w.url, n)
+}
+
+// smtpNotifier sends an email, with STARTTLS when the server offers it
+type smtpNotifier struct {
+	settings SMTPSettings
+}
+
+func (s smtpNotifier) notify(n Notification) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", s.settings.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(s.settings.To, ", "))
+	// Rule names and subjects are user input, encoding keeps line breaks
+	// out of the header
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.title()))
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&body, "%s\r\n\r\nStarted: %s\r\n", n.Message, n.StartedAt)
+	if n.ResolvedAt != "" {
+		fmt.Fprintf(&body, "Resolved: %s\r\n", n.ResolvedAt)
+	}
+
+	// Addresses were validated on save, the envelope takes them without names
+	from, _ := mail.ParseAddress(s.settings.From)
+	var to []string
+	for _, recipient := range s.settings.To {
+		address, _ := mail.ParseAddress(recipient)
+		to = append(to, address.Address)
+	}
+
+	return s.send(from.Address, to, []byte(body.String()))
+}
+
+// send delivers a message like smtp.SendMail, but gives up after smtpTimeout
+// instead of waiting forever on an unresponsive server
+func (s smtpNotifier) send(from string, to []string, message []byte) error {
+	server := net.JoinHostPort(s.settings.Host, strconv.Itoa(s.settings.Port))
+	conn, err := net.DialTimeout("tcp", server, smtpTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, s.settings.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.settings.Host}); err != nil {
+			return err
+		}
+	}
+	if s.settings.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.settings.Username, s.settings.Password, s.settings.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+	for _, recipient := range to {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// validateNotifier checks a notifier request
+func validateNotifier(req *NotifierRequest) error {
+	var errs fieldErrors
+	switch req.Type {
+	case notifierSlack, notifierWebhook:
+		target, err := url.Parse(req.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			errs.add("url", "url must be an absolute http or https URL")
+		}
+		if req.SMTP != nil {
+			errs.add("smtp", "smtp is only used by the %q type", notifierSMTP)
+		}
+	case notifierSMTP:
+		if req.URL != "" {
+			errs.add("url", "url is not used by the %q type", notifierSMTP)
+		}
+		settings := req.SMTP
+		if settings == nil {
+			errs.add("smtp", "smtp is required for the %q type", notifierSMTP)
+			break
+		}
+		if settings.Host == "" {
+			errs.add("smtp.host", "smtp.host is required")
+		}
+		if settings.Port == 0 {
+			settings.Port = 587
+		}
+		if settings.Port < 1 || settings.Port > 65535 {
+			errs.add("smtp.port", "smtp.port must be between 1 and 65535")
+		}
+		if _, err := mail.ParseAddress(settings.From); err != nil {
+			errs.add("smtp.from", "smtp.from must be an email address")
+		}
+		if len(settings.To) == 0 {
+			errs.add("smtp.to", "smtp.to is required")
+		}
+		for i, to := range settings.To {
+			if _, err := mail.ParseAddress(to); err != nil {
+				errs.add(fmt.Sprintf("smtp.to[%d]", i), "smtp.to[%d] must be an email address", i)
+			}
+		}
+	default:
+		errs.add("type", "type must be one of %q, %q or %q", notifierSlack, notifierSMTP, notifierWebhook)
+	}
+	return errs.err()
+}
+
+// redacted returns the notifier without its SMTP password
+func (n Notifier) redacted() Notifier {
+	if n.SMTP != nil {
+		settings := *n.SMTP
+		settings.Password = ""
+		n.SMTP = &settings
+	}
+	return n
+}
+
+// ListNotifiers godoc
+// @Summary      List notifiers
+// @Description  Get all notification channels. SMTP passwords are not returned.
+// @Tags         alerts
+// @Produce      json
+// @Success      200 {array} Notifier
+// @Router       /notifiers [get]
+func ListNotifiers(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	notifiers := []Notifier{}
+	for _, n := range store.notifiers.all() {
+		notifiers = append(notifiers, n.redacted())
+	}
+	return c.JSON(notifiers)
+}
+
+// CreateNotifier godoc
+// @Summary      Create a notifier
+// @Description  Add a Slack incoming webhook, SMTP or generic webhook channel for alert notifications
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Param        notifier body NotifierRequest true "Notifier"
+// @Success      201 {object} Notifier
+// @Header       201 {string} ETag "Version of the notifier"
+// @Failure      400 {object} ErrorResponse
+// @Router       /notifiers [post]
+func CreateNotifier(c *fiber.Ctx) error {
+	var req NotifierRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateNotifier(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	n := Notifier{
+		ID:        store.notifiers.newID(),
+		Name:      req.Name,
+		Type:      req.Type,
+		URL:       req.URL,
+		SMTP:      req.SMTP,
+		CreatedAt: now(),
+	}
+	n = store.notifiers.put(n.ID, n)
+
+	setETag(c, n.Version)
+	return c.Status(201).JSON(n.redacted())
+}
+
+// UpdateNotifier godoc
+// @Summary      Update a notifier
+// @Description  Update a notification channel. An empty SMTP password keeps the current one.
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Notifier ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        notifier body NotifierRequest true "Updated Notifier"
+// @Success      200 {object} Notifier
+// @Header       200 {string} ETag "Version of the notifier"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /notifiers/{id} [put]
+func UpdateNotifier(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req NotifierRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateNotifier(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	n, ok := store.notifiers.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Notifier not found")
+	}
+	if err := checkIfMatch(c, n.Version); err != nil {
+		return respondStale(c, n.Version, err)
+	}
+	if req.SMTP != nil && req.SMTP.Password == "" && n.SMTP != nil {
+		req.SMTP.Password = n.SMTP.Password
+	}
+
+	n.Name = req.Name
+	n.Type = req.Type
+	n.URL = req.URL
+	n.SMTP = req.SMTP
+	n = store.notifiers.put(n.ID, n)
+
+	setETag(c, n.Version)
+	return c.JSON(n.redacted())
+}
+
+// PatchNotifier godoc
+// @Summary      Partially update a notifier
+// @Description  Change some fields of a notification channel with a JSON merge patch (RFC 7396). The SMTP password is kept unless one is sent.
+// @Tags         alerts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Notifier ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        notifier body NotifierRequest true "Fields to change"
+// @Success      200 {object} Notifier
+// @Header       200 {string} ETag "Version of the notifier"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /notifiers/{id} [patch]
+func PatchNotifier(c *fiber.Ctx) error {
+	return patchResource(c, store.notifiers, "Notifier not found", notifierRequest, UpdateNotifier)
+}
+
+// notifierRequest returns the request that would set up a notifier as it is
+func notifierRequest(n Notifier) NotifierRequest {
+	return NotifierRequest{Name: n.Name, Type: n.Type, URL: n.URL, SMTP: n.SMTP}
+}
+
+// DeleteNotifier godoc
+// @Summary      Delete a notifier
+// @Description  Delete a notification channel that no alert rule uses
+// @Tags         alerts
+// @Produce      json
+// @Param        id path int true "Notifier ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /notifiers/{id} [delete]
+func DeleteNotifier(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	n, ok := store.notifiers.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Notifier not found")
+	}
+	if err := checkIfMatch(c, n.Version); err != nil {
+		return respondStale(c, n.Version, err)
+	}
+	for _, rule := range store.alertRules.all() {
+		if slices.Contains(rule.NotifierIDs, id) {
+			return respondError(c, 409, "Conflict", fmt.Sprintf("Notifier is used by alert rule %d", rule.ID))
+		}
+	}
+	store.notifiers.remove(id)
+
+	return c.JSON(fiber.Map{
+		"message": "Notifier deleted successfully",
+		"id":      id,
+	})
+}
+
+// TestNotifier godoc
+// @Summary      Test a notifier
+// @Description  Send a test notification and report whether it was accepted
+// @Tags         alerts
+// @Produce      json
+// @Param        id path int true "Notifier ID"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      502 {object} ErrorResponse
+// @Router       /notifiers/{id}/test [post]
+func TestNotifier(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	n, ok := store.notifiers.get(id)
+	store.mu.RUnlock()
+	if !ok {
+		return respondError(c, 404, "Not found", "Notifier not found")
+	}
+
+	// Sent without holding store.mu, SMTP servers can be slow
+	err = newNotifier(n).notify(Notification{
+		Status:    "test",
+		Rule:      "Test notification",
+		Subject:   n.Name,
+		Message:   "Balancer Studio can reach this notifier.",
+		StartedAt: now(),
+	})
+	if err != nil {
+		return respondError(c, 502, "Notification failed", err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Test notification sent",
+		"id":      id,
+	})
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	_ "embed"
+)
+
+//go:generate go run ../openapi -out openapi.json
+
+// openAPISpec is generated from the swag comments on the handlers and the
+// types they reference. Run go generate after changing either.
+//
+//go:embed openapi.json
+var openAPISpec []byte
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/openapi"
+)
+
+const basePath = "/api/v1"
+
+// routeParam matches fiber path parameters such as :id
+var routeParam = regexp.MustCompile(`:(\w+)`)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	doc, err := openapi.Generate(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registered := map[string]bool{}
+	for _, route := range newApp().GetRoutes(true) {
+		if route.Method == "HEAD" || !strings.HasPrefix(route.Path, basePath+"/") {
+			continue
+		}
+		// Group roots keep their trailing slash, routing ignores it
+		path := strings.TrimSuffix(strings.TrimPrefix(route.Path, basePath), "/")
+		path = routeParam.ReplaceAllString(path, "{$1}")
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+		if _, ok := doc.Paths[path][method]; !ok {
+			t.Errorf("%s %s has no handler with @Router %s [%s]", route.Method, route.Path, path, method)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			if !registered[method+" "+path] {
+				t.Errorf("%s documents %s %s, which is not registered", op.OperationID, strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISpecIsCurrent(t *testing.T) {
+	doc, err := openapi.Generate(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := openapi.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, openAPISpec) {
+		t.Error("openapi.json is stale, run go generate ./cmd/server")
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// Page is one page of a list endpoint. Total counts every row that matched
+// the filters, not only the rows on this page.
+type Page[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total" example:"120"`
+	Limit  int `json:"limit" example:"50"`
+	Offset int `json:"offset" example:"0"`
+}
+
+// sortKeys maps the sort parameter of a list endpoint to comparisons. Rows
+// come ordered by ID and are sorted stably, so ties stay ordered by ID.
+type sortKeys[T any] map[string]func(a, b T) int
+
+// paginate sorts rows by the sort parameter and cuts out the page selected
+// by limit and offset
+func paginate[T any](c *fiber.Ctx, rows []T, keys sortKeys[T]) (Page[T], error) {
+	limit, err := queryInt(c, "limit", defaultPageLimit)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return Page[T]{}, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+	offset, err := queryInt(c, "offset", 0)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	if offset < 0 {
+		return Page[T]{}, fmt.Errorf("offset must not be negative")
+	}
+
+	if sort := c.Query("sort"); sort != "" {
+		name, descending := strings.CutPrefix(sort, "-")
+		compare, ok := keys[name]
+		if !ok {
+			return Page[T]{}, fmt.Errorf("sort must be one of %s, prefixed with '-' for descending order", strings.Join(slices.Sorted(maps.Keys(keys)), ", "))
+		}
+		slices.SortStableFunc(rows, func(a, b T) int {
+			if descending {
+				return compare(b, a)
+			}
+			return compare(a, b)
+		})
+	}
+
+	page := Page[T]{Items: []T{}, Total: len(rows), Limit: limit, Offset: offset}
+	if offset < len(rows) {
+		page.Items = rows[offset:min(offset+limit, len(rows))]
+	}
+	return page, nil
+}
+
+// queryBool reads an optional boolean filter
+func queryBool(c *fiber.Ctx, name string) (*bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &parsed, nil
+}
+
+// queryInt reads an optional integer parameter
+func queryInt(c *fiber.Ctx, name string, fallback int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", name)
+	}
+	return parsed, nil
+}
+
+// containsFold reports whether substr is within s, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// filter returns the rows keep accepts
+func filter[T any](rows []T, keep func(T) bool) []T {
+	kept := []T{}
+	for _, row := range rows {
+		if keep(row) {
+			kept = append(kept, row)
+		}
+	}
+	return kept
+}
+
+// firstDomain sorts proxy hosts by their first domain name
+func firstDomain(host ProxyHost) string {
+	if len(host.DomainNames) == 0 {
+		return ""
+	}
+	return host.DomainNames[0]
+}
+
+var proxyHostSortKeys = sortKeys[ProxyHost]{
+	"id":           func(a, b ProxyHost) int { return cmp.Compare(a.ID, b.ID) },
+	"domain":       func(a, b ProxyHost) int { return cmp.Compare(firstDomain(a), firstDomain(b)) },
+	"forward_host": func(a, b ProxyHost) int { return cmp.Compare(a.ForwardHost, b.ForwardHost) },
+	"created_at":   func(a, b ProxyHost) int { return cmp.Compare(a.CreatedAt, b.CreatedAt) },
+}
+
+var certificateSortKeys = sortKeys[Certificate]{
+	"id":          func(a, b Certificate) int { return cmp.Compare(a.ID, b.ID) },
+	"name":        func(a, b Certificate) int { return cmp.Compare(a.Name, b.Name) },
+	"domain_name": func(a, b Certificate) int { return cmp.Compare(a.DomainName, b.DomainName) },
+	"expires_at":  func(a, b Certificate) int { return cmp.Compare(a.ExpiresAt, b.ExpiresAt) },
+}
+
+var upstreamSortKeys = sortKeys[Upstream]{
+	"id":   func(a, b Upstream) int { return cmp.Compare(a.ID, b.ID) },
+	"name": func(a, b Upstream) int { return cmp.Compare(a.Name, b.Name) },
+}
+
+var upstreamServerSortKeys = sortKeys[UpstreamServer]{
+	"id":     func(a, b UpstreamServer) int { return cmp.Compare(a.ID, b.ID) },
+	"host":   func(a, b UpstreamServer) int { return cmp.Compare(a.Host, b.Host) },
+	"weight": func(a, b UpstreamServer) int { return cmp.Compare(a.Weight, b.Weight) },
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPaginateQuery(t *testing.T) {
+	app := newApp()
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 200},
+		{"?limit=2&offset=1", 200},
+		{"?limit=abc", 400},
+		{"?limit=0", 400},
+		{"?limit=10x", 400},
+		{"?offset=-1", 400},
+		{"?offset=one", 400},
+	}
+	for _, tt := range tests {
+		resp, err := app.Test(httptest.NewRequest("GET", basePath+"/proxy-hosts"+tt.query, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET /proxy-hosts%s = %d, want %d", tt.query, resp.StatusCode, tt.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+// Rate limit keys
+const (
+	limitKeyClientIP = "client_ip"
+	limitKeyHeader   = "header"
+	limitKeyAPIKey   = "api_key"
+)
+
+// defaultAPIKeyHeader is used by the api_key limit key when no header is set
+const defaultAPIKeyHeader = "X-API-Key"
+
+// RateLimit limits request rate and concurrent connections of a proxy host or location
+type RateLimit struct {
+	Rate            int    `json:"rate,omitempty" example:"10"`
+	Per             string `json:"per,omitempty" example:"second"`
+	Burst           int    `json:"burst,omitempty" example:"20"`
+	NoDelay         bool   `json:"nodelay,omitempty" example:"true"`
+	Key             string `json:"key,omitempty" example:"client_ip"`
+	Header          string `json:"header,omitempty" example:"X-API-Key"`
+	ConnectionLimit int    `json:"connection_limit,omitempty" example:"10"`
+	StatusCode      int    `json:"status_code,omitempty" example:"429"`
+	ZoneSize        string `json:"zone_size,omitempty" example:"1m"`
+}
+
+// validateRateLimit normalizes and checks a rate limit definition
+func validateRateLimit(limit *RateLimit) error {
+	if limit == nil {
+		return nil
+	}
+	var errs fieldErrors
+
+	for _, field := range []struct {
+		name  string
+		value int
+	}{{"rate", limit.Rate}, {"burst", limit.Burst}, {"connection_limit", limit.ConnectionLimit}} {
+		if field.value < 0 {
+			errs.add(field.name, "%s must not be negative", field.name)
+		}
+	}
+	if limit.Rate == 0 && limit.ConnectionLimit == 0 {
+		errs.add("rate", "rate or connection_limit is required")
+	}
+	if limit.Rate == 0 && (limit.Burst > 0 || limit.NoDelay || limit.Per != "") {
+		errs.add("rate", "rate is required by burst, nodelay and per")
+	}
+	if limit.Rate > 0 {
+		if limit.Per == "" {
+			limit.Per = "second"
+		}
+		if limit.Per != "second" && limit.Per != "minute" {
+			errs.add("per", "per must be %q or %q", "second", "minute")
+		}
+	}
+
+	if limit.Key == "" {
+		limit.Key = limitKeyClientIP
+	}
+	switch limit.Key {
+	case limitKeyClientIP:
+		if limit.Header != "" {
+			errs.add("header", "header is only allowed with the %q or %q key", limitKeyHeader, limitKeyAPIKey)
+		}
+	case limitKeyHeader:
+		if limit.Header == "" {
+			errs.add("header", "header is required for the %q key", limitKeyHeader)
+		}
+	case limitKeyAPIKey:
+		if limit.Header == "" {
+			limit.Header = defaultAPIKeyHeader
+		}
+	default:
+		errs.add("key", "key must be one of %q, %q or %q", limitKeyClientIP, limitKeyHeader, limitKeyAPIKey)
+	}
+	if limit.Header != "" && !headerNamePattern.MatchString(limit.Header) {
+		errs.add("header", "header %q is not a valid header name", limit.Header)
+	}
+
+	if limit.StatusCode == 0 {
+		limit.StatusCode = 429
+	}
+	if limit.StatusCode < 400 || limit.StatusCode > 599 {
+		errs.add("status_code", "status_code must be between 400 and 599")
+	}
+
+	// One megabyte keeps about 16 thousand fixed-size client IP states.
+	// Header keys are variable length and need roughly twice the room.
+	if limit.ZoneSize == "" {
+		limit.ZoneSize = "1m"
+		if limit.Key != limitKeyClientIP {
+			limit.ZoneSize = "2m"
+		}
+	}
+	if !nginxSizePattern.MatchString(limit.ZoneSize) {
+		errs.add("zone_size", "zone_size must be an nginx size such as 1m")
+	}
+
+	return errs.err()
+}
+
+// rateLimitKey returns the nginx variable requests are counted by
+func rateLimitKey(zone string, limit *RateLimit) string {
+	if limit.Key == limitKeyClientIP {
+		return "$binary_remote_addr"
+	}
+	return "$bs_limit_key_" + zone
+}
+
+// renderRateLimitKeyMap renders the map behind a header key. nginx does not
+// count requests with an empty key, so requests without the header are
+// counted by client address instead of bypassing the limit.
+func renderRateLimitKeyMap(zone string, limit *RateLimit) []nginx.Directive {
+	if limit.Key == limitKeyClientIP {
+		return nil
+	}
+	header := headerVariable(limit.Header)
+	return []nginx.Directive{nginx.NewBlock("map", []string{header, rateLimitKey(zone, limit)},
+		nginx.Simple(`""`, "$binary_remote_addr"),
+		nginx.Simple("default", header),
+	)}
+}
+
+// rateLimitZones renders the limit_req_zone and limit_conn_zone directives
+// for a limit. zone identifies its owner, for example host1 or host1_loc0.
+func rateLimitZones(zone string, limit *RateLimit) []nginx.Directive {
+	if limit == nil {
+		return nil
+	}
+
+	key := rateLimitKey(zone, limit)
+	size := limit.ZoneSize
+
+	directives := renderRateLimitKeyMap(zone, limit)
+	if limit.Rate > 0 {
+		unit := "r/s"
+		if limit.Per == "minute" {
+			unit = "r/m"
+		}
+		directives = append(directives, nginx.Simple("limit_req_zone", key,
+			"zone=bs_req_"+zone+":"+size, "rate="+strconv.Itoa(limit.Rate)+unit))
+	}
+	if limit.ConnectionLimit > 0 {
+		directives = append(directives, nginx.Simple("limit_conn_zone", key, "zone=bs_conn_"+zone+":"+size))
+	}
+	return directives
+}
+
+// renderRateLimit renders the limit_req and limit_conn directives that use
+// the zones of a limit
+func renderRateLimit(zone string, limit *RateLimit) []nginx.Directive {
+	if limit == nil {
+		return nil
+	}
+
+	status := strconv.Itoa(limit.StatusCode)
+
+	var directives []nginx.Directive
+	if limit.Rate > 0 {
+		args := []string{"zone=bs_req_" + zone}
+		if limit.Burst > 0 {
+			args = append(args, "burst="+strconv.Itoa(limit.Burst))
+		}
+		if limit.NoDelay {
+			args = append(args, "nodelay")
+		}
+		directives = append(directives,
+			nginx.Simple("limit_req", args...),
+			nginx.Simple("limit_req_status", status),
+		)
+	}
+	if limit.ConnectionLimit > 0 {
+		directives = append(directives,
+			nginx.Simple("limit_conn", "bs_conn_"+zone, strconv.Itoa(limit.ConnectionLimit)),
+			nginx.Simple("limit_conn_status", status),
+		)
+	}
+	return directives
+}
+
+// hostZone names the shared zones and cache of a proxy host
+func hostZone(host ProxyHost) string {
+	return "host" + strconv.Itoa(host.ID)
+}
+
+// locationZone names the rate limit zones of a location
+func locationZone(host ProxyHost, index int) string {
+	return hostZone(host) + "_loc" + strconv.Itoa(index)
+}
+
+// renderRateLimitZones renders every shared zone a proxy host needs
+func renderRateLimitZones(host ProxyHost) []nginx.Directive {
+	directives := rateLimitZones(hostZone(host), host.RateLimit)
+	for i, loc := range host.Locations {
+		directives = append(directives, rateLimitZones(locationZone(host, i), loc.RateLimit)...)
+	}
+	return directives
+}
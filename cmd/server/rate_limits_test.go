@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+func TestRenderRateLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		limit        RateLimit
+		zones, rules string
+	}{
+		{
+			"client ip",
+			RateLimit{Rate: 10, Burst: 20, NoDelay: true},
+			"limit_req_zone $binary_remote_addr zone=bs_req_host1:1m rate=10r/s;\n",
+			"limit_req zone=bs_req_host1 burst=20 nodelay;\nlimit_req_status 429;\n",
+		},
+		{
+			"api key per minute with connections",
+			RateLimit{Rate: 60, Per: "minute", Key: limitKeyAPIKey, ConnectionLimit: 5, StatusCode: 503},
+			"map $http_x_api_key $bs_limit_key_host1 {\n    \"\" $binary_remote_addr;\n    default $http_x_api_key;\n}\n" +
+				"limit_req_zone $bs_limit_key_host1 zone=bs_req_host1:2m rate=60r/m;\n" +
+				"limit_conn_zone $bs_limit_key_host1 zone=bs_conn_host1:2m;\n",
+			"limit_req zone=bs_req_host1;\nlimit_req_status 503;\nlimit_conn bs_conn_host1 5;\nlimit_conn_status 503;\n",
+		},
+	}
+
+	for _, tt := range tests {
+		limit := tt.limit
+		if err := validateRateLimit(&limit); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := nginx.Render(rateLimitZones("host1", &limit)); got != tt.zones {
+			t.Errorf("%s: zones\n%s\nwant\n%s", tt.name, got, tt.zones)
+		}
+		if got := nginx.Render(renderRateLimit("host1", &limit)); got != tt.rules {
+			t.Errorf("%s: rules\n%s\nwant\n%s", tt.name, got, tt.rules)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+package main
+
+import (
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// redirectStatusCodes lists the status codes a redirection host may answer with
+var redirectStatusCodes = []int{301, 302, 307, 308}
+
+// RedirectionHost represents domains that redirect to another URL
+type RedirectionHost struct {
+	ID int `json:"id" example:"1"`
+	Revision
+	DomainNames   []string `json:"domain_names" example:"old-example.com,www.old-example.com"`
+	TargetURL     string   `json:"target_url" example:"https://example.com"`
+	StatusCode    int      `json:"status_code" example:"301"`
+	PreservePath  bool     `json:"preserve_path" example:"true"`
+	PreserveQuery bool     `json:"preserve_query" example:"true"`
+	SSLEnabled    bool     `json:"ssl_enabled" example:"true"`
+	SSLCertID     *int     `json:"ssl_cert_id,omitempty" example:"1"`
+	Enabled       bool     `json:"enabled" example:"true"`
+	CreatedAt     string   `json:"created_at" example:"2025-12-08T10:00:00Z"`
+}
+
+// RedirectionHostRequest represents the request body for creating/updating redirection hosts
+type RedirectionHostRequest struct {
+	DomainNames   []string `json:"domain_names" binding:"required,domain" example:"old-example.com"`
+	TargetURL     string   `json:"target_url" binding:"required" example:"https://example.com"`
+	StatusCode    int      `json:"status_code" example:"301"`
+	PreservePath  bool     `json:"preserve_path" example:"true"`
+	PreserveQuery bool     `json:"preserve_query" example:"true"`
+	SSLEnabled    bool     `json:"ssl_enabled" example:"false"`
+	SSLCertID     *int     `json:"ssl_cert_id,omitempty" example:"1"`
+}
+
+// validateRedirectionHost normalizes and checks a redirection host request.
+// Callers must hold store.mu.
+func validateRedirectionHost(req *RedirectionHostRequest) error {
+	var errs fieldErrors
+	target, err := url.Parse(req.TargetURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		errs.add("target_url", "target_url must be an absolute http or https URL")
+	} else if (req.PreservePath || req.PreserveQuery) && (target.RawQuery != "" || target.ForceQuery || target.Fragment != "") {
+		// The request path and query are appended to the target as they are
+		errs.add("target_url", "target_url cannot have a query or fragment when preserve_path or preserve_query is set")
+	}
+	if req.PreservePath {
+		// The request path is appended, so the target must not end with '/'
+		req.TargetURL = strings.TrimRight(req.TargetURL, "/")
+	}
+
+	if req.StatusCode == 0 {
+		req.StatusCode = 301
+	}
+	if !slices.Contains(redirectStatusCodes, req.StatusCode) {
+		errs.add("status_code", "status_code must be one of 301, 302, 307 or 308")
+	}
+
+	// Without a certificate renderListen leaves out the 443 listener
+	if req.SSLEnabled && req.SSLCertID == nil {
+		errs.add("ssl_cert_id", "ssl_cert_id is required when SSL is enabled")
+	}
+	errs.check("ssl_cert_id", validateCertificateRef(req.SSLCertID))
+	return errs.err()
+}
+
+// ListRedirectionHosts godoc
+// @Summary      List all redirection hosts
+// @Description  Get a list of all configured redirection hosts
+// @Tags         redirection-hosts
+// @Produce      json
+// @Success      200 {array} RedirectionHost
+// @Router       /redirection-hosts [get]
+func ListRedirectionHosts(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return c.JSON(store.redirectionHosts.all())
+}
+
+// CreateRedirectionHost godoc
+// @Summary      Create a new redirection host
+// @Description  Create domains that redirect to another URL
+// @Tags         redirection-hosts
+// @Accept       json
+// @Produce      json
+// @Param        host body RedirectionHostRequest true "Redirection Host Configuration"
+// @Success      201 {object} RedirectionHost
+// @Header       201 {string} ETag "Version of the redirection host"
+// @Failure      400 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Router       /redirection-hosts [post]
+func CreateRedirectionHost(c *fiber.Ctx) error {
+	var req RedirectionHostRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := validateRedirectionHost(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+	conflicts := domainConflicts(resourceRedirectionHost, 0, req.DomainNames)
+	if servesHTTP3(req.SSLEnabled, req.SSLCertID, nil) {
+		conflicts = append(conflicts, http3Conflicts(ConflictSide{Type: resourceRedirectionHost})...)
+	}
+	if len(conflicts) > 0 {
+		return respondConflicts(c, conflicts)
+	}
+
+	host := RedirectionHost{
+		ID:            store.redirectionHosts.newID(),
+		DomainNames:   req.DomainNames,
+		TargetURL:     req.TargetURL,
+		StatusCode:    req.StatusCode,
+		PreservePath:  req.PreservePath,
+		PreserveQuery: req.PreserveQuery,
+		SSLEnabled:    req.SSLEnabled,
+		SSLCertID:     req.SSLCertID,
+		Enabled:       true,
+		CreatedAt:     now(),
+	}
+	host = store.redirectionHosts.put(host.ID, host)
+
+	setETag(c, host.Version)
+	return c.Status(201).JSON(host)
+}
+
+// GetRedirectionHost godoc
+// @Summary      Get a redirection host
+// @Description  Get a specific redirection host by ID
+// @Tags         redirection-hosts
+// @Produce      json
+// @Param        id path int true "Redirection Host ID"
+// @Success      200 {object} RedirectionHost
+// @Header       200 {string} ETag "Version of the redirection host"
+// @Failure      404 {object} ErrorResponse
+// @Router       /redirection-hosts/{id} [get]
+func GetRedirectionHost(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	host, ok := store.redirectionHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Redirection host not found")
+	}
+
+	setETag(c, host.Version)
+	return c.JSON(host)
+}
+
+// UpdateRedirectionHost godoc
+// @Summary      Update a redirection host
+// @Description  Update an existing redirection host
+// @Tags         redirection-hosts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Redirection Host ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        host body RedirectionHostRequest true "Updated Redirection Host Configuration"
+// @Success      200 {object} RedirectionHost
+// @Header       200 {string} ETag "Version of the redirection host"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /redirection-hosts/{id} [put]
+func UpdateRedirectionHost(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req RedirectionHostRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	host, ok := store.redirectionHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Redirection host not found")
+	}
+	if err := checkIfMatch(c, host.Version); err != nil {
+		return respondStale(c, host.Version, err)
+	}
+	if err := validateRedirectionHost(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+	conflicts := domainConflicts(resourceRedirectionHost, id, req.DomainNames)
+	if servesHTTP3(req.SSLEnabled, req.SSLCertID, nil) {
+		conflicts = append(conflicts, http3Conflicts(ConflictSide{Type: resourceRedirectionHost, ID: id})...)
+	}
+	if len(conflicts) > 0 {
+		return respondConflicts(c, conflicts)
+	}
+
+	host.DomainNames = req.DomainNames
+	host.TargetURL = req.TargetURL
+	host.StatusCode = req.StatusCode
+	host.PreservePath = req.PreservePath
+	host.PreserveQuery = req.PreserveQuery
+	host.SSLEnabled = req.SSLEnabled
+	host.SSLCertID = req.SSLCertID
+	host = store.redirectionHosts.put(host.ID, host)
+
+	setETag(c, host.Version)
+	return c.JSON(host)
+}
+
+// PatchRedirectionHost godoc
+// @Summary      Partially update a redirection host
+// @Description  Change some fields of a redirection host with a JSON merge patch (RFC 7396)
+// @Tags         redirection-hosts
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Redirection Host ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        host body RedirectionHostRequest true "Fields to change"
+// @Success      200 {object} RedirectionHost
+// @Header       200 {string} ETag "Version of the redirection host"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /redirection-hosts/{id} [patch]
+func PatchRedirectionHost(c *fiber.Ctx) error {
+	return patchResource(c, store.redirectionHosts, "Redirection host not found", redirectionHostRequest, UpdateRedirectionHost)
+}
+
+// redirectionHostRequest returns the request that would set up a redirection
+// host as it is
+func redirectionHostRequest(host RedirectionHost) RedirectionHostRequest {
+	return RedirectionHostRequest{
+		DomainNames:   host.DomainNames,
+		TargetURL:     host.TargetURL,
+		StatusCode:    host.StatusCode,
+		PreservePath:  host.PreservePath,
+		PreserveQuery: host.PreserveQuery,
+		SSLEnabled:    host.SSLEnabled,
+		SSLCertID:     host.SSLCertID,
+	}
+}
+
+// DeleteRedirectionHost godoc
+// @Summary      Delete a redirection host
+// @Description  Delete a redirection host
+// @Tags         redirection-hosts
+// @Produce      json
+// @Param        id path int true "Redirection Host ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /redirection-hosts/{id} [delete]
+func DeleteRedirectionHost(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	host, ok := store.redirectionHosts.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Redirection host not found")
+	}
+	if err := checkIfMatch(c, host.Version); err != nil {
+		return respondStale(c, host.Version, err)
+	}
+	store.redirectionHosts.remove(id)
+
+	return c.JSON(fiber.Map{
+		"message": "Redirection host deleted successfully",
+		"id":      id,
+	})
+}
+
+// renderRedirectionHost renders the server block of a redirection host
+func renderRedirectionHost(host RedirectionHost) nginx.Directive {
+	server := renderListen(host.SSLEnabled, host.SSLCertID, nil)
+	server = append(server,
+		nginx.Simple("server_name", host.DomainNames...),
+		nginx.NewBlock("location", []string{"/"},
+			nginx.Simple("return", strconv.Itoa(host.StatusCode), redirectTarget(host)),
+		),
+	)
+
+	return nginx.NewBlock("server", nil, server...)
+}
+
+// requestPathVariable holds the path of $request_uri without the query. It
+// stays percent-encoded, unlike $uri, whose decoded CR and LF would split
+// the Location header.
+const requestPathVariable = "$bs_request_path"
+
+// usesRequestPath reports whether any enabled redirection host keeps the
+// path but drops the query
+func usesRequestPath() bool {
+	for _, host := range store.redirectionHosts.all() {
+		if host.Enabled && host.PreservePath && !host.PreserveQuery {
+			return true
+		}
+	}
+	return false
+}
+
+// renderRequestPathMap renders the map behind requestPathVariable
+func renderRequestPathMap() nginx.Directive {
+	return nginx.NewBlock("map", []string{"$request_uri", requestPathVariable},
+		nginx.Simple(`"~^([^?]*)"`, "$1"),
+	)
+}
+
+// redirectTarget returns the URL a redirection host answers with
+func redirectTarget(host RedirectionHost) string {
+	switch {
+	case host.PreservePath && host.PreserveQuery:
+		return host.TargetURL + "$request_uri"
+	case host.PreservePath:
+		return host.TargetURL + requestPathVariable
+	case host.PreserveQuery:
+		return host.TargetURL + "$is_args$args"
+	}
+	return host.TargetURL
+}
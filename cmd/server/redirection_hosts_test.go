@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+func TestRenderRedirectionHost(t *testing.T) {
+	tests := []struct {
+		name                        string
+		preservePath, preserveQuery bool
+		want                        string
+	}{
+		{"target only", false, false, "return 301 https://example.com;"},
+		{"path", true, false, "return 301 https://example.com$bs_request_path;"},
+		{"query", false, true, "return 301 https://example.com$is_args$args;"},
+		{"path and query", true, true, "return 301 https://example.com$request_uri;"},
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, tt := range tests {
+		host := RedirectionHost{
+			ID:            9000,
+			DomainNames:   []string{"old.example.com"},
+			TargetURL:     "https://example.com",
+			StatusCode:    301,
+			PreservePath:  tt.preservePath,
+			PreserveQuery: tt.preserveQuery,
+			Enabled:       true,
+		}
+		got := nginx.Render([]nginx.Directive{renderRedirectionHost(host)})
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s: rendered\n%s\nwant %s", tt.name, got, tt.want)
+		}
+		// $uri is decoded, so an encoded CR LF would split the Location header
+		if strings.Contains(got, "$uri") {
+			t.Errorf("%s: redirect uses $uri:\n%s", tt.name, got)
+		}
+
+		store.redirectionHosts.put(host.ID, host)
+		http := nginx.Render(renderHTTP())
+		store.redirectionHosts.remove(host.ID)
+		pathMap := "map $request_uri $bs_request_path {\n    \"~^([^?]*)\" $1;\n}\n"
+		if uses := tt.preservePath && !tt.preserveQuery; strings.Contains(http, pathMap) != uses {
+			t.Errorf("%s: http context has the request path map = %v, want %v", tt.name, !uses, uses)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"net"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+// nginxController applies generated configuration to the local nginx
+var nginxController = nginx.NewController(nginx.GetDefaultConfig())
+
+// httpConfigFile holds every generated http-context block
+const httpConfigFile = "balancer-studio.conf"
+
+// streamConfigFile holds the generated stream context. It is written to the
+// streams directory, which must be included from the main context of
+// nginx.conf.
+const streamConfigFile = "balancer-studio-streams.conf"
+
+// nginxTimePattern matches nginx time values such as 500ms, 30s or 10m
+var nginxTimePattern = regexp.MustCompile(`^[0-9]+(ms|s|m|h|d|w|M|y)?$`)
+
+// upstreamAlgorithms maps API algorithm names to nginx balancing directives
+var upstreamAlgorithms = map[string]string{
+	"round_robin": "",
+	"least_conn":  "least_conn",
+	"random":      "random",
+}
+
+// appliedFiles holds the content of every file written by the last
+// successful apply, by path. Guarded by store.mu.
+var appliedFiles = map[string]string{}
+
+// applyConfig renders the current state and applies it to nginx.
+// Callers must hold store.mu.
+func applyConfig() (string, error) {
+	files := renderConfigFiles()
+	output, err := "", nginxController.EnsureDirs(accessLogDir())
+	if err == nil {
+		output, err = nginxController.Apply(files)
+	}
+
+	if err != nil {
+		publishEvent(topicConfig, "config.failed", map[string]string{"output": output, "error": err.Error()})
+		return output, err
+	}
+
+	clear(appliedFiles)
+	for _, file := range files {
+		if !file.Remove {
+			appliedFiles[nginxController.Path(file.Path)] = file.Content
+		}
+	}
+	publishEvent(topicConfig, "config.applied", map[string]string{"output": output})
+	return output, nil
+}
+
+// removeStale adds a Remove entry for every file on disk matching pattern
+// that files no longer generates
+func removeStale(files []nginx.File, pattern string) []nginx.File {
+	stale, _ := filepath.Glob(pattern)
+	for _, path := range stale {
+		if !slices.ContainsFunc(files, func(file nginx.File) bool { return file.Path == path }) {
+			files = append(files, nginx.File{Path: path, Remove: true})
+		}
+	}
+	return files
+}
+
+// renderConfigFiles renders the current state into nginx files.
+// Callers must hold store.mu.
+func renderConfigFiles() []nginx.File {
+	streamsPath := nginxController.Config().StreamsPath
+
+	files := []nginx.File{{Path: httpConfigFile, Content: nginx.Render(renderHTTP())}}
+
+	// An empty stream block is left out rather than written
+	streamFile := nginx.File{Path: filepath.Join(streamsPath, streamConfigFile), Remove: true}
+	if stream := renderStreamContext(); len(stream.Block) > 0 {
+		streamFile = nginx.File{Path: streamFile.Path, Content: nginx.Render([]nginx.Directive{stream})}
+	}
+	files = append(files, streamFile)
+	files = append(files, renderHtpasswdFiles()...)
+	files = append(files, renderCABundleFiles()...)
+	files = append(files, renderErrorPageFiles()...)
+	return append(files, renderMaintenanceFiles()...)
+}
+
+// renderHTTP renders upstream and server blocks for the http context
+func renderHTTP() []nginx.Directive {
+	var directives []nginx.Directive
+
+	for _, upstream := range store.upstreams.all() {
+		if block, ok := renderUpstream(upstream); ok {
+			directives = append(directives, renderAffinityMaps(upstream)...)
+			directives = append(directives, block)
+		}
+	}
+
+	directives = append(directives, renderLogFormat())
+
+	if usesWebSocket() {
+		directives = append(directives, renderConnectionUpgradeMap())
+	}
+	if usesRequestPath() {
+		directives = append(directives, renderRequestPathMap())
+	}
+
+	for _, host := range store.proxyHosts.all() {
+		if host.Enabled {
+			directives = append(directives, renderRateLimitZones(host)...)
+			directives = append(directives, renderCachePath(host)...)
+			directives = append(directives, renderHeaderMaps(host)...)
+			directives = append(directives, renderClientDNMap(host)...)
+			directives = append(directives, renderMaintenanceGeo(host)...)
+		}
+	}
+
+	for _, host := range store.proxyHosts.all() {
+		if !host.Enabled {
+			continue
+		}
+		directives = append(directives, renderProxyHost(host))
+	}
+
+	for _, host := range store.redirectionHosts.all() {
+		if !host.Enabled {
+			continue
+		}
+		directives = append(directives, renderRedirectionHost(host))
+	}
+
+	directives = append(directives, renderDefaultServer()...)
+	directives = append(directives, renderStatusServer())
+
+	return directives
+}
+
+// renderStatusServer serves stub_status on the loopback address that
+// nginxStatus reads
+func renderStatusServer() nginx.Directive {
+	return nginx.NewBlock("server", nil,
+		nginx.Simple("listen", nginxController.Config().StatusAddr),
+		nginx.Simple("access_log", "off"),
+		nginx.NewBlock("location", []string{"=", nginx.StatusPath},
+			nginx.Simple("stub_status"),
+			nginx.Simple("allow", "127.0.0.1"),
+			nginx.Simple("allow", "::1"),
+			nginx.Simple("deny", "all"),
+		),
+	)
+}
+
+// renderUpstream renders an upstream block. Groups without servers are
+// skipped because nginx refuses to load an empty upstream.
+func renderUpstream(upstream Upstream) (nginx.Directive, bool) {
+	servers := store.serversOf(upstream.ID)
+	if len(servers) == 0 {
+		return nginx.Directive{}, false
+	}
+
+	var body []nginx.Directive
+	if method := upstreamAlgorithms[upstream.Algorithm]; method != "" {
+		body = append(body, nginx.Simple(method))
+	}
+	body = append(body, renderAffinity(upstream)...)
+
+	for _, server := range servers {
+		args := []string{net.JoinHostPort(server.Host, strconv.Itoa(server.Port))}
+		if server.Weight > 0 {
+			args = append(args, "weight="+strconv.Itoa(server.Weight))
+		}
+		if server.MaxFails > 0 {
+			args = append(args, "max_fails="+strconv.Itoa(server.MaxFails))
+		}
+		if server.Status == "down" {
+			args = append(args, "down")
+		}
+		body = append(body, nginx.Simple("server", args...))
+	}
+
+	return nginx.NewBlock("upstream", []string{upstream.Name}, body...), true
+}
+
+// renderProxyHost renders the server block of a proxy host
+func renderProxyHost(host ProxyHost) nginx.Directive {
+	server := renderListen(host.SSLEnabled, host.SSLCertID, host.TLSProfileID)
+	if hostHTTP2(host) && !slices.ContainsFunc(server, func(d nginx.Directive) bool { return d.Name == "http2" }) {
+		server = append(server, nginx.Simple("http2", "on"))
+	}
+	if defaultHost, ok := defaultProxyHost(); ok && defaultHost.ID == host.ID {
+		server = markDefaultServer(server)
+	}
+	server = append(server, renderClientAuth(host)...)
+	server = append(server, nginx.Simple("server_name", host.DomainNames...))
+	server = append(server, renderHostLogs(host)...)
+	server = append(server, renderAccessList(host.AccessListID)...)
+	server = append(server, renderRateLimit(hostZone(host), host.RateLimit)...)
+	server = append(server, renderCache(host)...)
+	server = append(server, renderBackend(host)...)
+	server = append(server, renderErrorPages(host)...)
+	server = append(server, renderMaintenancePage(host)...)
+	server = append(server, renderLocations(host)...)
+
+	return nginx.NewBlock("server", nil, server...)
+}
+
+// renderListen renders the listeners of a server block and, when SSL is
+// enabled with a certificate, the certificate paths and TLS profile. A nil
+// profile selects the default profile.
+func renderListen(sslEnabled bool, sslCertID *int, tlsProfileID *int) []nginx.Directive {
+	directives := []nginx.Directive{nginx.Simple("listen", "80")}
+
+	if sslEnabled && sslCertID != nil {
+		directives = append(directives, nginx.Simple("listen", "443", "ssl"))
+		directives = append(directives, renderCertificate(*sslCertID)...)
+		if profile, ok := tlsProfileFor(tlsProfileID); ok {
+			directives = append(directives, renderTLSProfile(profile, *sslCertID)...)
+		}
+	}
+
+	return directives
+}
+
+// renderCertificate renders the certificate and key paths of a certificate
+func renderCertificate(certID int) []nginx.Directive {
+	certDir := filepath.Join(nginxController.Config().CertsPath, strconv.Itoa(certID))
+	return []nginx.Directive{
+		nginx.Simple("ssl_certificate", filepath.Join(certDir, "fullchain.pem")),
+		nginx.Simple("ssl_certificate_key", filepath.Join(certDir, "privkey.pem")),
+	}
+}
+
+// proxyTarget is the backend a location forwards to: either an upstream
+// group or a single host and port
+type proxyTarget struct {
+	upstream *Upstream
+	host     string
+	port     int
+}
+
+// hostTarget returns the backend a proxy host forwards to. Blue/green
+// hosts forward to the upstream of their active color.
+func hostTarget(host ProxyHost) proxyTarget {
+	if host.BlueGreen != nil {
+		if upstream, ok := store.upstreams.get(host.BlueGreen.activeUpstreamID()); ok {
+			return proxyTarget{upstream: &upstream}
+		}
+	}
+	return proxyTarget{host: host.ForwardHost, port: host.ForwardPort}
+}
+
+// url returns the proxy_pass argument for the target
+func (t proxyTarget) url(scheme string) string {
+	if t.upstream != nil {
+		return scheme + "://" + t.upstream.Name
+	}
+	return scheme + "://" + net.JoinHostPort(t.host, strconv.Itoa(t.port))
+}
+
+// proxyDirectives returns proxy_pass with the standard forwarding headers.
+// An upstream that lost all its servers is not rendered, so requests for it
+// are answered with a 502 instead of pointing proxy_pass at a missing group.
+func proxyDirectives(target proxyTarget, scheme string) []nginx.Directive {
+	if target.upstream != nil && len(store.serversOf(target.upstream.ID)) == 0 {
+		return []nginx.Directive{nginx.Simple("return", "502")}
+	}
+
+	directives := []nginx.Directive{
+		nginx.Simple("proxy_pass", target.url(scheme)),
+		nginx.Simple("proxy_set_header", "Host", "$host"),
+		nginx.Simple("proxy_set_header", "X-Real-IP", "$remote_addr"),
+		nginx.Simple("proxy_set_header", "X-Forwarded-For", "$proxy_add_x_forwarded_for"),
+		nginx.Simple("proxy_set_header", "X-Forwarded-Proto", "$scheme"),
+	}
+	if target.upstream != nil {
+		directives = append(directives, renderAffinityLocation(*target.upstream)...)
+	}
+	return directives
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+)
+
+func TestRemoveStale(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"1.pem", "2.pem", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kept := nginx.File{Path: filepath.Join(dir, "1.pem"), Content: "PEM"}
+	got := removeStale([]nginx.File{kept}, filepath.Join(dir, "*.pem"))
+	want := []nginx.File{kept, {Path: filepath.Join(dir, "2.pem"), Remove: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("removeStale = %+v, want %+v", got, want)
+	}
+}
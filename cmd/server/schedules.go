@@ -0,0 +1,393 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// schedulerInterval is how often maintenance windows and scheduled changes are checked
+const schedulerInterval = 15 * time.Second
+
+// Scheduled change actions
+const (
+	scheduleEnableProxyHost  = "enable_proxy_host"
+	scheduleDisableProxyHost = "disable_proxy_host"
+	scheduleUpstreamWeights  = "set_upstream_weights"
+	scheduleEnterMaintenance = "enter_maintenance"
+	scheduleExitMaintenance  = "exit_maintenance"
+)
+
+// Scheduled change statuses
+const (
+	schedulePending   = "pending"
+	scheduleApplied   = "applied"
+	scheduleFailed    = "failed"
+	scheduleCancelled = "cancelled"
+)
+
+// ScheduledChange is a change that is applied through the apply pipeline at RunAt
+type ScheduledChange struct {
+	ID int `json:"id" example:"1"`
+	Revision
+	Action      string              `json:"action" example:"set_upstream_weights"`
+	RunAt       string              `json:"run_at" example:"2025-12-10T03:00:00Z"`
+	ProxyHostID *int                `json:"proxy_host_id,omitempty" example:"1"`
+	UpstreamID  *int                `json:"upstream_id,omitempty" example:"1"`
+	Weights     []ServerWeight      `json:"weights,omitempty"`
+	Maintenance *MaintenanceRequest `json:"maintenance,omitempty"`
+	Description string              `json:"description,omitempty" example:"Shift traffic to the new servers"`
+	Status      string              `json:"status" example:"pending"`
+	Output      string              `json:"output,omitempty"`
+	Error       string              `json:"error,omitempty"`
+	CreatedAt   string              `json:"created_at" example:"2025-12-08T10:00:00Z"`
+	ExecutedAt  string              `json:"executed_at,omitempty" example:"2025-12-10T03:00:05Z"`
+}
+
+// ScheduledChangeRequest represents the request body for scheduling a change
+type ScheduledChangeRequest struct {
+	Action      string              `json:"action" binding:"required" example:"set_upstream_weights"`
+	RunAt       string              `json:"run_at" binding:"required" example:"2025-12-10T03:00:00Z"`
+	ProxyHostID *int                `json:"proxy_host_id,omitempty" example:"1"`
+	UpstreamID  *int                `json:"upstream_id,omitempty" example:"1"`
+	Weights     []ServerWeight      `json:"weights,omitempty"`
+	Maintenance *MaintenanceRequest `json:"maintenance,omitempty"`
+	Description string              `json:"description,omitempty" example:"Shift traffic to the new servers"`
+}
+
+// ServerWeight sets the weight of one upstream server
+type ServerWeight struct {
+	ServerID int `json:"server_id" example:"2"`
+	Weight   int `json:"weight" example:"5"`
+}
+
+// validateScheduledChange checks a scheduled change against the current
+// state. Callers must hold store.mu.
+func validateScheduledChange(req *ScheduledChangeRequest, current time.Time) error {
+	var errs fieldErrors
+	runAt, err := time.Parse(time.RFC3339, req.RunAt)
+	if err != nil {
+		errs.add("run_at", "run_at must be an RFC 3339 time")
+	} else if !runAt.After(current) {
+		errs.add("run_at", "run_at must be in the future")
+	}
+
+	switch req.Action {
+	case scheduleEnableProxyHost, scheduleDisableProxyHost, scheduleEnterMaintenance, scheduleExitMaintenance:
+		if req.ProxyHostID == nil {
+			errs.add("proxy_host_id", "proxy_host_id is required for %s", req.Action)
+		} else if _, ok := store.proxyHosts.get(*req.ProxyHostID); !ok {
+			errs.add("proxy_host_id", "proxy_host_id: proxy host %d does not exist", *req.ProxyHostID)
+		}
+		if req.UpstreamID != nil || len(req.Weights) > 0 {
+			errs.add("upstream_id", "upstream_id and weights are only used by %s", scheduleUpstreamWeights)
+		}
+	case scheduleUpstreamWeights:
+		if req.UpstreamID == nil {
+			errs.add("upstream_id", "upstream_id is required for %s", req.Action)
+		} else if _, ok := store.upstreams.get(*req.UpstreamID); !ok {
+			errs.add("upstream_id", "upstream_id: upstream %d does not exist", *req.UpstreamID)
+		} else {
+			errs.check("weights", validateServerWeights(*req.UpstreamID, req.Weights))
+		}
+		if req.ProxyHostID != nil {
+			errs.add("proxy_host_id", "proxy_host_id is not used by %s", req.Action)
+		}
+	default:
+		errs.add("action", "action must be one of %q, %q, %q, %q or %q",
+			scheduleEnableProxyHost, scheduleDisableProxyHost, scheduleUpstreamWeights, scheduleEnterMaintenance, scheduleExitMaintenance)
+	}
+
+	if req.Action != scheduleEnterMaintenance {
+		if req.Maintenance != nil {
+			errs.add("maintenance", "maintenance is only used by %s", scheduleEnterMaintenance)
+		}
+		return errs.err()
+	}
+	if req.Maintenance == nil {
+		req.Maintenance = &MaintenanceRequest{}
+	}
+	if req.Maintenance.StartsAt != "" {
+		errs.add("maintenance.starts_at", "maintenance.starts_at is replaced by run_at")
+	}
+	if err == nil {
+		errs.nest("maintenance", validateMaintenance(req.Maintenance, runAt))
+	}
+	return errs.err()
+}
+
+// validateServerWeights checks that every server belongs to the upstream.
+// Callers must hold store.mu.
+func validateServerWeights(upstreamID int, weights []ServerWeight) error {
+	if _, ok := store.upstreams.get(upstreamID); !ok {
+		return fmt.Errorf("upstream %d does not exist", upstreamID)
+	}
+	if len(weights) == 0 {
+		return fmt.Errorf("weights is required")
+	}
+	for i, weight := range weights {
+		server, ok := store.upstreamServers.get(weight.ServerID)
+		if !ok || server.UpstreamID != upstreamID {
+			return fmt.Errorf("weights[%d]: server %d is not in upstream %d", i, weight.ServerID, upstreamID)
+		}
+		if weight.Weight < 1 {
+			return fmt.Errorf("weights[%d]: weight must be at least 1", i)
+		}
+	}
+	return nil
+}
+
+// executeChange applies the state change of a scheduled change and returns
+// a function that undoes it. Callers must hold store.mu.
+func executeChange(change ScheduledChange, current time.Time) (func(), error) {
+	if change.Action == scheduleUpstreamWeights {
+		if err := validateServerWeights(*change.UpstreamID, change.Weights); err != nil {
+			return nil, err
+		}
+		var previous []UpstreamServer
+		for _, weight := range change.Weights {
+			server, _ := store.upstreamServers.get(weight.ServerID)
+			previous = append(previous, server)
+			server.Weight = weight.Weight
+			store.upstreamServers.put(server.ID, server)
+		}
+		return func() {
+			for _, server := range previous {
+				store.upstreamServers.restore(server.ID, server)
+			}
+		}, nil
+	}
+
+	host, ok := store.proxyHosts.get(*change.ProxyHostID)
+	if !ok {
+		return nil, fmt.Errorf("proxy host %d no longer exists", *change.ProxyHostID)
+	}
+	previous := host
+
+	switch change.Action {
+	case scheduleEnableProxyHost:
+		host.Enabled = true
+	case scheduleDisableProxyHost:
+		if isDefaultProxyHost(host.ID) {
+			return nil, fmt.Errorf("proxy host %d is the default server", host.ID)
+		}
+		host.Enabled = false
+	case scheduleEnterMaintenance:
+		req := change.Maintenance
+		host.Maintenance = &Maintenance{
+			RetryAfter: req.RetryAfter,
+			Template:   req.Template,
+			AllowIPs:   req.AllowIPs,
+			StartsAt:   change.RunAt,
+			EndsAt:     req.EndsAt,
+			Active:     true,
+			UpdatedAt:  current.UTC().Format(time.RFC3339),
+		}
+	case scheduleExitMaintenance:
+		if host.Maintenance == nil {
+			return nil, fmt.Errorf("proxy host %d is not in maintenance mode", host.ID)
+		}
+		host.Maintenance = nil
+	}
+	store.proxyHosts.put(host.ID, host)
+
+	return func() { store.proxyHosts.restore(previous.ID, previous) }, nil
+}
+
+// dueChanges returns pending changes whose time has come, oldest first
+func dueChanges(current time.Time) []ScheduledChange {
+	var due []ScheduledChange
+	for _, change := range store.schedules.all() {
+		if change.Status != schedulePending {
+			continue
+		}
+		if runAt, err := time.Parse(time.RFC3339, change.RunAt); err == nil && !current.Before(runAt) {
+			due = append(due, change)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b ScheduledChange) int {
+		return cmp.Compare(a.RunAt, b.RunAt)
+	})
+	return due
+}
+
+// scheduleTick runs due changes one by one so each gets its own result
+func scheduleTick(current time.Time) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, change := range dueChanges(current) {
+		change.ExecutedAt = now()
+
+		undo, err := executeChange(change, current)
+		if err == nil {
+			change.Output, err = applyConfig()
+			if err != nil {
+				undo()
+			}
+		}
+
+		if err != nil {
+			change.Status = scheduleFailed
+			change.Error = err.Error()
+			log.Printf("schedule: change %d (%s) failed: %v", change.ID, change.Action, err)
+		} else {
+			change.Status = scheduleApplied
+			log.Printf("schedule: change %d (%s) applied", change.ID, change.Action)
+		}
+		store.schedules.put(change.ID, change)
+	}
+}
+
+// startScheduler runs maintenance windows and scheduled changes in the background
+func startScheduler() {
+	go func() {
+		ticker := time.NewTicker(schedulerInterval)
+		defer ticker.Stop()
+		for current := range ticker.C {
+			maintenanceTick(current)
+			scheduleTick(current)
+		}
+	}()
+}
+
+// ListSchedules godoc
+// @Summary      List scheduled changes
+// @Description  Get scheduled changes, optionally filtered by status (pending, applied, failed, cancelled)
+// @Tags         schedules
+// @Produce      json
+// @Param        status query string false "Status filter"
+// @Success      200 {array} ScheduledChange
+// @Router       /schedules [get]
+func ListSchedules(c *fiber.Ctx) error {
+	status := c.Query("status")
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	changes := []ScheduledChange{}
+	for _, change := range store.schedules.all() {
+		if status == "" || change.Status == status {
+			changes = append(changes, change)
+		}
+	}
+	return c.JSON(changes)
+}
+
+// CreateSchedule godoc
+// @Summary      Schedule a change
+// @Description  Enable or disable a proxy host, set upstream server weights, or enter/exit maintenance mode at a future time. Scheduled changes are kept in memory only: a restart drops pending changes without running them.
+// @Tags         schedules
+// @Accept       json
+// @Produce      json
+// @Param        change body ScheduledChangeRequest true "Scheduled Change"
+// @Success      201 {object} ScheduledChange
+// @Header       201 {string} ETag "Version of the scheduled change"
+// @Failure      400 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Router       /schedules [post]
+func CreateSchedule(c *fiber.Ctx) error {
+	var req ScheduledChangeRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := validateScheduledChange(&req, time.Now()); err != nil {
+		return respondInvalid(c, err)
+	}
+	if req.Action == scheduleDisableProxyHost && isDefaultProxyHost(*req.ProxyHostID) {
+		return respondError(c, 409, "Conflict", "Proxy host is the default server")
+	}
+
+	runAt, _ := time.Parse(time.RFC3339, req.RunAt)
+	change := ScheduledChange{
+		ID:          store.schedules.newID(),
+		Action:      req.Action,
+		RunAt:       runAt.UTC().Format(time.RFC3339),
+		ProxyHostID: req.ProxyHostID,
+		UpstreamID:  req.UpstreamID,
+		Weights:     req.Weights,
+		Maintenance: req.Maintenance,
+		Description: req.Description,
+		Status:      schedulePending,
+		CreatedAt:   now(),
+	}
+	change = store.schedules.put(change.ID, change)
+
+	setETag(c, change.Version)
+	return c.Status(201).JSON(change)
+}
+
+// GetSchedule godoc
+// @Summary      Get a scheduled change
+// @Description  Get a scheduled change and its result
+// @Tags         schedules
+// @Produce      json
+// @Param        id path int true "Scheduled Change ID"
+// @Success      200 {object} ScheduledChange
+// @Header       200 {string} ETag "Version of the scheduled change"
+// @Failure      404 {object} ErrorResponse
+// @Router       /schedules/{id} [get]
+func GetSchedule(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	change, ok := store.schedules.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Scheduled change not found")
+	}
+	setETag(c, change.Version)
+	return c.JSON(change)
+}
+
+// CancelSchedule godoc
+// @Summary      Cancel a scheduled change
+// @Description  Cancel a pending scheduled change. The record is kept with status cancelled.
+// @Tags         schedules
+// @Produce      json
+// @Param        id path int true "Scheduled Change ID"
+// @Param        If-Match header string false "ETag of the scheduled change version being cancelled"
+// @Success      200 {object} ScheduledChange
+// @Header       200 {string} ETag "Version of the scheduled change"
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /schedules/{id} [delete]
+func CancelSchedule(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	change, ok := store.schedules.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Scheduled change not found")
+	}
+	if err := checkIfMatch(c, change.Version); err != nil {
+		return respondStale(c, change.Version, err)
+	}
+	if change.Status != schedulePending {
+		return respondError(c, 409, "Conflict", fmt.Sprintf("Scheduled change is already %s", change.Status))
+	}
+
+	change.Status = scheduleCancelled
+	change = store.schedules.put(change.ID, change)
+
+	setETag(c, change.Version)
+	return c.JSON(change)
+}
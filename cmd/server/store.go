@@ -0,0 +1,217 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// store keeps resources in memory until the PostgreSQL integration lands.
+// Handlers hold mu for the whole request so that a render always sees a
+// consistent snapshot.
+var store = newMemoryStore()
+
+type memoryStore struct {
+	mu                sync.RWMutex
+	proxyHosts        *table[ProxyHost]
+	accessLists       *table[AccessList]
+	redirectionHosts  *table[RedirectionHost]
+	upstreams         *table[Upstream]
+	upstreamServers   *table[UpstreamServer]
+	streams           *table[Stream]
+	switches          *table[SwitchRecord]
+	tlsProfiles       *table[TLSProfile]
+	caBundles         *table[CABundle]
+	certificates      *table[Certificate]
+	webhooks          *table[Webhook]
+	webhookDeliveries *table[WebhookDelivery]
+	notifiers         *table[Notifier]
+	alertRules        *table[AlertRule]
+	alerts            *table[Alert]
+	schedules         *table[ScheduledChange]
+	defaultServer     DefaultServer
+}
+
+// table is an in-memory collection of rows keyed by ID
+type table[T any] struct {
+	rows   map[int]T
+	nextID int
+}
+
+func newTable[T any]() *table[T] {
+	return &table[T]{rows: map[int]T{}, nextID: 1}
+}
+
+// all returns every row ordered by ID
+func (t *table[T]) all() []T {
+	rows := make([]T, 0, len(t.rows))
+	for _, id := range slices.Sorted(maps.Keys(t.rows)) {
+		rows = append(rows, t.rows[id])
+	}
+	return rows
+}
+
+func (t *table[T]) get(id int) (T, bool) {
+	row, ok := t.rows[id]
+	return row, ok
+}
+
+// newID reserves the next free ID
+func (t *table[T]) newID() int {
+	id := t.nextID
+	t.nextID++
+	return id
+}
+
+// put stores a row and returns it. Rows embedding Revision get the version
+// after the one stored.
+func (t *table[T]) put(id int, row T) T {
+	if r, ok := any(&row).(versioned); ok {
+		r.revision().Version = versionOf(t.rows[id]) + 1
+	}
+	t.rows[id] = row
+	if id >= t.nextID {
+		t.nextID = id + 1
+	}
+	return row
+}
+
+// restore puts back a row read earlier, revision included, so undoing a
+// change that failed to apply leaves no trace in the version
+func (t *table[T]) restore(id int, row T) {
+	t.rows[id] = row
+}
+
+func (t *table[T]) remove(id int) bool {
+	if _, ok := t.rows[id]; !ok {
+		return false
+	}
+	delete(t.rows, id)
+	return true
+}
+
+func newMemoryStore() *memoryStore {
+	s := &memoryStore{
+		proxyHosts:        newTable[ProxyHost](),
+		accessLists:       newTable[AccessList](),
+		redirectionHosts:  newTable[RedirectionHost](),
+		upstreams:         newTable[Upstream](),
+		upstreamServers:   newTable[UpstreamServer](),
+		streams:           newTable[Stream](),
+		switches:          newTable[SwitchRecord](),
+		tlsProfiles:       newTable[TLSProfile](),
+		caBundles:         newTable[CABundle](),
+		certificates:      newTable[Certificate](),
+		webhooks:          newTable[Webhook](),
+		webhookDeliveries: newTable[WebhookDelivery](),
+		notifiers:         newTable[Notifier](),
+		alertRules:        newTable[AlertRule](),
+		alerts:            newTable[Alert](),
+		schedules:         newTable[ScheduledChange](),
+		defaultServer:     DefaultServer{Revision: Revision{Version: 1}, Action: defaultActionNginx},
+	}
+
+	for _, profile := range builtinTLSProfiles() {
+		profile.ID = s.tlsProfiles.newID()
+		profile.Builtin = true
+		s.tlsProfiles.put(profile.ID, profile)
+	}
+
+	// Seed data so the API is usable without a database
+	s.proxyHosts.put(1, ProxyHost{
+		ID:          1,
+		DomainNames: []string{"example.com", "www.example.com"},
+		ForwardHost: "192.168.1.100",
+		ForwardPort: 8080,
+		SSLEnabled:  true,
+		Enabled:     true,
+		CreatedAt:   "2025-12-08T10:00:00Z",
+	})
+	s.proxyHosts.put(2, ProxyHost{
+		ID:          2,
+		DomainNames: []string{"api.example.com"},
+		ForwardHost: "192.168.1.101",
+		ForwardPort: 3000,
+		SSLEnabled:  true,
+		Enabled:     true,
+		CreatedAt:   "2025-12-08T11:00:00Z",
+	})
+
+	s.certificates.put(1, Certificate{
+		ID:         1,
+		Name:       "example.com SSL",
+		Provider:   "letsencrypt",
+		DomainName: "example.com",
+		ExpiresAt:  "2025-12-31T23:59:59Z",
+		Status:     "active",
+	})
+	s.certificates.put(2, Certificate{
+		ID:         2,
+		Name:       "api.example.com SSL",
+		Provider:   "letsencrypt",
+		DomainName: "api.example.com",
+		ExpiresAt:  "2026-01-15T23:59:59Z",
+		Status:     "active",
+	})
+
+	s.upstreams.put(1, Upstream{
+		ID:          1,
+		Name:        "backend",
+		Algorithm:   "round_robin",
+		Description: "Backend application servers",
+	})
+	s.upstreams.put(2, Upstream{
+		ID:          2,
+		Name:        "api_servers",
+		Algorithm:   "least_conn",
+		Description: "API server pool",
+	})
+
+	s.upstreamServers.put(1, UpstreamServer{
+		ID:         1,
+		UpstreamID: 1,
+		Host:       "192.168.1.100",
+		Port:       8080,
+		Weight:     1,
+		MaxFails:   3,
+		Status:     "up",
+	})
+	s.upstreamServers.put(2, UpstreamServer{
+		ID:         2,
+		UpstreamID: 1,
+		Host:       "192.168.1.101",
+		Port:       8080,
+		Weight:     1,
+		MaxFails:   3,
+		Status:     "up",
+	})
+
+	return s
+}
+
+// serversOf returns the servers that belong to an upstream group
+func (s *memoryStore) serversOf(upstreamID int) []UpstreamServer {
+	var servers []UpstreamServer
+	for _, server := range s.upstreamServers.all() {
+		if server.UpstreamID == upstreamID {
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// upstreamByName looks up an upstream group by its nginx name
+func (s *memoryStore) upstreamByName(name string) (Upstream, bool) {
+	for _, upstream := range s.upstreams.all() {
+		if upstream.Name == name {
+			return upstream, true
+		}
+	}
+	return Upstream{}, false
+}
+
+// now returns the current time formatted like every timestamp in the API
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Stream protocols
+const (
+	protocolTCP = "tcp"
+	protocolUDP = "udp"
+)
+
+// httpListenPorts are the TCP ports nginx already listens on for proxy hosts
+var httpListenPorts = []int{80, 443}
+
+// Stream represents a TCP/UDP proxy rendered into the nginx stream context
+type Stream struct {
+	ID int `json:"id" example:"1"`
+	Revision
+	ListenPort   int    `json:"listen_port" example:"5432"`
+	Protocol     string `json:"protocol" example:"tcp"`
+	ForwardHost  string `json:"forward_host,omitempty" example:"192.168.1.200"`
+	ForwardPort  int    `json:"forward_port,omitempty" example:"5432"`
+	UpstreamID   *int   `json:"upstream_id,omitempty" example:"1"`
+	ProxyTimeout string `json:"proxy_timeout,omitempty" example:"10m"`
+	SSLEnabled   bool   `json:"ssl_enabled" example:"false"`
+	SSLCertID    *int   `json:"ssl_cert_id,omitempty" example:"1"`
+	Enabled      bool   `json:"enabled" example:"true"`
+	CreatedAt    string `json:"created_at" example:"2025-12-08T10:00:00Z"`
+}
+
+// StreamRequest represents the request body for creating/updating streams
+type StreamRequest struct {
+	ListenPort   int    `json:"listen_port" binding:"required,port" example:"5432"`
+	Protocol     string `json:"protocol" example:"tcp"`
+	ForwardHost  string `json:"forward_host,omitempty" binding:"host" example:"192.168.1.200"`
+	ForwardPort  int    `json:"forward_port,omitempty" binding:"port" example:"5432"`
+	UpstreamID   *int   `json:"upstream_id,omitempty" example:"1"`
+	ProxyTimeout string `json:"proxy_timeout,omitempty" example:"10m"`
+	SSLEnabled   bool   `json:"ssl_enabled" example:"false"`
+	SSLCertID    *int   `json:"ssl_cert_id,omitempty" example:"1"`
+}
+
+// validateStream normalizes and checks a stream request. Ports used by
+// other streams or the http listeners are reported by streamConflicts. Callers must hold store.mu.
+func validateStream(req *StreamRequest) error {
+	var errs fieldErrors
+	if req.Protocol == "" {
+		req.Protocol = protocolTCP
+	}
+	if req.Protocol != protocolTCP && req.Protocol != protocolUDP {
+		errs.add("protocol", "protocol must be %q or %q", protocolTCP, protocolUDP)
+	}
+
+	if req.UpstreamID != nil {
+		if req.ForwardHost != "" || req.ForwardPort != 0 {
+			errs.add("upstream_id", "set either upstream_id or forward_host/forward_port, not both")
+		} else if upstream, ok := store.upstreams.get(*req.UpstreamID); !ok {
+			errs.add("upstream_id", "upstream_id: upstream %d does not exist", *req.UpstreamID)
+		} else if len(store.serversOf(upstream.ID)) == 0 {
+			errs.add("upstream_id", "upstream_id: upstream %q has no servers", upstream.Name)
+		} else {
+			errs.check("upstream_id", streamAffinityError(upstream))
+		}
+	} else {
+		if req.ForwardHost == "" {
+			errs.add("forward_host", "forward_host or upstream_id is required")
+		}
+		if req.ForwardPort < 1 || req.ForwardPort > 65535 {
+			errs.add("forward_port", "forward_port must be between 1 and 65535")
+		}
+	}
+
+	if req.ProxyTimeout != "" && !nginxTimePattern.MatchString(req.ProxyTimeout) {
+		errs.add("proxy_timeout", "proxy_timeout must be an nginx time such as 30s or 10m")
+	}
+	if req.SSLEnabled && req.Protocol == protocolUDP {
+		errs.add("ssl_enabled", "ssl_enabled: SSL termination is only supported for TCP streams")
+	}
+	if req.SSLEnabled && req.SSLCertID == nil {
+		errs.add("ssl_cert_id", "ssl_cert_id is required when SSL is enabled")
+	}
+	errs.check("ssl_cert_id", validateCertificateRef(req.SSLCertID))
+
+	return errs.err()
+}
+
+// streamAffinityError rejects affinity the stream context cannot express.
+// Cookies and request variables only exist in the http context, so only
+// ip_hash is rendered for streams.
+func streamAffinityError(upstream Upstream) error {
+	if upstream.Affinity != nil && upstream.Affinity.Mode != affinityIPHash {
+		return fmt.Errorf("upstream %q uses %s affinity, which the stream context does not support", upstream.Name, upstream.Affinity.Mode)
+	}
+	return nil
+}
+
+// ListStreams godoc
+// @Summary      List all streams
+// @Description  Get a list of all TCP/UDP streams
+// @Tags         streams
+// @Produce      json
+// @Success      200 {array} Stream
+// @Router       /streams [get]
+func ListStreams(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return c.JSON(store.streams.all())
+}
+
+// CreateStream godoc
+// @Summary      Create a new stream
+// @Description  Create a TCP/UDP proxy in the Nginx stream context
+// @Tags         streams
+// @Accept       json
+// @Produce      json
+// @Param        stream body StreamRequest true "Stream Configuration"
+// @Success      201 {object} Stream
+// @Header       201 {string} ETag "Version of the stream"
+// @Failure      400 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Router       /streams [post]
+func CreateStream(c *fiber.Ctx) error {
+	var req StreamRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if err := validateStream(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+	if conflicts := streamConflicts(0, req.ListenPort, req.Protocol); len(conflicts) > 0 {
+		return respondConflicts(c, conflicts)
+	}
+
+	stream := Stream{
+		ID:           store.streams.newID(),
+		ListenPort:   req.ListenPort,
+		Protocol:     req.Protocol,
+		ForwardHost:  req.ForwardHost,
+		ForwardPort:  req.ForwardPort,
+		UpstreamID:   req.UpstreamID,
+		ProxyTimeout: req.ProxyTimeout,
+		SSLEnabled:   req.SSLEnabled,
+		SSLCertID:    req.SSLCertID,
+		Enabled:      true,
+		CreatedAt:    now(),
+	}
+	stream = store.streams.put(stream.ID, stream)
+
+	setETag(c, stream.Version)
+	return c.Status(201).JSON(stream)
+}
+
+// GetStream godoc
+// @Summary      Get a stream
+// @Description  Get a specific TCP/UDP stream by ID
+// @Tags         streams
+// @Produce      json
+// @Param        id path int true "Stream ID"
+// @Success      200 {object} Stream
+// @Header       200 {string} ETag "Version of the stream"
+// @Failure      404 {object} ErrorResponse
+// @Router       /streams/{id} [get]
+func GetStream(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	stream, ok := store.streams.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Stream not found")
+	}
+
+	setETag(c, stream.Version)
+	return c.JSON(stream)
+}
+
+// UpdateStream godoc
+// @Summary      Update a stream
+// @Description  Update an existing TCP/UDP stream
+// @Tags         streams
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Stream ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        stream body StreamRequest true "Updated Stream Configuration"
+// @Success      200 {object} Stream
+// @Header       200 {string} ETag "Version of the stream"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /streams/{id} [put]
+func UpdateStream(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req StreamRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	stream, ok := store.streams.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Stream not found")
+	}
+	if err := checkIfMatch(c, stream.Version); err != nil {
+		return respondStale(c, stream.Version, err)
+	}
+	if err := validateStream(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+	if conflicts := streamConflicts(id, req.ListenPort, req.Protocol); len(conflicts) > 0 {
+		return respondConflicts(c, conflicts)
+	}
+
+	stream.ListenPort = req.ListenPort
+	stream.Protocol = req.Protocol
+	stream.ForwardHost = req.ForwardHost
+	stream.ForwardPort = req.ForwardPort
+	stream.UpstreamID = req.UpstreamID
+	stream.ProxyTimeout = req.ProxyTimeout
+	stream.SSLEnabled = req.SSLEnabled
+	stream.SSLCertID = req.SSLCertID
+	stream = store.streams.put(stream.ID, stream)
+
+	setETag(c, stream.Version)
+	return c.JSON(stream)
+}
+
+// PatchStream godoc
+// @Summary      Partially update a stream
+// @Description  Change some fields of a TCP/UDP stream with a JSON merge patch (RFC 7396)
+// @Tags         streams
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Stream ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        stream body StreamRequest true "Fields to change"
+// @Success      200 {object} Stream
+// @Header       200 {string} ETag "Version of the stream"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /streams/{id} [patch]
+func PatchStream(c *fiber.Ctx) error {
+	return patchResource(c, store.streams, "Stream not found", streamRequest, UpdateStream)
+}
+
+// streamRequest returns the request that would set up a stream as it is
+func streamRequest(stream Stream) StreamRequest {
+	return StreamRequest{
+		ListenPort:   stream.ListenPort,
+		Protocol:     stream.Protocol,
+		ForwardHost:  stream.ForwardHost,
+		ForwardPort:  stream.ForwardPort,
+		UpstreamID:   stream.UpstreamID,
+		ProxyTimeout: stream.ProxyTimeout,
+		SSLEnabled:   stream.SSLEnabled,
+		SSLCertID:    stream.SSLCertID,
+	}
+}
+
+// DeleteStream godoc
+// @Summary      Delete a stream
+// @Description  Delete a TCP/UDP stream
+// @Tags         streams
+// @Produce      json
+// @Param        id path int true "Stream ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /streams/{id} [delete]
+func DeleteStream(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	stream, ok := store.streams.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Stream not found")
+	}
+	if err := checkIfMatch(c, stream.Version); err != nil {
+		return respondStale(c, stream.Version, err)
+	}
+	store.streams.remove(id)
+
+	return c.JSON(fiber.Map{
+		"message": "Stream deleted successfully",
+		"id":      id,
+	})
+}
+
+// renderStreamContext renders the stream {} block with the upstream groups
+// used by streams and one server per enabled stream
+func renderStreamContext() nginx.Directive {
+	var body []nginx.Directive
+	// rendered records whether an upstream produced a block; groups without
+	// servers do not, and streams proxying to them are left out
+	rendered := map[int]bool{}
+
+	for _, stream := range store.streams.all() {
+		if !stream.Enabled || stream.UpstreamID == nil {
+			continue
+		}
+		if _, seen := rendered[*stream.UpstreamID]; seen {
+			continue
+		}
+		rendered[*stream.UpstreamID] = false
+
+		upstream, ok := store.upstreams.get(*stream.UpstreamID)
+		if !ok {
+			continue
+		}
+		if block, ok := renderStreamUpstream(upstream); ok {
+			body = append(body, block)
+			rendered[*stream.UpstreamID] = true
+		}
+	}
+
+	for _, stream := range store.streams.all() {
+		if !stream.Enabled || (stream.UpstreamID != nil && !rendered[*stream.UpstreamID]) {
+			continue
+		}
+		body = append(body, renderStream(stream))
+	}
+
+	return nginx.NewBlock("stream", nil, body...)
+}
+
+// renderStreamUpstream renders an upstream group for the stream context,
+// which has no ip_hash and uses hash $remote_addr instead
+func renderStreamUpstream(upstream Upstream) (nginx.Directive, bool) {
+	block, ok := renderUpstream(Upstream{
+		ID:        upstream.ID,
+		Name:      upstream.Name,
+		Algorithm: upstream.Algorithm,
+	})
+	if !ok {
+		return block, false
+	}
+
+	if upstream.Affinity != nil && upstream.Affinity.Mode == affinityIPHash {
+		block.Block = append([]nginx.Directive{nginx.Simple("hash", "$remote_addr")}, block.Block...)
+	}
+	return block, true
+}
+
+// renderStream renders the server block of a stream
+func renderStream(stream Stream) nginx.Directive {
+	listen := []string{strconv.Itoa(stream.ListenPort)}
+	if stream.Protocol == protocolUDP {
+		listen = append(listen, "udp")
+	}
+	if stream.SSLEnabled {
+		listen = append(listen, "ssl")
+	}
+
+	server := []nginx.Directive{nginx.Simple("listen", listen...)}
+
+	target := net.JoinHostPort(stream.ForwardHost, strconv.Itoa(stream.ForwardPort))
+	if stream.UpstreamID != nil {
+		if upstream, ok := store.upstreams.get(*stream.UpstreamID); ok {
+			target = upstream.Name
+		}
+	}
+	server = append(server, nginx.Simple("proxy_pass", target))
+
+	if stream.ProxyTimeout != "" {
+		server = append(server, nginx.Simple("proxy_timeout", stream.ProxyTimeout))
+	}
+	if stream.SSLEnabled && stream.SSLCertID != nil {
+		server = append(server, renderCertificate(*stream.SSLCertID)...)
+	}
+
+	return nginx.NewBlock("server", nil, server...)
+}
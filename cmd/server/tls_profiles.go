@@ -0,0 +1,502 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/VladislavUsenko/balancer-studio/internal/nginx"
+	"github.com/gofiber/fiber/v2"
+)
+
+// tlsProtocols lists the protocol versions a profile may enable, oldest first
+var tlsProtocols = []string{"TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3"}
+
+// deprecatedTLSProtocols are versions deprecated by RFC 8996
+var deprecatedTLSProtocols = []string{"TLSv1", "TLSv1.1"}
+
+// cipherListPattern matches OpenSSL cipher strings
+var cipherListPattern = regexp.MustCompile(`^[A-Za-z0-9:+!@_=.-]+$`)
+
+// defaultTLSProfile is used by SSL-enabled hosts without a profile
+const defaultTLSProfile = "intermediate"
+
+// TLSProfile is a named TLS policy for SSL-enabled hosts
+type TLSProfile struct {
+	ID int `json:"id" example:"2"`
+	Revision
+	Name                string   `json:"name" example:"intermediate"`
+	Description         string   `json:"description" example:"Recommended for general-purpose servers"`
+	Builtin             bool     `json:"builtin" example:"true"`
+	Protocols           []string `json:"protocols" example:"TLSv1.2,TLSv1.3"`
+	Ciphers             string   `json:"ciphers,omitempty" example:"ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256"`
+	PreferServerCiphers bool     `json:"prefer_server_ciphers" example:"false"`
+	SessionCacheSize    string   `json:"session_cache_size" example:"10m"`
+	SessionTimeout      string   `json:"session_timeout" example:"1d"`
+	SessionTickets      bool     `json:"session_tickets" example:"false"`
+	OCSPStapling        bool     `json:"ocsp_stapling" example:"true"`
+	HTTP2               bool     `json:"http2" example:"true"`
+	HTTP3               bool     `json:"http3" example:"false"`
+}
+
+// TLSProfileRequest represents the request body for creating/updating custom TLS profiles
+type TLSProfileRequest struct {
+	Name                string   `json:"name" binding:"required" example:"internal-apis"`
+	Description         string   `json:"description" example:"TLS 1.3 only with HTTP/3"`
+	Protocols           []string `json:"protocols" binding:"required" example:"TLSv1.3"`
+	Ciphers             string   `json:"ciphers,omitempty"`
+	PreferServerCiphers bool     `json:"prefer_server_ciphers" example:"false"`
+	SessionCacheSize    string   `json:"session_cache_size" example:"10m"`
+	SessionTimeout      string   `json:"session_timeout" example:"1d"`
+	SessionTickets      bool     `json:"session_tickets" example:"false"`
+	OCSPStapling        bool     `json:"ocsp_stapling" example:"true"`
+	HTTP2               bool     `json:"http2" example:"true"`
+	HTTP3               bool     `json:"http3" example:"true"`
+}
+
+// DeprecatedTLSHost reports a host that still accepts deprecated TLS versions
+type DeprecatedTLSHost struct {
+	ProxyHostID         int      `json:"proxy_host_id" example:"1"`
+	DomainNames         []string `json:"domain_names" example:"example.com"`
+	TLSProfileID        int      `json:"tls_profile_id" example:"3"`
+	TLSProfileName      string   `json:"tls_profile_name" example:"legacy"`
+	DeprecatedProtocols []string `json:"deprecated_protocols" example:"TLSv1,TLSv1.1"`
+}
+
+// builtinTLSProfiles follow the Mozilla server side TLS guidelines
+func builtinTLSProfiles() []TLSProfile {
+	return []TLSProfile{
+		{
+			Name:             "modern",
+			Description:      "TLS 1.3 only, for clients that are known to be recent",
+			Protocols:        []string{"TLSv1.3"},
+			SessionCacheSize: "10m",
+			SessionTimeout:   "1d",
+			OCSPStapling:     true,
+			HTTP2:            true,
+		},
+		{
+			Name:             "intermediate",
+			Description:      "Recommended for general-purpose servers",
+			Protocols:        []string{"TLSv1.2", "TLSv1.3"},
+			Ciphers:          "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305:DHE-RSA-AES128-GCM-SHA256:DHE-RSA-AES256-GCM-SHA384:DHE-RSA-CHACHA20-POLY1305",
+			SessionCacheSize: "10m",
+			SessionTimeout:   "1d",
+			OCSPStapling:     true,
+			HTTP2:            true,
+		},
+		{
+			Name:                "legacy",
+			Description:         "Compatible with very old clients, uses deprecated TLS versions",
+			Protocols:           []string{"TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3"},
+			Ciphers:             "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:ECDHE-RSA-AES256-GCM-SHA384:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305:DHE-RSA-AES128-GCM-SHA256:DHE-RSA-AES256-GCM-SHA384:DHE-RSA-CHACHA20-POLY1305:ECDHE-ECDSA-AES128-SHA256:ECDHE-RSA-AES128-SHA256:ECDHE-ECDSA-AES128-SHA:ECDHE-RSA-AES128-SHA:ECDHE-ECDSA-AES256-SHA384:ECDHE-RSA-AES256-SHA384:ECDHE-ECDSA-AES256-SHA:ECDHE-RSA-AES256-SHA:DHE-RSA-AES128-SHA256:DHE-RSA-AES256-SHA256:AES128-GCM-SHA256:AES256-GCM-SHA384:AES128-SHA256:AES256-SHA256:AES128-SHA:AES256-SHA:DES-CBC3-SHA",
+			PreferServerCiphers: true,
+			SessionCacheSize:    "10m",
+			SessionTimeout:      "1d",
+			OCSPStapling:        true,
+			HTTP2:               true,
+		},
+	}
+}
+
+// validateTLSProfile normalizes and checks a custom TLS profile request
+func validateTLSProfile(req *TLSProfileRequest) error {
+	var errs fieldErrors
+	for i, protocol := range req.Protocols {
+		if !slices.Contains(tlsProtocols, protocol) {
+			errs.add(fmt.Sprintf("protocols[%d]", i), "protocols[%d]: unknown protocol %q", i, protocol)
+		}
+	}
+	if req.Ciphers != "" && !cipherListPattern.MatchString(req.Ciphers) {
+		errs.add("ciphers", "ciphers must be an OpenSSL cipher list")
+	}
+
+	if req.SessionCacheSize == "" {
+		req.SessionCacheSize = "10m"
+	}
+	if req.SessionTimeout == "" {
+		req.SessionTimeout = "1d"
+	}
+	if !nginxSizePattern.MatchString(req.SessionCacheSize) {
+		errs.add("session_cache_size", "session_cache_size must be an nginx size such as 10m")
+	}
+	if !nginxTimePattern.MatchString(req.SessionTimeout) {
+		errs.add("session_timeout", "session_timeout must be an nginx time such as 1d")
+	}
+	if req.HTTP3 && !slices.Contains(req.Protocols, "TLSv1.3") {
+		errs.add("http3", "http3 requires TLSv1.3")
+	}
+	return errs.err()
+}
+
+// validateTLSProfileRef checks the profile assigned to a host.
+// Callers must hold store.mu.
+func validateTLSProfileRef(id *int, sslEnabled bool) error {
+	if id == nil {
+		return nil
+	}
+	if !sslEnabled {
+		return fmt.Errorf("tls_profile_id requires ssl_enabled")
+	}
+	if _, ok := store.tlsProfiles.get(*id); !ok {
+		return fmt.Errorf("TLS profile %d does not exist", *id)
+	}
+	return nil
+}
+
+// tlsProfileServed reports whether an SSL host with a certificate uses the
+// profile, so that turning on HTTP/3 opens UDP 443. Callers must hold store.mu.
+func tlsProfileServed(id int) bool {
+	return slices.ContainsFunc(store.proxyHosts.all(), func(host ProxyHost) bool {
+		return host.SSLEnabled && host.SSLCertID != nil && host.TLSProfileID != nil && *host.TLSProfileID == id
+	})
+}
+
+// tlsProfileFor returns the profile assigned to a host or the default one.
+// Callers must hold store.mu.
+func tlsProfileFor(id *int) (TLSProfile, bool) {
+	if id != nil {
+		if profile, ok := store.tlsProfiles.get(*id); ok {
+			return profile, true
+		}
+	}
+	for _, profile := range store.tlsProfiles.all() {
+		if profile.Builtin && profile.Name == defaultTLSProfile {
+			return profile, true
+		}
+	}
+	return TLSProfile{}, false
+}
+
+// deprecatedProtocols returns the deprecated versions a profile enables
+func deprecatedProtocols(profile TLSProfile) []string {
+	var deprecated []string
+	for _, protocol := range profile.Protocols {
+		if slices.Contains(deprecatedTLSProtocols, protocol) {
+			deprecated = append(deprecated, protocol)
+		}
+	}
+	return deprecated
+}
+
+// renderTLSProfile renders the TLS settings of a server block. certID is
+// used to locate the chain for OCSP stapling.
+func renderTLSProfile(profile TLSProfile, certID int) []nginx.Directive {
+	var directives []nginx.Directive
+
+	if profile.HTTP3 {
+		directives = append(directives, nginx.Simple("listen", "443", "quic"))
+	}
+	if profile.HTTP2 {
+		directives = append(directives, nginx.Simple("http2", "on"))
+	}
+	directives = append(directives, renderHandshakeSettings(profile)...)
+
+	if profile.OCSPStapling {
+		directives = append(directives, nginx.Simple("ssl_stapling", "on"))
+		// Without chain.pem nginx finds the issuer in fullchain.pem but
+		// cannot verify the responses
+		chain := filepath.Join(nginxController.Config().CertsPath, strconv.Itoa(certID), "chain.pem")
+		if _, err := os.Stat(chain); err == nil {
+			directives = append(directives,
+				nginx.Simple("ssl_stapling_verify", "on"),
+				nginx.Simple("ssl_trusted_certificate", chain),
+			)
+		}
+	}
+	return directives
+}
+
+// renderHandshakeSettings renders the settings nginx applies before SNI
+// picks a server. On a shared listen socket they are taken from the default
+// server of the socket, whatever the profile of the requested host says.
+func renderHandshakeSettings(profile TLSProfile) []nginx.Directive {
+	var directives []nginx.Directive
+	directives = append(directives, nginx.Simple("ssl_protocols", profile.Protocols...))
+	if profile.Ciphers != "" {
+		directives = append(directives, nginx.Simple("ssl_ciphers", profile.Ciphers))
+	}
+	directives = append(directives,
+		nginx.Simple("ssl_prefer_server_ciphers", onOff(profile.PreferServerCiphers)),
+		// Every profile gets its own zone since zones of one name must share a size
+		nginx.Simple("ssl_session_cache", "shared:bs_tls_"+strconv.Itoa(profile.ID)+":"+profile.SessionCacheSize),
+		nginx.Simple("ssl_session_timeout", profile.SessionTimeout),
+		nginx.Simple("ssl_session_tickets", onOff(profile.SessionTickets)),
+	)
+	return directives
+}
+
+// altSvcRule advertises HTTP/3 to clients that connected over TCP
+func altSvcRule() HeaderRule {
+	return HeaderRule{
+		Direction: headerResponse,
+		Action:    headerSet,
+		Name:      "Alt-Svc",
+		Value:     `h3=":443"; ma=86400`,
+		Always:    true,
+	}
+}
+
+// onOff renders a boolean as an nginx flag
+func onOff(value bool) string {
+	if value {
+		return "on"
+	}
+	return "off"
+}
+
+// ListTLSProfiles godoc
+// @Summary      List TLS profiles
+// @Description  Get the built-in (modern, intermediate, legacy) and custom TLS profiles
+// @Tags         tls-profiles
+// @Produce      json
+// @Success      200 {array} TLSProfile
+// @Router       /tls-profiles [get]
+func ListTLSProfiles(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return c.JSON(store.tlsProfiles.all())
+}
+
+// CreateTLSProfile godoc
+// @Summary      Create a custom TLS profile
+// @Description  Create a TLS profile with custom protocols, ciphers, session and HTTP/2/HTTP/3 settings
+// @Tags         tls-profiles
+// @Accept       json
+// @Produce      json
+// @Param        profile body TLSProfileRequest true "TLS Profile"
+// @Success      201 {object} TLSProfile
+// @Header       201 {string} ETag "Version of the TLS profile"
+// @Failure      400 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Router       /tls-profiles [post]
+func CreateTLSProfile(c *fiber.Ctx) error {
+	var req TLSProfileRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateTLSProfile(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if _, exists := tlsProfileByName(req.Name); exists {
+		return respondError(c, 409, "Conflict", fmt.Sprintf("TLS profile %q already exists", req.Name))
+	}
+
+	profile := tlsProfileFromRequest(req)
+	profile.ID = store.tlsProfiles.newID()
+	profile = store.tlsProfiles.put(profile.ID, profile)
+
+	setETag(c, profile.Version)
+	return c.Status(201).JSON(profile)
+}
+
+// UpdateTLSProfile godoc
+// @Summary      Update a custom TLS profile
+// @Description  Update a custom TLS profile. Built-in profiles cannot be changed.
+// @Tags         tls-profiles
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "TLS Profile ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        profile body TLSProfileRequest true "Updated TLS Profile"
+// @Success      200 {object} TLSProfile
+// @Header       200 {string} ETag "Version of the TLS profile"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /tls-profiles/{id} [put]
+func UpdateTLSProfile(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req TLSProfileRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateTLSProfile(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	profile, ok := store.tlsProfiles.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "TLS profile not found")
+	}
+	if err := checkIfMatch(c, profile.Version); err != nil {
+		return respondStale(c, profile.Version, err)
+	}
+	if profile.Builtin {
+		return respondError(c, 409, "Conflict", "Built-in TLS profiles cannot be changed")
+	}
+	if existing, exists := tlsProfileByName(req.Name); exists && existing.ID != id {
+		return respondError(c, 409, "Conflict", fmt.Sprintf("TLS profile %q already exists", req.Name))
+	}
+
+	profile = tlsProfileFromRequest(req)
+	profile.ID = id
+	if profile.HTTP3 && tlsProfileServed(id) {
+		if conflicts := http3Conflicts(ConflictSide{Type: resourceTLSProfile, ID: id}); len(conflicts) > 0 {
+			return respondConflicts(c, conflicts)
+		}
+	}
+	profile = store.tlsProfiles.put(profile.ID, profile)
+
+	setETag(c, profile.Version)
+	return c.JSON(profile)
+}
+
+// PatchTLSProfile godoc
+// @Summary      Partially update a custom TLS profile
+// @Description  Change some fields of a custom TLS profile with a JSON merge patch (RFC 7396)
+// @Tags         tls-profiles
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "TLS Profile ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        profile body TLSProfileRequest true "Fields to change"
+// @Success      200 {object} TLSProfile
+// @Header       200 {string} ETag "Version of the TLS profile"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /tls-profiles/{id} [patch]
+func PatchTLSProfile(c *fiber.Ctx) error {
+	return patchResource(c, store.tlsProfiles, "TLS profile not found", tlsProfileRequest, UpdateTLSProfile)
+}
+
+// DeleteTLSProfile godoc
+// @Summary      Delete a custom TLS profile
+// @Description  Delete a custom TLS profile that no proxy host uses
+// @Tags         tls-profiles
+// @Produce      json
+// @Param        id path int true "TLS Profile ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      409 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /tls-profiles/{id} [delete]
+func DeleteTLSProfile(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	profile, ok := store.tlsProfiles.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "TLS profile not found")
+	}
+	if err := checkIfMatch(c, profile.Version); err != nil {
+		return respondStale(c, profile.Version, err)
+	}
+	if profile.Builtin {
+		return respondError(c, 409, "Conflict", "Built-in TLS profiles cannot be deleted")
+	}
+	for _, host := range store.proxyHosts.all() {
+		if host.TLSProfileID != nil && *host.TLSProfileID == id {
+			return respondError(c, 409, "Conflict", fmt.Sprintf("TLS profile is used by proxy host %d", host.ID))
+		}
+	}
+	store.tlsProfiles.remove(id)
+
+	return c.JSON(fiber.Map{
+		"message": "TLS profile deleted successfully",
+		"id":      id,
+	})
+}
+
+// ListDeprecatedTLSHosts godoc
+// @Summary      List hosts with deprecated TLS
+// @Description  Report SSL-enabled proxy hosts whose TLS profile still enables TLSv1 or TLSv1.1
+// @Tags         tls-profiles
+// @Produce      json
+// @Success      200 {array} DeprecatedTLSHost
+// @Router       /tls-profiles/deprecated-hosts [get]
+func ListDeprecatedTLSHosts(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	report := []DeprecatedTLSHost{}
+	for _, host := range store.proxyHosts.all() {
+		if !host.SSLEnabled {
+			continue
+		}
+		profile, ok := tlsProfileFor(host.TLSProfileID)
+		if !ok {
+			continue
+		}
+		if deprecated := deprecatedProtocols(profile); len(deprecated) > 0 {
+			report = append(report, DeprecatedTLSHost{
+				ProxyHostID:         host.ID,
+				DomainNames:         host.DomainNames,
+				TLSProfileID:        profile.ID,
+				TLSProfileName:      profile.Name,
+				DeprecatedProtocols: deprecated,
+			})
+		}
+	}
+	return c.JSON(report)
+}
+
+// tlsProfileByName looks up a TLS profile by name.
+// Callers must hold store.mu.
+func tlsProfileByName(name string) (TLSProfile, bool) {
+	for _, profile := range store.tlsProfiles.all() {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return TLSProfile{}, false
+}
+
+func tlsProfileFromRequest(req TLSProfileRequest) TLSProfile {
+	return TLSProfile{
+		Name:                req.Name,
+		Description:         req.Description,
+		Protocols:           req.Protocols,
+		Ciphers:             req.Ciphers,
+		PreferServerCiphers: req.PreferServerCiphers,
+		SessionCacheSize:    req.SessionCacheSize,
+		SessionTimeout:      req.SessionTimeout,
+		SessionTickets:      req.SessionTickets,
+		OCSPStapling:        req.OCSPStapling,
+		HTTP2:               req.HTTP2,
+		HTTP3:               req.HTTP3,
+	}
+}
+
+// tlsProfileRequest is the inverse of tlsProfileFromRequest
+func tlsProfileRequest(profile TLSProfile) TLSProfileRequest {
+	return TLSProfileRequest{
+		Name:                profile.Name,
+		Description:         profile.Description,
+		Protocols:           profile.Protocols,
+		Ciphers:             profile.Ciphers,
+		PreferServerCiphers: profile.PreferServerCiphers,
+		SessionCacheSize:    profile.SessionCacheSize,
+		SessionTimeout:      profile.SessionTimeout,
+		SessionTickets:      profile.SessionTickets,
+		OCSPStapling:        profile.OCSPStapling,
+		HTTP2:               profile.HTTP2,
+		HTTP3:               profile.HTTP3,
+	}
+}
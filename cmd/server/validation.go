@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FieldError is a problem with one field of a request body. Message is a
+// full sentence that names the field.
+type FieldError struct {
+	Field   string `json:"field" example:"domain_names[0]"`
+	Message string `json:"message" example:"domain_names[0] is not a valid domain name"`
+}
+
+// fieldErrors collects the problems of a request body so they can be
+// reported together
+type fieldErrors []FieldError
+
+func (e fieldErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, field := range e {
+		messages[i] = field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// add records a problem with field. The message should start with the field.
+func (e *fieldErrors) add(field, format string, args ...any) {
+	*e = append(*e, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// check records err against field when it is not nil. Messages that do not
+// name the field are prefixed with it.
+func (e *fieldErrors) check(field string, err error) {
+	if err == nil {
+		return
+	}
+	var nested fieldErrors
+	if errors.As(err, &nested) {
+		*e = append(*e, nested...)
+		return
+	}
+	message := err.Error()
+	if !strings.HasPrefix(message, field) {
+		message = field + ": " + message
+	}
+	e.add(field, "%s", message)
+}
+
+// nest records err like check, placing the fields of a nested object or
+// list under prefix. Nested fields are relative: "zone_size", "[0].path", or
+// empty for a problem with the object as a whole.
+func (e *fieldErrors) nest(prefix string, err error) {
+	var nested fieldErrors
+	if !errors.As(err, &nested) {
+		e.check(prefix, err)
+		return
+	}
+	for _, field := range nested {
+		path := prefix
+		switch {
+		case field.Field == "":
+		case strings.HasPrefix(field.Field, "["):
+			path += field.Field
+		default:
+			path += "." + field.Field
+		}
+
+		message := path + " " + field.Message
+		if rest, ok := strings.CutPrefix(field.Message, field.Field); ok && field.Field != "" {
+			message = path + rest
+		}
+		e.add(path, "%s", message)
+	}
+}
+
+// err returns nil when nothing was recorded
+func (e fieldErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// respondInvalid sends a 400 for a request that failed to parse or validate,
+// listing the invalid fields when there are any
+func respondInvalid(c *fiber.Ctx, err error) error {
+	var fields fieldErrors
+	if errors.As(err, &fields) {
+		return c.Status(400).JSON(ErrorResponse{
+			Error:   "Invalid request",
+			Message: fields.Error(),
+			Fields:  fields,
+		})
+	}
+	return respondError(c, 400, "Invalid request", err.Error())
+}
+
+// parseBody parses a JSON request body into req and checks its binding tags.
+// Domain names are normalized in place.
+func parseBody(c *fiber.Ctx, req any) error {
+	if err := c.BodyParser(req); err != nil {
+		return err
+	}
+	var errs fieldErrors
+	checkBindings(reflect.ValueOf(req).Elem(), "", &errs)
+	return errs.err()
+}
+
+// checkBindings walks a struct and applies the comma separated rules of its
+// binding tags:
+//
+//	required  the field must not be zero or empty
+//	domain    a server name, or every name of a list, see normalizeDomain
+//	host      an IP address or a hostname
+//	port      a port between 1 and 65535, 0 is allowed unless required
+func checkBindings(value reflect.Value, path string, errs *fieldErrors) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			checkBindings(value.Elem(), path, errs)
+		}
+		return
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			checkBindings(value.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if path != "" {
+			name = path + "." + name
+		}
+
+		fieldValue := value.Field(i)
+		rules := strings.Split(field.Tag.Get("binding"), ",")
+		empty := fieldValue.IsZero() || fieldValue.Kind() == reflect.Slice && fieldValue.Len() == 0
+		if empty && slices.Contains(rules, "required") {
+			errs.add(name, "%s is required", name)
+			continue
+		}
+		for _, rule := range rules {
+			checkRule(rule, fieldValue, name, errs)
+		}
+		checkBindings(fieldValue, name, errs)
+	}
+}
+
+// checkRule applies one binding rule other than required
+func checkRule(rule string, value reflect.Value, name string, errs *fieldErrors) {
+	switch rule {
+	case "domain":
+		if value.Kind() == reflect.Slice {
+			for i := 0; i < value.Len(); i++ {
+				checkDomain(value.Index(i), fmt.Sprintf("%s[%d]", name, i), errs)
+			}
+			return
+		}
+		checkDomain(value, name, errs)
+	case "host":
+		host := value.String()
+		if host == "" || net.ParseIP(host) != nil {
+			return
+		}
+		normalized, err := normalizeHostname(host)
+		if err != nil {
+			errs.add(name, "%s must be an IP address or hostname: %v", name, err)
+			return
+		}
+		value.SetString(normalized)
+	case "port":
+		if port := value.Int(); port != 0 && (port < 1 || port > 65535) {
+			errs.add(name, "%s must be between 1 and 65535", name)
+		}
+	}
+}
+
+func checkDomain(value reflect.Value, name string, errs *fieldErrors) {
+	normalized, err := normalizeDomain(value.String())
+	if err != nil {
+		errs.add(name, "%s is not a valid domain name: %v", name, err)
+		return
+	}
+	value.SetString(normalized)
+}
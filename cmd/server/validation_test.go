@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidateProxyHostFields(t *testing.T) {
+	req := ProxyHostRequest{
+		Locations: []Location{
+			{Path: "/api"},
+			{Path: "api", RateLimit: &RateLimit{Rate: 10, ZoneSize: "lots"}, HeaderRules: []HeaderRule{{Direction: "both", Action: headerSet, Name: "X-A", Value: "1"}}},
+		},
+		RateLimit:  &RateLimit{Rate: -1, ConnectionLimit: 5, StatusCode: 200},
+		ClientAuth: &ClientAuth{Verify: clientVerifyOptionalNoCA},
+	}
+
+	store.mu.Lock()
+	err := validateProxyHost(&req)
+	store.mu.Unlock()
+
+	var fields fieldErrors
+	if !errors.As(err, &fields) {
+		t.Fatalf("validateProxyHost = %v, want field errors", err)
+	}
+	want := fieldErrors{
+		{"locations[1].path", "locations[1].path must start with '/'"},
+		{"locations[1].rate_limit.zone_size", "locations[1].rate_limit.zone_size must be an nginx size such as 1m"},
+		{"locations[1].header_rules[0].direction", `locations[1].header_rules[0].direction must be "request" or "response"`},
+		{"rate_limit.rate", "rate_limit.rate must not be negative"},
+		{"rate_limit.status_code", "rate_limit.status_code must be between 400 and 599"},
+		{"client_auth", "client_auth requires ssl_enabled"},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields =\n%v\nwant\n%v", fields, want)
+	}
+}
+
+func TestValidateUpstreamServer(t *testing.T) {
+	req := UpstreamServerRequest{Host: "10.0.0.1", Port: 80}
+	if err := validateUpstreamServer(&req); err != nil || req.Weight != 1 {
+		t.Errorf("default weight: err %v, weight %d", err, req.Weight)
+	}
+
+	req = UpstreamServerRequest{Host: "10.0.0.1", Port: 80, Weight: -2, MaxFails: -1}
+	var fields fieldErrors
+	if err := validateUpstreamServer(&req); !errors.As(err, &fields) || len(fields) != 2 ||
+		fields[0].Field != "weight" || fields[1].Field != "max_fails" {
+		t.Errorf("validateUpstreamServer = %v, want weight and max_fails errors", err)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Revision is embedded in resources that support conditional writes.
+// Version starts at 1 and is bumped by every table.put.
+type Revision struct {
+	Version int `json:"version" example:"3"`
+}
+
+func (r *Revision) revision() *Revision {
+	return r
+}
+
+// versioned is implemented by pointers to resources embedding Revision
+type versioned interface {
+	revision() *Revision
+}
+
+// versionOf returns the version of a row, 0 for rows without Revision
+func versionOf[T any](row T) int {
+	if r, ok := any(&row).(versioned); ok {
+		return r.revision().Version
+	}
+	return 0
+}
+
+// etag returns the entity tag of a version
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// setETag sets the ETag header of a response to the version of a resource
+func setETag(c *fiber.Ctx, version int) {
+	c.Set(fiber.HeaderETag, etag(version))
+}
+
+// checkIfMatch checks the If-Match header of a write against the current
+// version of a resource. Writes without If-Match are unconditional.
+func checkIfMatch(c *fiber.Ctx, version int) error {
+	header := c.Get(fiber.HeaderIfMatch)
+	if header == "" {
+		return nil
+	}
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+			return nil
+		}
+	}
+	return fmt.Errorf("If-Match %s does not match the current version %s", header, current)
+}
+
+// respondStale sends a 412 for a write based on an outdated version
+func respondStale(c *fiber.Ctx, version int, err error) error {
+	setETag(c, version)
+	return respondError(c, 412, "Precondition failed", err.Error())
+}
+
+// patchResource serves a PATCH through the PUT handler of a resource. The
+// body is merged onto the stored resource as a JSON merge patch (RFC 7396)
+// and the PUT is made conditional on the version the merge started from, so
+// a write in between is reported with a 412 instead of being reverted.
+func patchResource[T, R any](c *fiber.Ctx, rows *table[T], notFound string, request func(T) R, update fiber.Handler) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	row, ok := rows.get(id)
+	store.mu.RUnlock()
+	if !ok {
+		return respondError(c, 404, "Not found", notFound)
+	}
+
+	version := versionOf(row)
+	if err := checkIfMatch(c, version); err != nil {
+		return respondStale(c, version, err)
+	}
+	body, err := mergePatch(request(row), c.Body())
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	c.Request().SetBody(body)
+	c.Request().Header.SetContentType(fiber.MIMEApplicationJSON)
+	c.Request().Header.Set(fiber.HeaderIfMatch, etag(version))
+	return update(c)
+}
+
+// mergePatch applies a JSON merge patch to the JSON encoding of target
+func mergePatch(target any, patch []byte) ([]byte, error) {
+	current, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+	var document any
+	if err := json.Unmarshal(current, &document); err != nil {
+		return nil, err
+	}
+
+	var changes any
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, fmt.Errorf("body must be a JSON merge patch: %v", err)
+	}
+	if _, ok := changes.(map[string]any); !ok {
+		return nil, fmt.Errorf("body must be a JSON object")
+	}
+	return json.Marshal(mergeValue(document, changes))
+}
+
+// mergeValue merges patch into target. Objects are merged key by key, null
+// removes a key and any other value replaces the target.
+func mergeValue(target, patch any) any {
+	changes, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	object, ok := target.(map[string]any)
+	if !ok {
+		object = map[string]any{}
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(object, key)
+		} else {
+			object[key] = mergeValue(object[key], value)
+		}
+	}
+	return object
+}
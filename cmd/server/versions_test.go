@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	target := map[string]any{
+		"name":      "backend",
+		"algorithm": "least_conn",
+		"affinity":  map[string]any{"mode": "cookie", "cookie": map[string]any{"name": "bs_backend", "path": "/"}},
+		"tags":      []any{"a", "b"},
+	}
+
+	tests := []struct {
+		name, patch, want, err string
+	}{
+		{"replace a field", `{"algorithm":"ip_hash"}`,
+			`{"affinity":{"cookie":{"name":"bs_backend","path":"/"},"mode":"cookie"},"algorithm":"ip_hash","name":"backend","tags":["a","b"]}`, ""},
+		{"null removes a field", `{"affinity":null}`,
+			`{"algorithm":"least_conn","name":"backend","tags":["a","b"]}`, ""},
+		{"objects merge key by key", `{"affinity":{"cookie":{"path":null,"secure":true}}}`,
+			`{"affinity":{"cookie":{"name":"bs_backend","secure":true},"mode":"cookie"},"algorithm":"least_conn","name":"backend","tags":["a","b"]}`, ""},
+		{"arrays are replaced", `{"tags":["c"]}`,
+			`{"affinity":{"cookie":{"name":"bs_backend","path":"/"},"mode":"cookie"},"algorithm":"least_conn","name":"backend","tags":["c"]}`, ""},
+		{"empty patch", `{}`,
+			`{"affinity":{"cookie":{"name":"bs_backend","path":"/"},"mode":"cookie"},"algorithm":"least_conn","name":"backend","tags":["a","b"]}`, ""},
+		{"array body", `[]`, "", "body must be a JSON object"},
+		{"invalid JSON", `{"name":`, "", "body must be a JSON merge patch"},
+	}
+	for _, tt := range tests {
+		got, err := mergePatch(target, []byte(tt.patch))
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: got %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+
+	// The target itself is left alone
+	if _, ok := target["affinity"]; !ok {
+		t.Error("mergePatch modified its target")
+	}
+}
+
+func TestPatchUpstreamIfMatch(t *testing.T) {
+	store.mu.Lock()
+	id := store.upstreams.newID()
+	upstream := store.upstreams.put(id, Upstream{ID: id, Name: "patch_test", Algorithm: "round_robin", Description: "before"})
+	store.mu.Unlock()
+	defer func() {
+		store.mu.Lock()
+		store.upstreams.remove(id)
+		store.mu.Unlock()
+	}()
+
+	app := newApp()
+	patch := func(ifMatch, body string) (int, string, Upstream) {
+		req := httptest.NewRequest("PATCH", basePath+"/upstreams/"+strconv.Itoa(id), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		var result Upstream
+		json.Unmarshal(data, &result)
+		return resp.StatusCode, resp.Header.Get("ETag"), result
+	}
+
+	status, tag, result := patch(etag(upstream.Version), `{"algorithm":"least_conn"}`)
+	if status != 200 || result.Algorithm != "least_conn" || result.Description != "before" || result.Name != "patch_test" {
+		t.Fatalf("patch = %d %+v, want 200 with only the algorithm changed", status, result)
+	}
+	if result.Version != upstream.Version+1 || tag != etag(result.Version) {
+		t.Errorf("version %d, ETag %s after patching version %d", result.Version, tag, upstream.Version)
+	}
+
+	// The first patch moved the version on, so the old ETag is stale
+	status, tag, _ = patch(etag(upstream.Version), `{"description":"lost update"}`)
+	if status != 412 || tag != etag(upstream.Version+1) {
+		t.Errorf("stale patch = %d with ETag %s, want 412 with %s", status, tag, etag(upstream.Version+1))
+	}
+	store.mu.RLock()
+	current, _ := store.upstreams.get(id)
+	store.mu.RUnlock()
+	if current.Description != "before" {
+		t.Errorf("stale patch was applied: %+v", current)
+	}
+
+	if status, _, _ := patch("", `{"description":"unconditional"}`); status != 200 {
+		t.Errorf("patch without If-Match = %d, want 200", status)
+	}
+	if status, _, _ := patch(`"1", *`, `{"description":"any version"}`); status != 200 {
+		t.Errorf("patch with If-Match * = %d, want 200", status)
+	}
+	if status, _, _ := patch("", `["not an object"]`); status != 400 {
+		t.Errorf("patch with an array body = %d, want 400", status)
+	}
+}
+
+func TestCancelScheduleIfMatch(t *testing.T) {
+	store.mu.Lock()
+	id := store.schedules.newID()
+	change := store.schedules.put(id, ScheduledChange{ID: id, Action: scheduleEnableProxyHost, Status: schedulePending})
+	store.mu.Unlock()
+	defer func() {
+		store.mu.Lock()
+		store.schedules.remove(id)
+		store.mu.Unlock()
+	}()
+
+	app := newApp()
+	cancel := func(ifMatch string) (int, string) {
+		req := httptest.NewRequest("DELETE", basePath+"/schedules/"+strconv.Itoa(id), nil)
+		req.Header.Set("If-Match", ifMatch)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode, resp.Header.Get("ETag")
+	}
+
+	if status, tag := cancel(etag(change.Version + 1)); status != 412 || tag != etag(change.Version) {
+		t.Errorf("stale cancel = %d with ETag %s, want 412 with %s", status, tag, etag(change.Version))
+	}
+	if status, tag := cancel(etag(change.Version)); status != 200 || tag != etag(change.Version+1) {
+		t.Errorf("cancel = %d with ETag %s, want 200 with %s", status, tag, etag(change.Version+1))
+	}
+}
+
+func TestRestoreKeepsVersion(t *testing.T) {
+	rows := newTable[Upstream]()
+	before := rows.put(1, Upstream{ID: 1, Name: "before"})
+	rows.put(1, Upstream{ID: 1, Name: "failed change"})
+	rows.restore(1, before)
+
+	if got, _ := rows.get(1); got.Name != "before" || got.Version != before.Version {
+		t.Errorf("restored row = %+v, want %+v", got, before)
+	}
+	if next := rows.put(1, before); next.Version != before.Version+1 {
+		t.Errorf("version after restore and put = %d, want %d", next.Version, before.Version+1)
+	}
+}
@@ -0,0 +1,673 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Webhook delivery statuses
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+const (
+	webhookInterval = 2 * time.Second
+	webhookTimeout  = 10 * time.Second
+	// webhookMaxAttempts and webhookBackoff retry a delivery after 30s, 1m,
+	// 2m, 4m and 8m before giving up
+	webhookMaxAttempts = 6
+	webhookBackoff     = 30 * time.Second
+	// webhookDeliveryRetention is how many deliveries are kept per webhook
+	webhookDeliveryRetention = 100
+	// webhookResponseLimit caps the response body kept for an attempt
+	webhookResponseLimit = 1024
+	// webhookConcurrency is how many deliveries of one webhook are sent at
+	// once, so a slow endpoint neither holds up other webhooks nor is flooded
+	webhookConcurrency = 4
+)
+
+// webhookWorkers tracks the deliveries being sent
+var webhookWorkers = struct {
+	mu       sync.Mutex
+	running  map[int]int  // deliveries in flight per webhook
+	inFlight map[int]bool // delivery IDs in flight
+}{running: map[int]int{}, inFlight: map[int]bool{}}
+
+// webhookClient sends deliveries. Redirects are not followed so that a
+// delivery only ever reaches the registered URL.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// Webhook receives signed JSON payloads for events
+type Webhook struct {
+	ID int `json:"id" example:"1"`
+	Revision
+	Name   string   `json:"name" example:"ops"`
+	URL    string   `json:"url" example:"https://hooks.example.com/balancer"`
+	Events []string `json:"events" example:"config.failed,upstream_server.down"`
+	// CertificateExpiryDays only delivers certificate.expiring events once
+	// the certificate expires within this many days
+	CertificateExpiryDays int  `json:"certificate_expiry_days,omitempty" example:"14"`
+	Enabled               bool `json:"enabled" example:"true"`
+	// Secret signs payloads and is only returned when the webhook is created
+	Secret    string `json:"-"`
+	CreatedAt string `json:"created_at" example:"2025-12-08T10:00:00Z"`
+}
+
+// WebhookRequest creates or updates a webhook. An empty secret generates one
+// on create and keeps the current one on update.
+type WebhookRequest struct {
+	Name                  string   `json:"name" binding:"required" example:"ops"`
+	URL                   string   `json:"url" example:"https://hooks.example.com/balancer"`
+	Events                []string `json:"events" binding:"required" example:"config.failed,upstream_server.down"`
+	CertificateExpiryDays int      `json:"certificate_expiry_days,omitempty" example:"14"`
+	Enabled               *bool    `json:"enabled,omitempty" example:"true"`
+	Secret                string   `json:"secret,omitempty" example:"s3cret"`
+}
+
+// CreatedWebhook is returned once on create, with the generated secret
+type CreatedWebhook struct {
+	Webhook
+	Secret string `json:"secret" example:"4f9c2a..."`
+}
+
+// WebhookDelivery is one event sent to one webhook
+type WebhookDelivery struct {
+	ID            int              `json:"id" example:"1"`
+	WebhookID     int              `json:"webhook_id" example:"1"`
+	EventID       int64            `json:"event_id" example:"42"`
+	EventType     string           `json:"event_type" example:"config.failed"`
+	Payload       string           `json:"payload"`
+	Status        string           `json:"status" example:"delivered"`
+	Attempts      []WebhookAttempt `json:"attempts"`
+	NextAttemptAt string           `json:"next_attempt_at,omitempty" example:"2025-12-08T10:00:30Z"`
+	// RedeliveryOf is the delivery this one repeats
+	RedeliveryOf *int   `json:"redelivery_of,omitempty" example:"1"`
+	CreatedAt    string `json:"created_at" example:"2025-12-08T10:00:00Z"`
+}
+
+// WebhookAttempt is one HTTP request of a delivery
+type WebhookAttempt struct {
+	At           string `json:"at" example:"2025-12-08T10:00:00Z"`
+	ResponseCode int    `json:"response_code,omitempty" example:"200"`
+	Response     string `json:"response,omitempty"`
+	Error        string `json:"error,omitempty"`
+	DurationMs   int64  `json:"duration_ms" example:"120"`
+}
+
+// validateWebhook normalizes and checks a webhook request
+func validateWebhook(req *WebhookRequest) error {
+	var errs fieldErrors
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		errs.add("url", "url must be an absolute http or https URL")
+	}
+	for i, eventType := range req.Events {
+		if eventType != "*" && !slices.Contains(eventTypes, eventType) {
+			errs.add(fmt.Sprintf("events[%d]", i), "events[%d]: unknown event %q", i, eventType)
+		}
+	}
+	if req.CertificateExpiryDays < 0 || req.CertificateExpiryDays > certificateExpiryDays[0] {
+		errs.add("certificate_expiry_days", "certificate_expiry_days must be at most %d", certificateExpiryDays[0])
+	}
+	return errs.err()
+}
+
+// wants reports whether a webhook subscribed to an event
+func (w Webhook) wants(event Event) bool {
+	if !w.Enabled || (!slices.Contains(w.Events, "*") && !slices.Contains(w.Events, event.Type)) {
+		return false
+	}
+	if event.Type == "certificate.expiring" && w.CertificateExpiryDays > 0 {
+		data, _ := event.Data.(map[string]any)
+		daysLeft, _ := data["days_left"].(int)
+		return daysLeft <= w.CertificateExpiryDays
+	}
+	return true
+}
+
+// generateWebhookSecret returns a random signing secret
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// signWebhook signs a payload with the webhook secret. Receivers recompute
+// HMAC-SHA256 over "<timestamp>.<body>" and compare.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// startWebhooks queues a delivery for every event a webhook subscribed to
+// and sends due deliveries in the background
+func startWebhooks() {
+	go func() {
+		topics := map[string]bool{}
+		for _, topic := range eventTopics {
+			topics[topic] = topic != topicStatus
+		}
+		sub, _ := events.subscribe(topics, 0)
+		var lastID int64
+
+		for {
+			event, ok := <-sub.ch
+			if !ok {
+				// Dropped for falling behind, pick up from the history
+				var missed []Event
+				sub, missed = events.subscribe(topics, lastID)
+				for _, event := range missed {
+					queueDeliveries(event)
+					lastID = event.ID
+				}
+				continue
+			}
+			queueDeliveries(event)
+			lastID = event.ID
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(webhookInterval)
+		defer ticker.Stop()
+		for current := range ticker.C {
+			deliverDue(current)
+		}
+	}()
+}
+
+// queueDeliveries creates a pending delivery for every webhook that wants
+// an event
+func queueDeliveries(event Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	for _, webhook := range store.webhooks.all() {
+		if !webhook.wants(event) {
+			continue
+		}
+		delivery := WebhookDelivery{
+			ID:            store.webhookDeliveries.newID(),
+			WebhookID:     webhook.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       string(payload),
+			Status:        deliveryPending,
+			Attempts:      []WebhookAttempt{},
+			NextAttemptAt: now(),
+			CreatedAt:     now(),
+		}
+		store.webhookDeliveries.put(delivery.ID, delivery)
+		pruneDeliveries(webhook.ID)
+	}
+}
+
+// pruneDeliveries drops the oldest finished deliveries of a webhook beyond
+// the retention. Callers must hold store.mu.
+func pruneDeliveries(webhookID int) {
+	deliveries := deliveriesOf(webhookID)
+	excess := len(deliveries) - webhookDeliveryRetention
+	for _, delivery := range deliveries {
+		if excess <= 0 {
+			return
+		}
+		if delivery.Status != deliveryPending {
+			store.webhookDeliveries.remove(delivery.ID)
+			excess--
+		}
+	}
+}
+
+// deliveriesOf returns the deliveries of a webhook, oldest first.
+// Callers must hold store.mu.
+func deliveriesOf(webhookID int) []WebhookDelivery {
+	var deliveries []WebhookDelivery
+	for _, delivery := range store.webhookDeliveries.all() {
+		if delivery.WebhookID == webhookID {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries
+}
+
+// deliverDue starts sending every pending delivery whose attempt is due.
+// Each request runs in its own goroutine without holding store.mu. A webhook
+// that already has webhookConcurrency requests in flight gets the rest on a
+// later tick.
+func deliverDue(current time.Time) {
+	type job struct {
+		delivery WebhookDelivery
+		webhook  Webhook
+	}
+
+	store.mu.RLock()
+	var jobs []job
+	for _, delivery := range store.webhookDeliveries.all() {
+		if delivery.Status != deliveryPending {
+			continue
+		}
+		if at, err := time.Parse(time.RFC3339, delivery.NextAttemptAt); err == nil && at.After(current) {
+			continue
+		}
+		if webhook, ok := store.webhooks.get(delivery.WebhookID); ok {
+			jobs = append(jobs, job{delivery, webhook})
+		}
+	}
+	store.mu.RUnlock()
+
+	for _, job := range jobs {
+		if !startDelivery(job.webhook.ID, job.delivery.ID) {
+			continue
+		}
+		go func() {
+			defer finishDelivery(job.webhook.ID, job.delivery.ID)
+			recordAttempt(job.delivery.ID, sendDelivery(job.webhook, job.delivery), time.Now())
+		}()
+	}
+}
+
+// startDelivery claims a worker slot for a delivery. It fails when the
+// delivery is already being sent or its webhook has no free slot.
+func startDelivery(webhookID, deliveryID int) bool {
+	webhookWorkers.mu.Lock()
+	defer webhookWorkers.mu.Unlock()
+
+	if webhookWorkers.inFlight[deliveryID] || webhookWorkers.running[webhookID] >= webhookConcurrency {
+		return false
+	}
+	webhookWorkers.inFlight[deliveryID] = true
+	webhookWorkers.running[webhookID]++
+	return true
+}
+
+// finishDelivery releases the slot claimed by startDelivery
+func finishDelivery(webhookID, deliveryID int) {
+	webhookWorkers.mu.Lock()
+	defer webhookWorkers.mu.Unlock()
+
+	delete(webhookWorkers.inFlight, deliveryID)
+	if webhookWorkers.running[webhookID]--; webhookWorkers.running[webhookID] <= 0 {
+		delete(webhookWorkers.running, webhookID)
+	}
+}
+
+// recordAttempt stores the result of an attempt and schedules the retry
+func recordAttempt(deliveryID int, attempt WebhookAttempt, current time.Time) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delivery, ok := store.webhookDeliveries.get(deliveryID)
+	if !ok {
+		return
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	switch {
+	case attempt.ResponseCode >= 200 && attempt.ResponseCode < 300:
+		delivery.Status = deliveryDelivered
+		delivery.NextAttemptAt = ""
+	case len(delivery.Attempts) >= webhookMaxAttempts:
+		delivery.Status = deliveryFailed
+		delivery.NextAttemptAt = ""
+	default:
+		backoff := webhookBackoff << (len(delivery.Attempts) - 1)
+		delivery.NextAttemptAt = current.Add(backoff).UTC().Format(time.RFC3339)
+	}
+	store.webhookDeliveries.put(delivery.ID, delivery)
+}
+
+// sendDelivery makes one signed request for a delivery
+func sendDelivery(webhook Webhook, delivery WebhookDelivery) WebhookAttempt {
+	started := time.Now()
+	attempt := WebhookAttempt{At: now()}
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(started.Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Balancer-Studio-Webhook/1.0")
+	req.Header.Set("X-Balancer-Event", delivery.EventType)
+	req.Header.Set("X-Balancer-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-Balancer-Timestamp", timestamp)
+	req.Header.Set("X-Balancer-Signature", signWebhook(webhook.Secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	attempt.DurationMs = time.Since(started).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	attempt.ResponseCode = resp.StatusCode
+	attempt.Response = string(response)
+	return attempt
+}
+
+// ListWebhooks godoc
+// @Summary      List webhooks
+// @Description  Get all registered webhooks. Secrets are not returned.
+// @Tags         webhooks
+// @Produce      json
+// @Success      200 {array} Webhook
+// @Router       /webhooks [get]
+func ListWebhooks(c *fiber.Ctx) error {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	return c.JSON(store.webhooks.all())
+}
+
+// CreateWebhook godoc
+// @Summary      Create a webhook
+// @Description  Register an endpoint for events. Payloads are signed with HMAC-SHA256 in the X-Balancer-Signature header. The secret is only returned in this response.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        webhook body WebhookRequest true "Webhook"
+// @Success      201 {object} CreatedWebhook
+// @Header       201 {string} ETag "Version of the webhook"
+// @Failure      400 {object} ErrorResponse
+// @Router       /webhooks [post]
+func CreateWebhook(c *fiber.Ctx) error {
+	var req WebhookRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateWebhook(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+	secret := req.Secret
+	if secret == "" {
+		generated, err := generateWebhookSecret()
+		if err != nil {
+			return respondError(c, 500, "Internal error", err.Error())
+		}
+		secret = generated
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	webhook := Webhook{
+		ID:                    store.webhooks.newID(),
+		Name:                  req.Name,
+		URL:                   req.URL,
+		Events:                req.Events,
+		CertificateExpiryDays: req.CertificateExpiryDays,
+		Enabled:               req.Enabled == nil || *req.Enabled,
+		Secret:                secret,
+		CreatedAt:             now(),
+	}
+	webhook = store.webhooks.put(webhook.ID, webhook)
+
+	setETag(c, webhook.Version)
+	return c.Status(201).JSON(CreatedWebhook{Webhook: webhook, Secret: secret})
+}
+
+// GetWebhook godoc
+// @Summary      Get a webhook
+// @Description  Get a specific webhook by ID
+// @Tags         webhooks
+// @Produce      json
+// @Param        id path int true "Webhook ID"
+// @Success      200 {object} Webhook
+// @Header       200 {string} ETag "Version of the webhook"
+// @Failure      404 {object} ErrorResponse
+// @Router       /webhooks/{id} [get]
+func GetWebhook(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	webhook, ok := store.webhooks.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Webhook not found")
+	}
+	setETag(c, webhook.Version)
+	return c.JSON(webhook)
+}
+
+// UpdateWebhook godoc
+// @Summary      Update a webhook
+// @Description  Update a webhook. An empty secret keeps the current one.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Webhook ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        webhook body WebhookRequest true "Updated Webhook"
+// @Success      200 {object} Webhook
+// @Header       200 {string} ETag "Version of the webhook"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /webhooks/{id} [put]
+func UpdateWebhook(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	var req WebhookRequest
+	if err := parseBody(c, &req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	if err := validateWebhook(&req); err != nil {
+		return respondInvalid(c, err)
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	webhook, ok := store.webhooks.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Webhook not found")
+	}
+	if err := checkIfMatch(c, webhook.Version); err != nil {
+		return respondStale(c, webhook.Version, err)
+	}
+
+	webhook.Name = req.Name
+	webhook.URL = req.URL
+	webhook.Events = req.Events
+	webhook.CertificateExpiryDays = req.CertificateExpiryDays
+	if req.Enabled != nil {
+		webhook.Enabled = *req.Enabled
+	}
+	if req.Secret != "" {
+		webhook.Secret = req.Secret
+	}
+	webhook = store.webhooks.put(webhook.ID, webhook)
+
+	setETag(c, webhook.Version)
+	return c.JSON(webhook)
+}
+
+// PatchWebhook godoc
+// @Summary      Partially update a webhook
+// @Description  Change some fields of a webhook with a JSON merge patch (RFC 7396). The secret is kept unless one is sent.
+// @Tags         webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Webhook ID"
+// @Param        If-Match header string false "ETag of the version being updated"
+// @Param        webhook body WebhookRequest true "Fields to change"
+// @Success      200 {object} Webhook
+// @Header       200 {string} ETag "Version of the webhook"
+// @Failure      400 {object} ErrorResponse
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /webhooks/{id} [patch]
+func PatchWebhook(c *fiber.Ctx) error {
+	return patchResource(c, store.webhooks, "Webhook not found", webhookRequest, UpdateWebhook)
+}
+
+// webhookRequest returns the request that would set up a webhook as it is.
+// The secret is left out so that it is kept.
+func webhookRequest(webhook Webhook) WebhookRequest {
+	return WebhookRequest{
+		Name:                  webhook.Name,
+		URL:                   webhook.URL,
+		Events:                webhook.Events,
+		CertificateExpiryDays: webhook.CertificateExpiryDays,
+		Enabled:               &webhook.Enabled,
+	}
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete a webhook
+// @Description  Delete a webhook and its delivery log
+// @Tags         webhooks
+// @Produce      json
+// @Param        id path int true "Webhook ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
+// @Success      200 {object} map[string]interface{}
+// @Failure      404 {object} ErrorResponse
+// @Failure      412 {object} ErrorResponse
+// @Router       /webhooks/{id} [delete]
+func DeleteWebhook(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	webhook, ok := store.webhooks.get(id)
+	if !ok {
+		return respondError(c, 404, "Not found", "Webhook not found")
+	}
+	if err := checkIfMatch(c, webhook.Version); err != nil {
+		return respondStale(c, webhook.Version, err)
+	}
+	store.webhooks.remove(id)
+	for _, delivery := range deliveriesOf(id) {
+		store.webhookDeliveries.remove(delivery.ID)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Webhook deleted successfully",
+		"id":      id,
+	})
+}
+
+// ListWebhookDeliveries godoc
+// @Summary      List webhook deliveries
+// @Description  Get the recent deliveries of a webhook with every attempt and response code, newest first
+// @Tags         webhooks
+// @Produce      json
+// @Param        id path int true "Webhook ID"
+// @Param        status query string false "Status filter (pending, delivered, failed)"
+// @Success      200 {array} WebhookDelivery
+// @Failure      404 {object} ErrorResponse
+// @Router       /webhooks/{id}/deliveries [get]
+func ListWebhookDeliveries(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	status := c.Query("status")
+
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if _, ok := store.webhooks.get(id); !ok {
+		return respondError(c, 404, "Not found", "Webhook not found")
+	}
+
+	deliveries := []WebhookDelivery{}
+	for _, delivery := range slices.Backward(deliveriesOf(id)) {
+		if status == "" || delivery.Status == status {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return c.JSON(deliveries)
+}
+
+// RedeliverWebhook godoc
+// @Summary      Redeliver a webhook event
+// @Description  Send the payload of an earlier delivery again as a new delivery
+// @Tags         webhooks
+// @Produce      json
+// @Param        id path int true "Webhook ID"
+// @Param        deliveryId path int true "Delivery ID"
+// @Success      202 {object} WebhookDelivery
+// @Failure      404 {object} ErrorResponse
+// @Router       /webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func RedeliverWebhook(c *fiber.Ctx) error {
+	id, err := paramID(c, "id")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+	deliveryID, err := paramID(c, "deliveryId")
+	if err != nil {
+		return respondError(c, 400, "Invalid request", err.Error())
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	original, ok := store.webhookDeliveries.get(deliveryID)
+	if !ok || original.WebhookID != id {
+		return respondError(c, 404, "Not found", "Delivery not found")
+	}
+
+	delivery := WebhookDelivery{
+		ID:            store.webhookDeliveries.newID(),
+		WebhookID:     id,
+		EventID:       original.EventID,
+		EventType:     original.EventType,
+		Payload:       original.Payload,
+		Status:        deliveryPending,
+		Attempts:      []WebhookAttempt{},
+		NextAttemptAt: now(),
+		RedeliveryOf:  &original.ID,
+		CreatedAt:     now(),
+	}
+	store.webhookDeliveries.put(delivery.ID, delivery)
+	pruneDeliveries(id)
+
+	return c.Status(202).JSON(delivery)
+}
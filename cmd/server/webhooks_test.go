@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	// Expected value comes from openssl dgst -sha256 -hmac secret
+	got := signWebhook("secret", "ts", []byte(`{"a":1}`))
+	want := "sha256=447ad5ab5f596da7ef72bb9317e1d9f0accf18d43df4082c17eb04ff85ee1210"
+	if got != want {
+		t.Errorf("signWebhook = %s, want %s", got, want)
+	}
+}
+
+func TestRecordAttemptBackoff(t *testing.T) {
+	store.mu.Lock()
+	id := store.webhookDeliveries.newID()
+	store.webhookDeliveries.put(id, WebhookDelivery{ID: id, Status: deliveryPending, Attempts: []WebhookAttempt{}})
+	store.mu.Unlock()
+	defer func() {
+		store.mu.Lock()
+		store.webhookDeliveries.remove(id)
+		store.mu.Unlock()
+	}()
+
+	current := time.Date(2025, 12, 8, 10, 0, 0, 0, time.UTC)
+	delivery := func() WebhookDelivery {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		d, _ := store.webhookDeliveries.get(id)
+		return d
+	}
+
+	for i, wait := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute} {
+		recordAttempt(id, WebhookAttempt{ResponseCode: 500}, current)
+		d := delivery()
+		want := current.Add(wait).Format(time.RFC3339)
+		if d.Status != deliveryPending || d.NextAttemptAt != want {
+			t.Errorf("attempt %d: status %s, next attempt %s, want pending at %s", i+1, d.Status, d.NextAttemptAt, want)
+		}
+	}
+
+	recordAttempt(id, WebhookAttempt{Error: "timeout"}, current)
+	if d := delivery(); d.Status != deliveryFailed || d.NextAttemptAt != "" || len(d.Attempts) != webhookMaxAttempts {
+		t.Errorf("last attempt: status %s, next attempt %q, %d attempts", d.Status, d.NextAttemptAt, len(d.Attempts))
+	}
+}
+
+func TestRecordAttemptDelivered(t *testing.T) {
+	store.mu.Lock()
+	id := store.webhookDeliveries.newID()
+	store.webhookDeliveries.put(id, WebhookDelivery{ID: id, Status: deliveryPending, Attempts: []WebhookAttempt{}})
+	store.mu.Unlock()
+
+	recordAttempt(id, WebhookAttempt{ResponseCode: 204}, time.Now())
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	d, _ := store.webhookDeliveries.get(id)
+	store.webhookDeliveries.remove(id)
+	if d.Status != deliveryDelivered || d.NextAttemptAt != "" {
+		t.Errorf("status %s, next attempt %q, want delivered", d.Status, d.NextAttemptAt)
+	}
+}
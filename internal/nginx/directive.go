@@ -0,0 +1,85 @@
+package nginx
+
+import (
+	"strings"
+)
+
+// Directive is a single nginx configuration directive. Directives with a
+// non-nil Block open a context such as server, upstream or location.
+type Directive struct {
+	Name  string
+	Args  []string
+	Block []Directive
+	// Raw is written verbatim instead of Name and Args when set
+	Raw string
+}
+
+// Simple creates a directive terminated by a semicolon
+func Simple(name string, args ...string) Directive {
+	return Directive{Name: name, Args: args}
+}
+
+// NewBlock creates a directive that opens a context
+func NewBlock(name string, args []string, children ...Directive) Directive {
+	return Directive{Name: name, Args: args, Block: append([]Directive{}, children...)}
+}
+
+// Raw creates a directive that is written as-is, one line per input line
+func Raw(text string) Directive {
+	return Directive{Raw: text}
+}
+
+// Render returns the textual nginx configuration for the given directives
+func Render(directives []Directive) string {
+	var b strings.Builder
+	render(&b, directives, 0)
+	return b.String()
+}
+
+func render(b *strings.Builder, directives []Directive, depth int) {
+	indent := strings.Repeat("    ", depth)
+
+	for i, d := range directives {
+		if d.Raw != "" {
+			for _, line := range strings.Split(strings.TrimRight(d.Raw, "\n"), "\n") {
+				b.WriteString(indent)
+				b.WriteString(strings.TrimSpace(line))
+				b.WriteString("\n")
+			}
+			continue
+		}
+
+		// Separate top-level blocks with an empty line for readability
+		if d.Block != nil && depth == 0 && i > 0 {
+			b.WriteString("\n")
+		}
+
+		b.WriteString(indent)
+		b.WriteString(d.Name)
+		for _, arg := range d.Args {
+			b.WriteString(" ")
+			b.WriteString(Quote(arg))
+		}
+
+		if d.Block == nil {
+			b.WriteString(";\n")
+			continue
+		}
+
+		b.WriteString(" {\n")
+		render(b, d.Block, depth+1)
+		b.WriteString(indent)
+		b.WriteString("}\n")
+	}
+}
+
+// Quote wraps an argument in double quotes when nginx would otherwise
+// split or misinterpret it
+func Quote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\r\n;{}#\"'\\") {
+		return arg
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + replacer.Replace(arg) + `"`
+}
@@ -0,0 +1,254 @@
+package nginx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Config holds nginx paths used by Balancer Studio
+type Config struct {
+	BinPath     string
+	ConfPath    string
+	SitesPath   string
+	StreamsPath string
+	CertsPath   string
+	DataPath    string
+	StatusAddr  string
+}
+
+// File is a generated file written by Apply. A file marked Remove is
+// deleted instead, so output that is no longer generated does not stay on
+// disk.
+type File struct {
+	Path    string
+	Content string
+	Mode    os.FileMode
+	Remove  bool
+}
+
+// Controller runs the nginx binary and manages generated config files
+type Controller struct {
+	config Config
+}
+
+// NewController creates a controller for the given nginx installation
+func NewController(config Config) *Controller {
+	return &Controller{config: config}
+}
+
+// Config returns the nginx paths used by the controller
+func (c *Controller) Config() Config {
+	return c.config
+}
+
+// Path resolves a file name relative to the sites directory
+func (c *Controller) Path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(c.config.SitesPath, name)
+}
+
+// Test validates the configuration with nginx -t
+func (c *Controller) Test() (string, error) {
+	output, err := c.run("-t", "-c", c.config.ConfPath)
+	if err != nil {
+		return output, fmt.Errorf("nginx configuration test failed: %w", err)
+	}
+	return output, nil
+}
+
+// Reload reloads the configuration without dropping connections
+func (c *Controller) Reload() (string, error) {
+	output, err := c.run("-s", "reload")
+	if err != nil {
+		return output, fmt.Errorf("failed to reload nginx: %w", err)
+	}
+	return output, nil
+}
+
+// Purge removes everything inside a directory under the data path, such as
+// a proxy cache, and returns the number of files removed. The directory
+// itself is kept so nginx can keep writing to it.
+func (c *Controller) Purge(dir string) (int, error) {
+	rel, err := filepath.Rel(c.config.DataPath, dir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return 0, fmt.Errorf("refusing to purge %s outside of %s", dir, c.config.DataPath)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		_ = filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				removed++
+			}
+			return nil
+		})
+		if err := os.RemoveAll(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	return removed, nil
+}
+
+// EnsureDirs creates directories nginx writes to but does not create
+// itself, such as the directory of an access log
+func (c *Controller) EnsureDirs(dirs ...string) error {
+	for _, dir := range dirs {
+		if err := os.MkdirAll(c.Path(dir), 0o755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// Apply writes the files, validates the resulting configuration and reloads
+// nginx. When any step fails the previous files are restored, so a broken
+// render never stays on disk.
+func (c *Controller) Apply(files []File) (string, error) {
+	backups, err := c.write(files)
+	if err != nil {
+		c.restore(backups)
+		return "", err
+	}
+
+	output, err := c.Test()
+	if err != nil {
+		c.restore(backups)
+		return output, err
+	}
+
+	reloadOutput, err := c.Reload()
+	output = strings.TrimSpace(output + "\n" + reloadOutput)
+	if err != nil {
+		c.restore(backups)
+		return output, err
+	}
+
+	return output, nil
+}
+
+// backup remembers the content a file had before Apply overwrote it
+type backup struct {
+	path    string
+	content []byte
+	mode    os.FileMode
+	existed bool
+}
+
+func (c *Controller) write(files []File) ([]backup, error) {
+	backups := make([]backup, 0, len(files))
+
+	for _, file := range files {
+		path := c.Path(file.Path)
+		mode := file.Mode
+		if mode == 0 {
+			mode = 0o644
+		}
+
+		prev := backup{path: path, mode: mode}
+		if info, err := os.Stat(path); err == nil {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return backups, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			prev.content = content
+			prev.mode = info.Mode().Perm()
+			prev.existed = true
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return backups, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		backups = append(backups, prev)
+
+		if file.Remove {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return backups, fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return backups, fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := writeAtomic(path, []byte(file.Content), mode); err != nil {
+			return backups, err
+		}
+	}
+
+	return backups, nil
+}
+
+func (c *Controller) restore(backups []backup) {
+	for _, b := range backups {
+		if b.existed {
+			_ = writeAtomic(b.path, b.content, b.mode)
+		} else {
+			_ = os.Remove(b.path)
+		}
+	}
+}
+
+func (c *Controller) run(args ...string) (string, error) {
+	output, err := exec.Command(c.config.BinPath, args...).CombinedOutput()
+	return strings.TrimSpace(string(output)), err
+}
+
+// writeAtomic replaces a file through a rename so nginx never reads a
+// partially written config
+func writeAtomic(path string, content []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".balancer-studio-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file for %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to chmod %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// GetDefaultConfig returns nginx paths from the environment
+func GetDefaultConfig() Config {
+	return Config{
+		BinPath:     getEnv("NGINX_BIN_PATH", "/usr/sbin/nginx"),
+		ConfPath:    getEnv("NGINX_CONF_PATH", "/etc/nginx/nginx.conf"),
+		SitesPath:   getEnv("NGINX_SITES_PATH", "/etc/nginx/sites-available"),
+		StreamsPath: getEnv("NGINX_STREAMS_PATH", "/etc/nginx/streams-available"),
+		CertsPath:   getEnv("NGINX_CERTS_PATH", "/etc/nginx/certs"),
+		DataPath:    getEnv("NGINX_DATA_PATH", "/etc/nginx/balancer-studio"),
+		StatusAddr:  getEnv("NGINX_STATUS_ADDR", "127.0.0.1:8081"),
+	}
+}
+
+// getEnv gets environment variable or returns default value
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,33 @@
+package nginx
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// StatusPath is where the stub_status location is served on Config.StatusAddr
+const StatusPath = "/nginx_status"
+
+// statusClient fetches stub_status. nginx answers it locally, so a slow
+// answer means nginx is stuck.
+var statusClient = &http.Client{Timeout: 2 * time.Second}
+
+// StubStatus returns the stub_status page of the running nginx
+func (c *Controller) StubStatus() (string, error) {
+	resp, err := statusClient.Get("http://" + c.config.StatusAddr + StatusPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read nginx status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to read nginx status: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return "", fmt.Errorf("failed to read nginx status: %w", err)
+	}
+	return string(body), nil
+}
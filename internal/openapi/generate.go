@@ -0,0 +1,760 @@
+package openapi
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	paramPattern    = regexp.MustCompile(`^(\S+)\s+(path|query|header|body)\s+(\S+)\s+(true|false)\s+"([^"]*)"$`)
+	responsePattern = regexp.MustCompile(`^(\d{3})\s+\{(object|array|string|integer|number|boolean)\}\s+(\S+)(?:\s+"([^"]*)")?$`)
+	headerPattern   = regexp.MustCompile(`^(\d{3}(?:,\d{3})*)\s+\{(string|integer|number|boolean)\}\s+(\S+)\s+"([^"]*)"$`)
+	routerPattern   = regexp.MustCompile(`^(/\S*)\s+\[(get|post|put|patch|delete)\]$`)
+)
+
+// mimeTypes expands the short names accepted by @Accept and @Produce
+var mimeTypes = map[string]string{
+	"json":  "application/json",
+	"plain": "text/plain",
+	"html":  "text/html",
+}
+
+// typeDecl is a type declared in the package
+type typeDecl struct {
+	spec *ast.TypeSpec
+	doc  string
+}
+
+type generator struct {
+	fset  *token.FileSet
+	types map[string]typeDecl
+	doc   *Document
+}
+
+// Generate builds the document from the swag annotations of the Go package
+// in dir: the general API info above func main, one operation per function
+// with a @Router line, and a schema for every type those operations use.
+func Generate(dir string) (*Document, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{
+		fset:  token.NewFileSet(),
+		types: map[string]typeDecl{},
+		doc: &Document{
+			OpenAPI:    "3.0.3",
+			Paths:      map[string]PathItem{},
+			Components: Components{Schemas: map[string]*Schema{}},
+		},
+	}
+
+	var files []*ast.File
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(g.fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec := spec.(*ast.TypeSpec)
+				doc := spec.Doc
+				if doc == nil {
+					doc = gen.Doc
+				}
+				g.types[spec.Name.Name] = typeDecl{spec: spec, doc: commentText(doc)}
+			}
+		}
+	}
+
+	// Security schemes come from the general info, so read it first
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "main" {
+				if err := g.general(file, fn); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	if g.doc.Info.Title == "" {
+		return nil, fmt.Errorf("%s: no @title above func main", dir)
+	}
+
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name != "main" {
+				if err := g.operation(fn); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return g.doc, nil
+}
+
+// annotation is one @ line of a comment
+type annotation struct {
+	pos   token.Pos
+	name  string
+	value string
+}
+
+// annotations returns the @ lines of comment groups
+func annotations(groups ...*ast.CommentGroup) []annotation {
+	var found []annotation
+	for _, group := range groups {
+		if group == nil {
+			continue
+		}
+		for _, comment := range group.List {
+			line := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+			if !strings.HasPrefix(line, "@") {
+				continue
+			}
+			name, value, _ := strings.Cut(line, " ")
+			found = append(found, annotation{pos: comment.Pos(), name: name, value: strings.TrimSpace(value)})
+		}
+	}
+	return found
+}
+
+// commentText joins the lines of a doc comment
+func commentText(group *ast.CommentGroup) string {
+	if group == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(group.Text()), " ")
+}
+
+func (g *generator) errorf(pos token.Pos, format string, args ...any) error {
+	return fmt.Errorf("%s: %s", g.fset.Position(pos), fmt.Sprintf(format, args...))
+}
+
+// general reads the API info from the comments above func main
+func (g *generator) general(file *ast.File, main *ast.FuncDecl) error {
+	var groups []*ast.CommentGroup
+	for _, group := range file.Comments {
+		if group.End() < main.Pos() {
+			groups = append(groups, group)
+		}
+	}
+
+	info := &g.doc.Info
+	var host, basePath string
+	var scheme *SecurityScheme
+	var schemeName string
+	for _, a := range annotations(groups...) {
+		switch a.name {
+		case "@title":
+			info.Title = a.value
+		case "@version":
+			info.Version = a.value
+		case "@description":
+			if scheme != nil {
+				scheme.Description = a.value
+			} else {
+				info.Description = a.value
+			}
+		case "@termsOfService":
+			info.TermsOfService = a.value
+		case "@contact.name", "@contact.url", "@contact.email":
+			if info.Contact == nil {
+				info.Contact = &Contact{}
+			}
+			switch a.name {
+			case "@contact.name":
+				info.Contact.Name = a.value
+			case "@contact.url":
+				info.Contact.URL = a.value
+			default:
+				info.Contact.Email = a.value
+			}
+		case "@license.name", "@license.url":
+			if info.License == nil {
+				info.License = &License{}
+			}
+			if a.name == "@license.name" {
+				info.License.Name = a.value
+			} else {
+				info.License.URL = a.value
+			}
+		case "@host":
+			host = a.value
+		case "@BasePath":
+			basePath = a.value
+		case "@tag.name":
+			g.doc.Tags = append(g.doc.Tags, Tag{Name: a.value})
+		case "@tag.description":
+			if len(g.doc.Tags) == 0 {
+				return g.errorf(a.pos, "@tag.description without @tag.name")
+			}
+			g.doc.Tags[len(g.doc.Tags)-1].Description = a.value
+		case "@securityDefinitions.apikey":
+			if scheme != nil {
+				g.addSecurityScheme(schemeName, *scheme)
+			}
+			schemeName, scheme = a.value, &SecurityScheme{Type: "apiKey"}
+		case "@in", "@name":
+			if scheme == nil {
+				return g.errorf(a.pos, "%s outside @securityDefinitions", a.name)
+			}
+			if a.name == "@in" {
+				scheme.In = a.value
+			} else {
+				scheme.Name = a.value
+			}
+		default:
+			return g.errorf(a.pos, "unknown annotation %s", a.name)
+		}
+	}
+	if scheme != nil {
+		g.addSecurityScheme(schemeName, *scheme)
+	}
+	if host != "" || basePath != "" {
+		url := basePath
+		if host != "" {
+			url = "http://" + host + basePath
+		}
+		g.doc.Servers = []Server{{URL: url}}
+	}
+	return nil
+}
+
+func (g *generator) addSecurityScheme(name string, scheme SecurityScheme) {
+	if g.doc.Components.SecuritySchemes == nil {
+		g.doc.Components.SecuritySchemes = map[string]SecurityScheme{}
+	}
+	g.doc.Components.SecuritySchemes[name] = scheme
+}
+
+// operation adds the route documented on a handler. Functions without a
+// @Router line are skipped.
+func (g *generator) operation(fn *ast.FuncDecl) error {
+	found := annotations(fn.Doc)
+	if !slices.ContainsFunc(found, func(a annotation) bool { return a.name == "@Router" }) {
+		return nil
+	}
+
+	op := &Operation{OperationID: fn.Name.Name, Responses: map[string]Response{}}
+	accept := []string{"application/json"}
+	produce := []string{"application/json"}
+	var path, method string
+	var params, responses, headers []annotation
+	for _, a := range found {
+		switch a.name {
+		case "@Summary":
+			op.Summary = a.value
+		case "@Description":
+			op.Description = strings.TrimSpace(op.Description + " " + a.value)
+		case "@Tags":
+			for _, tag := range strings.Split(a.value, ",") {
+				op.Tags = append(op.Tags, strings.TrimSpace(tag))
+			}
+		case "@Accept", "@Produce":
+			var types []string
+			for _, name := range strings.Split(a.value, ",") {
+				name = strings.TrimSpace(name)
+				if mime, ok := mimeTypes[name]; ok {
+					name = mime
+				} else if !strings.Contains(name, "/") {
+					return g.errorf(a.pos, "unknown content type %q", name)
+				}
+				types = append(types, name)
+			}
+			if a.name == "@Accept" {
+				accept = types
+			} else {
+				produce = types
+			}
+		case "@Param":
+			params = append(params, a)
+		case "@Success", "@Failure":
+			responses = append(responses, a)
+		case "@Header":
+			headers = append(headers, a)
+		case "@Security":
+			if _, ok := g.doc.Components.SecuritySchemes[a.value]; !ok {
+				return g.errorf(a.pos, "unknown security scheme %q", a.value)
+			}
+			op.Security = append(op.Security, map[string][]string{a.value: {}})
+		case "@Router":
+			m := routerPattern.FindStringSubmatch(a.value)
+			if m == nil {
+				return g.errorf(a.pos, "@Router must look like /path/{param} [method]")
+			}
+			path, method = m[1], m[2]
+		default:
+			return g.errorf(a.pos, "unknown annotation %s", a.name)
+		}
+	}
+
+	// Parameters and responses need the final @Accept and @Produce
+	for _, a := range params {
+		if err := g.parameter(op, a, accept); err != nil {
+			return err
+		}
+	}
+	for _, a := range responses {
+		if err := g.response(op, a, produce); err != nil {
+			return err
+		}
+	}
+	if len(op.Responses) == 0 {
+		return g.errorf(fn.Pos(), "%s has no @Success or @Failure", fn.Name.Name)
+	}
+	for _, a := range headers {
+		if err := g.header(op, a); err != nil {
+			return err
+		}
+	}
+
+	item := g.doc.Paths[path]
+	if item == nil {
+		item = PathItem{}
+		g.doc.Paths[path] = item
+	}
+	if existing, ok := item[method]; ok {
+		return g.errorf(fn.Pos(), "%s %s is already documented on %s", strings.ToUpper(method), path, existing.OperationID)
+	}
+	item[method] = op
+	return nil
+}
+
+// parameter reads a @Param line: name, in, type, required and description
+func (g *generator) parameter(op *Operation, a annotation, accept []string) error {
+	m := paramPattern.FindStringSubmatch(a.value)
+	if m == nil {
+		return g.errorf(a.pos, `@Param must look like name in type required "description"`)
+	}
+	schema, err := g.typeSchema(a.pos, m[3])
+	if err != nil {
+		return err
+	}
+	required := m[4] == "true"
+
+	if m[2] == "body" {
+		if op.RequestBody != nil {
+			return g.errorf(a.pos, "second body parameter %s", m[1])
+		}
+		op.RequestBody = &RequestBody{Description: m[5], Required: required, Content: content(accept, schema)}
+		return nil
+	}
+	op.Parameters = append(op.Parameters, Parameter{
+		Name:        m[1],
+		In:          m[2],
+		Description: m[5],
+		Required:    required || m[2] == "path",
+		Schema:      schema,
+	})
+	return nil
+}
+
+// response reads a @Success or @Failure line: code, {kind} type and an
+// optional description
+func (g *generator) response(op *Operation, a annotation, produce []string) error {
+	m := responsePattern.FindStringSubmatch(a.value)
+	if m == nil {
+		return g.errorf(a.pos, `%s must look like code {object} Type "description"`, a.name)
+	}
+	code, _ := strconv.Atoi(m[1])
+	description := m[4]
+	if description == "" {
+		description = http.StatusText(code)
+	}
+
+	var schema *Schema
+	switch m[2] {
+	case "object", "array":
+		item, err := g.typeSchema(a.pos, m[3])
+		if err != nil {
+			return err
+		}
+		schema = item
+		if m[2] == "array" {
+			schema = &Schema{Type: "array", Items: item}
+		}
+	default:
+		schema = &Schema{Type: m[2]}
+	}
+
+	if _, ok := op.Responses[m[1]]; ok {
+		return g.errorf(a.pos, "second response for %s", m[1])
+	}
+	op.Responses[m[1]] = Response{Description: description, Content: content(produce, schema)}
+	return nil
+}
+
+// header reads a @Header line: comma separated response codes, {type},
+// name and description
+func (g *generator) header(op *Operation, a annotation) error {
+	m := headerPattern.FindStringSubmatch(a.value)
+	if m == nil {
+		return g.errorf(a.pos, `@Header must look like code {string} Name "description"`)
+	}
+	for _, code := range strings.Split(m[1], ",") {
+		response, ok := op.Responses[code]
+		if !ok {
+			return g.errorf(a.pos, "@Header for undocumented response %s", code)
+		}
+		if response.Headers == nil {
+			response.Headers = map[string]Header{}
+		}
+		response.Headers[m[3]] = Header{Description: m[4], Schema: &Schema{Type: m[2]}}
+		op.Responses[code] = response
+	}
+	return nil
+}
+
+func content(types []string, schema *Schema) map[string]MediaType {
+	media := map[string]MediaType{}
+	for _, t := range types {
+		media[t] = MediaType{Schema: schema}
+	}
+	return media
+}
+
+// typeSchema resolves a Go type written in an annotation
+func (g *generator) typeSchema(pos token.Pos, text string) (*Schema, error) {
+	expr, err := parser.ParseExpr(text)
+	if err != nil {
+		return nil, g.errorf(pos, "invalid type %q", text)
+	}
+	schema, err := g.schema(expr, nil)
+	if err != nil {
+		return nil, g.errorf(pos, "%v", err)
+	}
+	return schema, nil
+}
+
+// schema converts a Go type expression. Type parameters of a generic type
+// being expanded are looked up in scope.
+func (g *generator) schema(expr ast.Expr, scope map[string]*Schema) (*Schema, error) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if schema, ok := scope[t.Name]; ok {
+			return schema, nil
+		}
+		if schema := basicSchema(t.Name); schema != nil {
+			return schema, nil
+		}
+		return g.named(t.Name, nil, scope)
+	case *ast.IndexExpr:
+		return g.instance(t.X, []ast.Expr{t.Index}, scope)
+	case *ast.IndexListExpr:
+		return g.instance(t.X, t.Indices, scope)
+	case *ast.StarExpr:
+		return g.schema(t.X, scope)
+	case *ast.ArrayType:
+		if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+			return &Schema{Type: "string", Format: "byte"}, nil
+		}
+		items, err := g.schema(t.Elt, scope)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case *ast.MapType:
+		values, err := g.schema(t.Value, scope)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case *ast.InterfaceType:
+		return &Schema{}, nil
+	case *ast.StructType:
+		return g.object(t, scope)
+	case *ast.SelectorExpr:
+		switch name := qualifiedName(t); name {
+		case "time.Time":
+			return &Schema{Type: "string", Format: "date-time"}, nil
+		case "time.Duration":
+			return &Schema{Type: "integer", Format: "int64"}, nil
+		case "fiber.Map":
+			return &Schema{Type: "object", AdditionalProperties: &Schema{}}, nil
+		case "json.RawMessage":
+			return &Schema{}, nil
+		default:
+			return nil, fmt.Errorf("unsupported type %s", name)
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %T", expr)
+}
+
+// qualifiedName formats a type name such as time.Time
+func qualifiedName(expr *ast.SelectorExpr) string {
+	if pkg, ok := expr.X.(*ast.Ident); ok {
+		return pkg.Name + "." + expr.Sel.Name
+	}
+	return expr.Sel.Name
+}
+
+func basicSchema(name string) *Schema {
+	switch name {
+	case "string":
+		return &Schema{Type: "string"}
+	case "bool":
+		return &Schema{Type: "boolean"}
+	case "int", "int8", "int16", "int32", "uint", "uint8", "uint16", "uint32", "byte", "rune":
+		return &Schema{Type: "integer"}
+	case "int64", "uint64":
+		return &Schema{Type: "integer", Format: "int64"}
+	case "float32", "float64":
+		return &Schema{Type: "number"}
+	case "any":
+		return &Schema{}
+	}
+	return nil
+}
+
+// instance expands a generic type such as Page[ProxyHost]
+func (g *generator) instance(base ast.Expr, args []ast.Expr, scope map[string]*Schema) (*Schema, error) {
+	ident, ok := base.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("unsupported generic type %T", base)
+	}
+	return g.named(ident.Name, args, scope)
+}
+
+// named returns the schema of a type declared in the package. Structs become
+// components named after the type and its type arguments, other types are
+// inlined.
+func (g *generator) named(name string, args []ast.Expr, scope map[string]*Schema) (*Schema, error) {
+	decl, ok := g.types[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %s", name)
+	}
+
+	var params []string
+	if decl.spec.TypeParams != nil {
+		for _, field := range decl.spec.TypeParams.List {
+			for _, param := range field.Names {
+				params = append(params, param.Name)
+			}
+		}
+	}
+	if len(params) != len(args) {
+		return nil, fmt.Errorf("%s takes %d type arguments, got %d", name, len(params), len(args))
+	}
+
+	inner := map[string]*Schema{}
+	component := name
+	for i, arg := range args {
+		schema, err := g.schema(arg, scope)
+		if err != nil {
+			return nil, err
+		}
+		inner[params[i]] = schema
+		component += "-" + schemaName(schema, arg)
+	}
+
+	structType, ok := decl.spec.Type.(*ast.StructType)
+	if !ok {
+		return g.schema(decl.spec.Type, inner)
+	}
+	ref := &Schema{Ref: "#/components/schemas/" + component}
+	if _, ok := g.doc.Components.Schemas[component]; ok {
+		return ref, nil
+	}
+	// Reserve the name first so recursive types end in a reference
+	g.doc.Components.Schemas[component] = &Schema{}
+	schema, err := g.object(structType, inner)
+	if err != nil {
+		return nil, err
+	}
+	schema.Description = decl.doc
+	g.doc.Components.Schemas[component] = schema
+	return ref, nil
+}
+
+// schemaName names a type argument inside a component name
+func schemaName(schema *Schema, arg ast.Expr) string {
+	if schema.Ref != "" {
+		return strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+	}
+	if ident, ok := arg.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return schema.Type
+}
+
+// object converts a struct. Embedded structs without a json name are
+// flattened like encoding/json does.
+func (g *generator) object(structType *ast.StructType, scope map[string]*Schema) (*Schema, error) {
+	object := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, field := range structType.Fields.List {
+		tag := reflect.StructTag("")
+		if field.Tag != nil {
+			value, err := strconv.Unquote(field.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(value)
+		}
+		jsonName, _, _ := strings.Cut(tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+
+		if len(field.Names) == 0 && jsonName == "" {
+			embedded, err := g.embedded(field.Type, scope)
+			if err != nil {
+				return nil, err
+			}
+			for name, property := range embedded.Properties {
+				object.Properties[name] = property
+			}
+			object.Required = append(object.Required, embedded.Required...)
+			continue
+		}
+
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{embeddedName(field.Type)}
+		}
+		for _, ident := range names {
+			if !ident.IsExported() {
+				continue
+			}
+			property, err := g.schema(field.Type, scope)
+			if err != nil {
+				return nil, fmt.Errorf("%s: field %s: %v", g.fset.Position(field.Pos()), ident.Name, err)
+			}
+			if property.Ref == "" {
+				// Shallow copy so shared schemas keep their own example
+				copied := *property
+				property = &copied
+				property.Description = commentText(field.Doc)
+				if property.Description == "" {
+					property.Description = commentText(field.Comment)
+				}
+				if example, ok := tag.Lookup("example"); ok {
+					value, err := exampleValue(property, example)
+					if err != nil {
+						return nil, fmt.Errorf("%s: field %s: %v", g.fset.Position(field.Pos()), ident.Name, err)
+					}
+					property.Example = value
+				}
+			}
+
+			name := jsonName
+			if name == "" {
+				name = ident.Name
+			}
+			object.Properties[name] = property
+			if slices.Contains(strings.Split(tag.Get("binding"), ","), "required") {
+				object.Required = append(object.Required, name)
+			}
+		}
+	}
+	return object, nil
+}
+
+// embedded returns the inline schema of an embedded struct
+func (g *generator) embedded(expr ast.Expr, scope map[string]*Schema) (*Schema, error) {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	var args []ast.Expr
+	switch t := expr.(type) {
+	case *ast.IndexExpr:
+		expr, args = t.X, []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		expr, args = t.X, t.Indices
+	}
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported embedded type", g.fset.Position(expr.Pos()))
+	}
+	decl, ok := g.types[ident.Name]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown type %s", g.fset.Position(expr.Pos()), ident.Name)
+	}
+	structType, ok := decl.spec.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("%s: embedded %s is not a struct", g.fset.Position(expr.Pos()), ident.Name)
+	}
+
+	inner := map[string]*Schema{}
+	if decl.spec.TypeParams != nil {
+		i := 0
+		for _, field := range decl.spec.TypeParams.List {
+			for _, param := range field.Names {
+				if i >= len(args) {
+					return nil, fmt.Errorf("%s: missing type arguments for %s", g.fset.Position(expr.Pos()), ident.Name)
+				}
+				schema, err := g.schema(args[i], scope)
+				if err != nil {
+					return nil, err
+				}
+				inner[param.Name] = schema
+				i++
+			}
+		}
+	}
+	return g.object(structType, inner)
+}
+
+// embeddedName is the field name of an embedded type
+func embeddedName(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.IndexExpr:
+		return embeddedName(t.X)
+	case *ast.IndexListExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	case *ast.Ident:
+		return t
+	}
+	return ast.NewIdent("")
+}
+
+// exampleValue converts an example tag to the type of its schema. Arrays
+// take comma separated items.
+func exampleValue(schema *Schema, example string) (any, error) {
+	switch schema.Type {
+	case "array":
+		if schema.Items == nil {
+			return nil, fmt.Errorf("example on array without items")
+		}
+		values := []any{}
+		for _, item := range strings.Split(example, ",") {
+			value, err := exampleValue(schema.Items, item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case "integer":
+		return strconv.ParseInt(example, 10, 64)
+	case "number":
+		return strconv.ParseFloat(example, 64)
+	case "boolean":
+		return strconv.ParseBool(example)
+	case "string":
+		return example, nil
+	}
+	return nil, fmt.Errorf("example on %q schema", schema.Type)
+}
@@ -0,0 +1,175 @@
+package openapi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const header = `package main
+
+// @title    Test API
+// @version  1.0
+// @BasePath /api
+func main() {}
+
+// Item is a stored thing
+type Item struct {
+	ID   int      ` + "`json:\"id\" example:\"7\"`" + `
+	Name string   ` + "`json:\"name\" binding:\"required\" example:\"box\"`" + `
+	Tags []string ` + "`json:\"tags,omitempty\" example:\"a,b\"`" + `
+	note string
+}
+
+// Page is one page of a list
+type Page[T any] struct {
+	Items []T ` + "`json:\"items\"`" + `
+	Total int ` + "`json:\"total\" example:\"1\"`" + `
+}
+
+type ErrorResponse struct {
+	Error string ` + "`json:\"error\"`" + `
+}
+`
+
+// generate writes source after the shared declarations into a package and
+// generates its document
+func generate(t *testing.T, source string) (*Document, error) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(header+source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return Generate(dir)
+}
+
+func TestGenerateOperation(t *testing.T) {
+	doc, err := generate(t, `
+// UpdateItem godoc
+// @Summary      Update an item
+// @Description  Replace an item.
+// @Description  Writes are conditional.
+// @Tags         items, things
+// @Accept       json
+// @Produce      json
+// @Param        id path int true "Item ID"
+// @Param        q query string false "Filter"
+// @Param        If-Match header string false "ETag"
+// @Param        item body Item true "New item"
+// @Success      200 {object} Page[Item]
+// @Header       200,412 {string} ETag "Version"
+// @Failure      412 {object} ErrorResponse "Stale"
+// @Router       /items/{id} [put]
+func UpdateItem() {}
+
+// helper has no @Router and is skipped
+func helper() {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Info.Title != "Test API" || len(doc.Servers) != 1 || doc.Servers[0].URL != "/api" {
+		t.Errorf("general info = %+v, servers %+v", doc.Info, doc.Servers)
+	}
+	if len(doc.Paths) != 1 {
+		t.Fatalf("paths = %v, want only /items/{id}", doc.Paths)
+	}
+	op := doc.Paths["/items/{id}"]["put"]
+	if op == nil {
+		t.Fatal("PUT /items/{id} is missing")
+	}
+
+	if op.OperationID != "UpdateItem" || op.Description != "Replace an item. Writes are conditional." {
+		t.Errorf("operation = %q, %q", op.OperationID, op.Description)
+	}
+	if !reflect.DeepEqual(op.Tags, []string{"items", "things"}) {
+		t.Errorf("tags = %v", op.Tags)
+	}
+
+	want := []Parameter{
+		{Name: "id", In: "path", Description: "Item ID", Required: true, Schema: &Schema{Type: "integer"}},
+		{Name: "q", In: "query", Description: "Filter", Schema: &Schema{Type: "string"}},
+		{Name: "If-Match", In: "header", Description: "ETag", Schema: &Schema{Type: "string"}},
+	}
+	if !reflect.DeepEqual(op.Parameters, want) {
+		t.Errorf("parameters = %+v, want %+v", op.Parameters, want)
+	}
+	if op.RequestBody == nil || !op.RequestBody.Required || op.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/Item" {
+		t.Errorf("request body = %+v", op.RequestBody)
+	}
+
+	ok := op.Responses["200"]
+	if ok.Description != "OK" || ok.Content["application/json"].Schema.Ref != "#/components/schemas/Page-Item" {
+		t.Errorf("200 = %+v", ok)
+	}
+	stale := op.Responses["412"]
+	if stale.Description != "Stale" {
+		t.Errorf("412 description = %q", stale.Description)
+	}
+	for _, code := range []string{"200", "412"} {
+		if h, found := op.Responses[code].Headers["ETag"]; !found || h.Schema.Type != "string" {
+			t.Errorf("%s has no ETag header", code)
+		}
+	}
+
+	page := doc.Components.Schemas["Page-Item"]
+	if page == nil || page.Description != "Page is one page of a list" {
+		t.Fatalf("Page-Item = %+v", page)
+	}
+	if items := page.Properties["items"]; items.Type != "array" || items.Items.Ref != "#/components/schemas/Item" {
+		t.Errorf("Page-Item.items = %+v", items)
+	}
+
+	item := doc.Components.Schemas["Item"]
+	if item == nil {
+		t.Fatal("Item schema is missing")
+	}
+	if _, found := item.Properties["note"]; found {
+		t.Error("unexported field was documented")
+	}
+	if !reflect.DeepEqual(item.Required, []string{"name"}) {
+		t.Errorf("Item.required = %v", item.Required)
+	}
+	if got := item.Properties["id"].Example; got != int64(7) {
+		t.Errorf("Item.id example = %#v", got)
+	}
+	if got := item.Properties["tags"].Example; !reflect.DeepEqual(got, []any{"a", "b"}) {
+		t.Errorf("Item.tags example = %#v", got)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	handler := func(lines ...string) string {
+		return "\n// Handler godoc\n// " + strings.Join(lines, "\n// ") + "\nfunc Handler() {}\n"
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"param without description", handler(`@Param id path int true`, `@Success 200 {object} Item`, `@Router /items/{id} [get]`), "@Param must look like"},
+		{"param in cookie", handler(`@Param id cookie int true "ID"`, `@Success 200 {object} Item`, `@Router /items [get]`), "@Param must look like"},
+		{"router without method", handler(`@Success 200 {object} Item`, `@Router /items`), "@Router must look like"},
+		{"router with unknown method", handler(`@Success 200 {object} Item`, `@Router /items [head]`), "@Router must look like"},
+		{"response without kind", handler(`@Success 200 Item`, `@Router /items [get]`), "@Success must look like"},
+		{"no responses", handler(`@Router /items [get]`), "has no @Success or @Failure"},
+		{"header for undocumented code", handler(`@Success 200 {object} Item`, `@Header 404 {string} ETag "Version"`, `@Router /items [get]`), "undocumented response 404"},
+		{"unknown annotation", handler(`@Deprecated`, `@Success 200 {object} Item`, `@Router /items [get]`), "unknown annotation @Deprecated"},
+		{"unknown type", handler(`@Success 200 {object} Missing`, `@Router /items [get]`), "unknown type Missing"},
+		{"missing type argument", handler(`@Success 200 {object} Page`, `@Router /items [get]`), "Page takes 1 type arguments, got 0"},
+		{"second body", handler(`@Param a body Item true "A"`, `@Param b body Item true "B"`, `@Success 200 {object} Item`, `@Router /items [post]`), "second body parameter b"},
+		{"duplicate route", handler(`@Success 200 {object} Item`, `@Router /items [get]`) +
+			"\n// Other godoc\n// @Success 200 {object} Item\n// @Router /items [get]\nfunc Other() {}\n", "already documented on"},
+	}
+
+	for _, tt := range tests {
+		_, err := generate(t, tt.source)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+}